@@ -12,6 +12,12 @@ int buttonStates[2] = {LOW, LOW}; // Array to store previous button states
 unsigned long debounceTime[2] = {0, 0}; // Debounce timers for buttons
 const int debounceDelay = 20; // Debounce delay in milliseconds. Adjust if you feel the LEDs turn on too slowly or too quickly
 
+const int NUM_LEVEL_LEDS = 2; // LEDs that light up as loud as a slider is playing, needs send_levels_to_device: true in config.yaml
+const int levelLedPins[NUM_LEVEL_LEDS] = {9, 10}; // Change to PWM pins your LEDs are connected to, one per slider starting at the first
+
+char levelLine[64]; // Level line being received from ReeeMiks, one percentage per slider (i.e "l0|l57|l100")
+int levelLineLength = 0;
+
 void setup() {
   for (int i = 0; i < NUM_SLIDERS; i++) {
     pinMode(analogInputs[i], INPUT);
@@ -25,6 +31,10 @@ void setup() {
     pinMode(ledPins[i], OUTPUT); // Set LED pins as output
   }
 
+  for (int i = 0; i < NUM_LEVEL_LEDS; i++) {
+    pinMode(levelLedPins[i], OUTPUT);
+  }
+
   Serial.begin(9600);
 }
 
@@ -32,6 +42,7 @@ void loop() {
   updateSliderValues();
   updateButtonStates();
   sendSliderValues(); // Send slider & button data
+  readLevels(); // Show slider levels sent by ReeeMiks
   // printSliderValues(); // For debug
   delay(10);
 }
@@ -87,6 +98,37 @@ void sendSliderValues() {
   Serial.println(builtString);
 }
 
+// Reads whatever ReeeMiks has sent so far without waiting for the rest, and shows each complete line
+void readLevels() {
+  while (Serial.available() > 0) {
+    char c = Serial.read();
+
+    if (c == '\n') {
+      levelLine[levelLineLength] = '\0';
+      showLevels(levelLine);
+      levelLineLength = 0;
+    } else if (c != '\r' && levelLineLength < (int)sizeof(levelLine) - 1) {
+      levelLine[levelLineLength++] = c;
+    }
+  }
+}
+
+// Sets each level LED's brightness to its slider's level
+void showLevels(char *line) {
+  int slider = 0;
+  char *value = strtok(line, "|");
+
+  while (value != NULL && slider < NUM_LEVEL_LEDS) {
+    if (value[0] == 'l') {
+      int percent = constrain(atoi(value + 1), 0, 100);
+      analogWrite(levelLedPins[slider], map(percent, 0, 100, 0, 255));
+    }
+
+    slider++;
+    value = strtok(NULL, "|");
+  }
+}
+
 // Optional debug function (comment out in production)
 void printSliderValues() {
   // ... (same as before)
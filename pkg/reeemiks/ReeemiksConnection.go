@@ -4,6 +4,11 @@ package reeemiks
 type SliderMoveEvent struct {
 	SliderID     int
 	PercentValue float32
+
+	// set for encoders and HID up/down, which only say how many steps they turned (negative for down).
+	// the session map works out PercentValue from the targets' current volume
+	Relative bool
+	Steps    int
 }
 
 type ButtonEvent struct {
@@ -11,9 +16,21 @@ type ButtonEvent struct {
 	Value 			 int
 }
 
+// ConnectionStatus describes the state of the link to the reeemiks board, as last seen by the connection
+type ConnectionStatus struct {
+	Connected  bool
+	Port       string
+	NumSliders int
+	NumButtons int
+}
+
 type ReeemiksConnection interface {
 	Start() error
 	Stop()
+	Status() ConnectionStatus
 	SubscribeToSliderMoveEvents() chan SliderMoveEvent
 	SubscribeToButtonEvents() chan ButtonEvent
+
+	// SendLevels shows how loud every slider is playing (0 to 1, by slider index) on the board, if it can
+	SendLevels(levels []float32) error
 }
@@ -0,0 +1,157 @@
+package reeemiks
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// autoProfileRule switches to a profile whenever any of its apps has an audio session
+type autoProfileRule struct {
+	Profile  string   `mapstructure:"profile"`
+	Apps     []string `mapstructure:"apps"`
+	Priority int      `mapstructure:"priority"`
+}
+
+// autoProfiler picks the active profile based on which applications are currently playing audio.
+// a manual switch locks it until the user hands control back, so it never fights with the user
+type autoProfiler struct {
+	reeemiks *Reeemiks
+	logger   *zap.SugaredLogger
+
+	locked  bool
+	pending string // the profile we're currently switching to, if any
+	lock    sync.Mutex
+
+	stopChannel chan bool
+}
+
+const (
+
+	// sessions are otherwise only refreshed when sliders move, which is too late to notice a game starting
+	autoProfileCheckInterval = 10 * time.Second
+)
+
+func newAutoProfiler(reeemiks *Reeemiks, logger *zap.SugaredLogger) *autoProfiler {
+	logger = logger.Named("auto_profile")
+
+	ap := &autoProfiler{
+		reeemiks:    reeemiks,
+		logger:      logger,
+		stopChannel: make(chan bool),
+	}
+
+	logger.Debug("Created auto profiler instance")
+
+	return ap
+}
+
+// start keeps the session map fresh enough for rules to kick in without anyone touching a slider
+func (ap *autoProfiler) start() {
+	ticker := time.NewTicker(autoProfileCheckInterval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ap.stopChannel:
+				return
+			case <-ticker.C:
+				if ap.active() {
+
+					// every refresh ends with an evaluation, the usual cooldown still applies
+					ap.reeemiks.sessions.refreshSessions(false)
+				}
+			}
+		}
+	}()
+}
+
+func (ap *autoProfiler) stop() {
+	close(ap.stopChannel)
+}
+
+// active returns true if there are rules and the user hasn't locked the profile in place
+func (ap *autoProfiler) active() bool {
+	ap.lock.Lock()
+	defer ap.lock.Unlock()
+
+	return !ap.locked && len(ap.reeemiks.config.snapshot().AutoProfileRules) > 0
+}
+
+// overrideManually stops automatic switching until release is called
+func (ap *autoProfiler) overrideManually() {
+	ap.lock.Lock()
+	defer ap.lock.Unlock()
+
+	if !ap.locked && len(ap.reeemiks.config.snapshot().AutoProfileRules) > 0 {
+		ap.logger.Info("Profile picked manually, pausing automatic profile switching")
+	}
+
+	ap.locked = true
+}
+
+// release hands profile selection back to the rules and applies them right away
+func (ap *autoProfiler) release() {
+	ap.lock.Lock()
+	ap.locked = false
+	ap.lock.Unlock()
+
+	ap.logger.Info("Resuming automatic profile switching")
+
+	ap.evaluate()
+}
+
+// evaluate switches to the profile of the highest priority rule that has a running app,
+// or back to the manually picked profile when none of them do
+func (ap *autoProfiler) evaluate() {
+	if !ap.active() {
+		return
+	}
+
+	config := ap.reeemiks.config.snapshot()
+	target := config.ManualProfile
+	reason := "no rules matched"
+
+	for _, rule := range config.AutoProfileRules {
+		if len(ap.reeemiks.sessions.resolveSessions(rule.Apps)) > 0 {
+			target = rule.Profile
+			reason = fmt.Sprintf("apps running: %v", rule.Apps)
+
+			break
+		}
+	}
+
+	ap.lock.Lock()
+	defer ap.lock.Unlock()
+
+	if target == config.ActiveProfile || target == ap.pending {
+		return
+	}
+
+	ap.logger.Infow("Switching profile automatically", "profile", target, "reason", reason)
+	ap.pending = target
+
+	// switching reloads the config, which re-acquires sessions - so it can't happen on the refresh that got us here
+	go func() {
+		defer func() {
+			ap.lock.Lock()
+			ap.pending = ""
+			ap.lock.Unlock()
+		}()
+
+		// the user may have picked a profile themselves in the meantime
+		if !ap.active() {
+			return
+		}
+
+		if err := ap.reeemiks.config.SetActiveProfile(target, false); err != nil {
+			ap.logger.Warnw("Failed to switch profile automatically", "profile", target, "error", err)
+		} else {
+			ap.reeemiks.notifier.Notify("Profile switched!", fmt.Sprintf("Now using the %s profile.", target))
+		}
+	}()
+}
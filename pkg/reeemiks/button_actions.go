@@ -0,0 +1,76 @@
+package reeemiks
+
+import "strings"
+
+// besides a key code to press, a button can be mapped to a named action followed by its arguments, e.g. [profile, next]
+const (
+	buttonActionProfile     = "profile"
+	buttonActionFade        = "fade"
+	buttonActionMove        = "move"
+	buttonActionDefaultSink = "default_sink"
+)
+
+// how many arguments each button action needs
+var buttonActionArgs = map[string]int{
+	buttonActionProfile: 1,
+	buttonActionLayer:   1,
+	buttonActionFade:    3,
+
+	buttonActionMove:        2,
+	buttonActionDefaultSink: 1,
+}
+
+// runButtonAction is called both when the button goes down and when it comes back up.
+// most actions only care about the press, while others (like holding a layer) need both
+func (m *sessionMap) runButtonAction(buttonID int, action string, args []string, pressed bool) {
+	action = strings.ToLower(action)
+
+	requiredArgs, ok := buttonActionArgs[action]
+	if !ok {
+		m.logger.Warnw("Unknown button action", "button", buttonID, "action", action)
+		return
+	}
+
+	if len(args) < requiredArgs {
+		m.logger.Warnw("Not enough arguments for button action",
+			"button", buttonID,
+			"action", action,
+			"args", args,
+			"required", requiredArgs)
+
+		return
+	}
+
+	m.logger.Debugw("Triggering button action", "button", buttonID, "action", action, "args", args, "pressed", pressed)
+
+	switch action {
+	case buttonActionProfile:
+		if pressed {
+			m.reeemiks.switchProfile(args[0]) // failures are already logged and shown to the user
+		}
+
+	case buttonActionLayer:
+		m.handleLayerButton(buttonID, args, pressed)
+
+	case buttonActionFade:
+		if pressed {
+			if err := m.fade(args[0], args[1], args[2]); err != nil {
+				m.logger.Warnw("Failed to fade", "button", buttonID, "args", args, "error", err)
+			}
+		}
+
+	case buttonActionMove:
+		if pressed {
+			if _, err := m.moveSessions(args[0], args[1]); err != nil {
+				m.logger.Warnw("Failed to move audio sessions", "button", buttonID, "args", args, "error", err)
+			}
+		}
+
+	case buttonActionDefaultSink:
+		if pressed {
+			if _, err := m.switchDefaultSink(args[0]); err != nil {
+				m.logger.Warnw("Failed to switch default sink", "button", buttonID, "args", args, "error", err)
+			}
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
 
 	"github.com/Red-M/ReeeMiks/pkg/reeemiks"
 )
@@ -23,6 +24,16 @@ func init() {
 
 func main() {
 
+	// `reeemiks ctl ...` talks to an already running instance instead of starting a new one
+	if flag.Arg(0) == "ctl" {
+		if err := reeemiks.RunControlCommand(flag.Args()[1:], os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "reeemiks ctl: %v\n", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	// first we need a logger
 	logger, err := reeemiks.NewLogger(buildType)
 	if err != nil {
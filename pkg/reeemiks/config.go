@@ -2,14 +2,20 @@ package reeemiks
 
 import (
 	"fmt"
+	"os"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kirsle/configdir"
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
 
 	"github.com/Red-M/ReeeMiks/pkg/reeemiks/util"
 )
@@ -17,9 +23,36 @@ import (
 // CanonicalConfig provides application-wide access to configuration fields,
 // as well as loading/file watching logic for reeemiks's configuration file
 type CanonicalConfig struct {
+
+	// the configuration fields themselves live in the current snapshot, which is swapped out whole on every change.
+	// goroutines read it through snapshot(), once per event, so a reload never hands them half of each config
+	current atomic.Pointer[configSnapshot]
+
+	logger             *zap.SugaredLogger
+	notifier           Notifier
+	stopWatcherChannel chan bool
+
+	reloadConsumers []chan bool
+
+	// only used to get notified about changes to the user config, its values are never read
+	watcher *viper.Viper
+
+	// serializes loads and runtime changes that get written back to the internal config
+	internalLock sync.Mutex
+}
+
+// configSnapshot is one complete reading of reeemiks's config files. every load parses into a fresh snapshot,
+// which only replaces the current one once it's fully populated - a broken config never leaves us half-configured
+type configSnapshot struct {
 	SliderMapping *sliderMap
 	ButtonMapping map[string][]string
 
+	// alternate slider mappings, swapped in while a layer button is engaged
+	Layers map[string]*sliderMap
+
+	// per-slider behaviour, sliders without an entry use the defaults
+	SliderSettings map[int]sliderSettings
+
 	SerialConnectionInfo struct {
 		COMPort  string
 		BaudRate int
@@ -36,18 +69,50 @@ type CanonicalConfig struct {
 	InvertSliders bool
 	NoiseReductionLevel string
 
+	// measure how loud every slider is playing, and whether to send that to the board
+	EnableLevelMeter   bool
+	SendLevelsToDevice bool
+
 	ReeemiksMatching string
 
-	logger             *zap.SugaredLogger
-	notifier           Notifier
-	stopWatcherChannel chan bool
+	// which audio server interface to use on linux, pulse or pipewire
+	AudioBackend string
 
-	reloadConsumers []chan bool
+	// the PulseAudio servers to control, the first one without a namespace
+	PulseServers []pulseServer
+
+	// every profile the user config defines, always starting with the default one
+	Profiles      []string
+	ActiveProfile string
+
+	// the profile the user picked themselves, which automatic switching falls back to
+	ManualProfile string
+
+	// rules for switching profiles automatically, highest priority first
+	AutoProfileRules []autoProfileRule
 
+	// rules for bringing sliders down while other apps play
+	DuckingRules []duckingRule
+
+	// the sinks to cycle through when switching to the next one
+	DefaultSinks []string
+
+	// sinks reeemiks creates while it runs
+	VirtualDevices []virtualDevice
+
+	// the vipers this snapshot was populated from
 	userConfig     *viper.Viper
 	internalConfig *viper.Viper
 }
 
+// pulseServer is a PulseAudio server to control, local or remote. sessions of every server but the first one
+// get its namespace in front of their name, like mediapc/spotify
+type pulseServer struct {
+	Address   string `mapstructure:"address"`
+	Cookie    string `mapstructure:"cookie"`
+	Namespace string `mapstructure:"namespace"`
+}
+
 const (
 	userConfigName     = "config"
 	internalConfigName = "preferences"
@@ -55,6 +120,7 @@ const (
 	configType = "yaml"
 
 	configKeySliderMapping       = "slider_mapping"
+	configKeyRemovedSliderTargets = "removed_slider_targets"
 	configKeyButtonMapping       = "button_mapping"
 	configKeyInvertSliders       = "invert_sliders"
 	configKeyCOMPort             = "com_port"
@@ -65,7 +131,28 @@ const (
 	configKeyUsagePage           = "usage_page"
 	configKeyUsage               = "usage"
 	configKeyEnableHID           = "enable_hid_listen"
+	configKeyEnableLevelMeter    = "enable_level_meter"
+	configKeySendLevelsToDevice  = "send_levels_to_device"
 	configReeemiksMatching = "Reeemiks.matching"
+	configKeyProfiles            = "profiles"
+	configKeyActiveProfile       = "active_profile"
+	configKeyManualProfile       = "manual_profile"
+	configKeyAutoProfiles        = "auto_profiles"
+	configKeyLayers              = "layers"
+	configKeyDucking             = "ducking"
+	configKeyDefaultSinks        = "default_sinks"
+	configKeyVirtualDevices      = "virtual_devices"
+	configKeyAudioBackend        = "audio_backend"
+	configKeyPulseServer         = "pulse_server"
+	configKeyPulseCookie         = "pulse_cookie"
+	configKeyPulseServers        = "pulse_servers"
+
+	// the top-level mappings and settings, which every other profile falls back to
+	defaultProfileName = "default"
+
+	audioBackendPulse    = "pulse"
+	audioBackendPipeWire = "pipewire"
+	audioBackendALSA     = "alsa"
 
 	defaultCOMPort  = "COM4"
 	defaultBaudRate = 9600
@@ -93,7 +180,7 @@ var userConfigPath = func() string {
 	} else if xdg_exists && rel_exists {
 		fmt.Printf("WARN: I'm ignoring your config relative to my binary, your config is located at: %s\n", configFile)
 	} else if !xdg_exists && !rel_exists {
-		fmt.Errorf("Config file doesn't exist: %s", configFile)
+		fmt.Printf("WARN: Config file doesn't exist: %s\n", configFile)
 	}
 
 
@@ -101,7 +188,7 @@ var userConfigPath = func() string {
 }()
 var userConfigFilepath = path.Join(userConfigPath, userConfigFilename)
 var internalConfigPath = path.Join(userConfigPath, logDirectory)
-var internalConfigFilepath = path.Join(userConfigPath, "preferences.yaml")
+var internalConfigFilepath = path.Join(internalConfigPath, internalConfigName+"."+configType)
 
 var defaultSliderMapping = func() *sliderMap {
 	emptyMap := newSliderMap()
@@ -119,9 +206,25 @@ func NewConfig(logger *zap.SugaredLogger, notifier Notifier) (*CanonicalConfig,
 		notifier:           notifier,
 		reloadConsumers:    []chan bool{},
 		stopWatcherChannel: make(chan bool),
+		watcher:            newUserConfigViper(),
+	}
+
+	// start out with nothing but defaults, so there's always a usable snapshot until the first load
+	snapshot, err := cc.populateFromVipers(newUserConfigViper(), newInternalConfigViper())
+	if err != nil {
+		logger.Warnw("Failed to populate default config fields", "error", err)
+		return nil, fmt.Errorf("populate default config fields: %w", err)
 	}
 
-	// distinguish between the user-provided config (config.yaml) and the internal config (logs/preferences.yaml)
+	cc.current.Store(snapshot)
+
+	logger.Debug("Created config instance")
+
+	return cc, nil
+}
+
+// distinguish between the user-provided config (config.yaml) and the internal config (logs/preferences.yaml)
+func newUserConfigViper() *viper.Viper {
 	userConfig := viper.New()
 	userConfig.SetConfigName(userConfigFilename)
 	userConfig.SetConfigType(configType)
@@ -134,18 +237,18 @@ func NewConfig(logger *zap.SugaredLogger, notifier Notifier) (*CanonicalConfig,
 	userConfig.SetDefault(configKeyBaudRate, defaultBaudRate)
 	userConfig.SetDefault(configKeyEnableHID, false)
 	userConfig.SetDefault(configReeemiksMatching, map[string]string{})
+	userConfig.SetDefault(configKeyAudioBackend, audioBackendPulse)
 
+	return userConfig
+}
+
+func newInternalConfigViper() *viper.Viper {
 	internalConfig := viper.New()
 	internalConfig.SetConfigName(internalConfigName)
 	internalConfig.SetConfigType(configType)
 	internalConfig.AddConfigPath(internalConfigPath)
 
-	cc.userConfig = userConfig
-	cc.internalConfig = internalConfig
-
-	logger.Debug("Created config instance")
-
-	return cc, nil
+	return internalConfig
 }
 
 // Load reads reeemiks's config files from disk and tries to parse them
@@ -161,8 +264,40 @@ func (cc *CanonicalConfig) Load() error {
 		return fmt.Errorf("Config file doesn't exist: %s", userConfigFilepath)
 	}
 
+	// validate the user config before viper gets to it, so a broken file never replaces the values we're running with
+	contents, err := os.ReadFile(userConfigFilepath)
+	if err != nil {
+		cc.logger.Warnw("Failed to read user config", "error", err)
+		cc.notifier.Notify("Error loading configuration!", "Please check reeemiks's logs for more details.")
+
+		return fmt.Errorf("read user config: %w", err)
+	}
+
+	if problems := validateUserConfig(contents); len(problems) > 0 {
+		for _, problem := range problems {
+			cc.logger.Warnw("Invalid config value", "path", problem.path, "line", problem.line, "problem", problem.message)
+		}
+
+		description := problems[0].String()
+		if len(problems) > 1 {
+			description = fmt.Sprintf("%s (and %d more, see logs)", description, len(problems)-1)
+		}
+
+		cc.notifier.Notify("Invalid configuration!", description)
+
+		return &configValidationError{problems: problems}
+	}
+
+	// read everything into fresh vipers, leaving the ones behind the current snapshot alone
+	userConfig := newUserConfigViper()
+	internalConfig := newInternalConfigViper()
+
+	// hold off runtime mapping changes until the new snapshot is in place, or they'd get lost
+	cc.internalLock.Lock()
+	defer cc.internalLock.Unlock()
+
 	// load the user config
-	if err := cc.userConfig.ReadInConfig(); err != nil {
+	if err := userConfig.ReadInConfig(); err != nil {
 		cc.logger.Warnw("Viper failed to read user config", "error", err)
 
 		// if the error is yaml-format-related, show a sensible error. otherwise, show 'em to the logs
@@ -177,22 +312,46 @@ func (cc *CanonicalConfig) Load() error {
 	}
 
 	// load the internal config - this doesn't have to exist, so it can error
-	if err := cc.internalConfig.ReadInConfig(); err != nil {
+	if err := internalConfig.ReadInConfig(); err != nil {
 		cc.logger.Debugw("Viper failed to read internal config", "error", err, "reminder", "this is fine")
 	}
 
 	// canonize the configuration with viper's helpers
-	if err := cc.populateFromVipers(); err != nil {
+	snapshot, err := cc.populateFromVipers(userConfig, internalConfig)
+	if err != nil {
 		cc.logger.Warnw("Failed to populate config fields", "error", err)
 		return fmt.Errorf("populate config fields: %w", err)
 	}
 
+	// everything checked out, swap the new snapshot in all at once
+	cc.current.Store(snapshot)
+
 	cc.logger.Info("Loaded config successfully")
 	cc.logger.Infow("Config values",
-		"sliderMapping", cc.SliderMapping,
-		"serialSonnectionInfo", cc.SerialConnectionInfo,
-		"hidConectionInfo", cc.HidConnectionInfo,
-		"invertSliders", cc.InvertSliders)
+		"profile", snapshot.ActiveProfile,
+		"sliderMapping", snapshot.SliderMapping,
+		"serialSonnectionInfo", snapshot.SerialConnectionInfo,
+		"hidConectionInfo", snapshot.HidConnectionInfo,
+		"invertSliders", snapshot.InvertSliders)
+
+	return nil
+}
+
+// snapshot returns the config currently in effect. it's never modified, so hold on to it for the whole of an event
+func (cc *CanonicalConfig) snapshot() *configSnapshot {
+	return cc.current.Load()
+}
+
+// Reload re-reads reeemiks's config files and lets subscribers know about it if that worked
+func (cc *CanonicalConfig) Reload() error {
+	if err := cc.Load(); err != nil {
+		return fmt.Errorf("reload config: %w", err)
+	}
+
+	cc.logger.Info("Reloaded config successfully")
+	cc.notifier.Notify("Configuration reloaded!", "Your changes have been applied.")
+
+	cc.onConfigReloaded()
 
 	return nil
 }
@@ -218,8 +377,8 @@ func (cc *CanonicalConfig) WatchConfigFileChanges() {
 	lastAttemptedReload := time.Now()
 
 	// establish watch using viper as opposed to doing it ourselves, though our internal cooldown is still required
-	cc.userConfig.WatchConfig()
-	cc.userConfig.OnConfigChange(func(event fsnotify.Event) {
+	cc.watcher.WatchConfig()
+	cc.watcher.OnConfigChange(func(event fsnotify.Event) {
 
 		// when we get a write event...
 		if event.Op&fsnotify.Write == fsnotify.Write {
@@ -235,13 +394,8 @@ func (cc *CanonicalConfig) WatchConfigFileChanges() {
 				// wait a bit to let the editor actually flush the new file contents to disk
 				<-time.After(delayBetweenEventAndReload)
 
-				if err := cc.Load(); err != nil {
-					cc.logger.Warnw("Failed to reload config file", "error", err)
-				} else {
-					cc.logger.Info("Reloaded config successfully")
-					cc.notifier.Notify("Configuration reloaded!", "Your changes have been applied.")
-
-					cc.onConfigReloaded()
+				if err := cc.Reload(); err != nil {
+					cc.logger.Warnw("Failed to reload config file, keeping the last working config", "error", err)
 				}
 
 				// don't forget to update the time
@@ -253,7 +407,7 @@ func (cc *CanonicalConfig) WatchConfigFileChanges() {
 	// wait till they stop us
 	<-cc.stopWatcherChannel
 	cc.logger.Debug("Stopping user config file watcher")
-	cc.userConfig.OnConfigChange(nil)
+	cc.watcher.OnConfigChange(nil)
 }
 
 // StopWatchingConfigFile signals our filesystem watcher to stop
@@ -261,43 +415,411 @@ func (cc *CanonicalConfig) StopWatchingConfigFile() {
 	cc.stopWatcherChannel <- true
 }
 
-func (cc *CanonicalConfig) populateFromVipers() error {
+// AddSliderTarget binds an additional target to a slider of the active profile at runtime. the change is saved to the internal
+// config (leaving the user's config file untouched), survives restarts and takes effect immediately
+func (cc *CanonicalConfig) AddSliderTarget(sliderIdx int, target string) error {
+	err := cc.updateInternalConfig(func() error {
+		sliderKey := strconv.Itoa(sliderIdx)
+		userMapping, internalMapping, removedTargets := cc.sliderMappingsForUpdate()
+
+		// adding back something that was removed earlier just lifts the removal
+		removedTargets[sliderKey] = removeTarget(removedTargets[sliderKey], target)
+
+		if !containsTarget(userMapping[sliderKey], target) && !containsTarget(internalMapping[sliderKey], target) {
+			internalMapping[sliderKey] = append(internalMapping[sliderKey], target)
+		}
+
+		return cc.saveSliderMappings(internalMapping, removedTargets)
+	})
+
+	if err != nil {
+		return fmt.Errorf("add slider target: %w", err)
+	}
+
+	cc.logger.Infow("Added slider target", "slider", sliderIdx, "target", target)
+
+	return nil
+}
+
+// RemoveSliderTarget unbinds a target from a slider of the active profile at runtime. targets that come from the user's config file
+// are remembered as removed in the internal config instead, so config.yaml itself is never rewritten
+func (cc *CanonicalConfig) RemoveSliderTarget(sliderIdx int, target string) error {
+	errNotMapped := fmt.Errorf("slider %d isn't mapped to %s", sliderIdx, target)
+
+	err := cc.updateInternalConfig(func() error {
+		sliderKey := strconv.Itoa(sliderIdx)
+		userMapping, internalMapping, removedTargets := cc.sliderMappingsForUpdate()
+
+		if !containsTarget(userMapping[sliderKey], target) && !containsTarget(internalMapping[sliderKey], target) {
+			return errNotMapped
+		}
+
+		internalMapping[sliderKey] = removeTarget(internalMapping[sliderKey], target)
+
+		if containsTarget(userMapping[sliderKey], target) && !containsTarget(removedTargets[sliderKey], target) {
+			removedTargets[sliderKey] = append(removedTargets[sliderKey], target)
+		}
+
+		return cc.saveSliderMappings(internalMapping, removedTargets)
+	})
+
+	if err == errNotMapped {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("remove slider target: %w", err)
+	}
+
+	cc.logger.Infow("Removed slider target", "slider", sliderIdx, "target", target)
+
+	return nil
+}
+
+// returns private copies of the user mapping, internal mapping and removed targets, safe to modify
+func (cc *CanonicalConfig) sliderMappingsForUpdate() (map[string][]string, map[string][]string, map[string][]string) {
+	copyMapping := func(mapping map[string][]string) map[string][]string {
+		result := make(map[string][]string, len(mapping))
+		for key, targets := range mapping {
+			result[key] = append([]string{}, targets...)
+		}
+
+		return result
+	}
+
+	snapshot := cc.snapshot()
+
+	return copyMapping(snapshot.userConfig.GetStringMapStringSlice(snapshot.profileSettingKey(configKeySliderMapping))),
+		copyMapping(snapshot.internalConfig.GetStringMapStringSlice(profileKey(snapshot.ActiveProfile, configKeySliderMapping))),
+		copyMapping(snapshot.internalConfig.GetStringMapStringSlice(profileKey(snapshot.ActiveProfile, configKeyRemovedSliderTargets)))
+}
+
+// persists the internal parts of the active profile's slider mapping and applies the merged result right away
+func (cc *CanonicalConfig) saveSliderMappings(
+	internalMapping map[string][]string,
+	removedTargets map[string][]string,
+) error {
+
+	// don't leave empty sliders lying around in the file
+	for _, mapping := range []map[string][]string{internalMapping, removedTargets} {
+		for key, targets := range mapping {
+			if len(targets) == 0 {
+				delete(mapping, key)
+			}
+		}
+	}
+
+	snapshot := cc.snapshot()
+
+	// the snapshot in use can't be touched, so the change goes into a copy of its internal config
+	internalConfig, err := copyInternalConfig(snapshot.internalConfig)
+	if err != nil {
+		return err
+	}
+
+	internalConfig.Set(profileKey(snapshot.ActiveProfile, configKeySliderMapping), internalMapping)
+	internalConfig.Set(profileKey(snapshot.ActiveProfile, configKeyRemovedSliderTargets), removedTargets)
+
+	return cc.applyInternalConfigChange(internalConfig)
+}
+
+// SetActiveProfile switches to another profile's mappings and settings. the choice is saved to the internal config,
+// and everything that depends on the mappings gets to re-resolve them without the config files being read again.
+// manual switches also become the profile that automatic switching returns to
+func (cc *CanonicalConfig) SetActiveProfile(name string, manual bool) error {
+	name = strings.ToLower(name)
+	errNoSuchProfile := fmt.Errorf("no such profile: %s", name)
+
+	err := cc.updateInternalConfig(func() error {
+		snapshot := cc.snapshot()
+
+		if !containsTarget(snapshot.Profiles, name) {
+			return errNoSuchProfile
+		}
+
+		internalConfig, err := copyInternalConfig(snapshot.internalConfig)
+		if err != nil {
+			return err
+		}
+
+		// remember where automatic switching has to return to, before it leaves there for the first time
+		if manual {
+			internalConfig.Set(configKeyManualProfile, name)
+		} else if !internalConfig.IsSet(configKeyManualProfile) {
+			internalConfig.Set(configKeyManualProfile, snapshot.ManualProfile)
+		}
+
+		internalConfig.Set(configKeyActiveProfile, name)
+
+		return cc.applyInternalConfigChange(internalConfig)
+	})
+
+	if err == errNoSuchProfile {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("set active profile: %w", err)
+	}
+
+	cc.logger.Infow("Switched profile", "profile", name, "manual", manual)
+
+	return nil
+}
+
+// NextProfile returns the name of the profile that comes after the active one, wrapping around at the end
+func (cc *CanonicalConfig) NextProfile() string {
+	snapshot := cc.snapshot()
+
+	for idx, name := range snapshot.Profiles {
+		if name == snapshot.ActiveProfile {
+			return snapshot.Profiles[(idx+1)%len(snapshot.Profiles)]
+		}
+	}
+
+	return defaultProfileName
+}
+
+// updateInternalConfig runs change with internalLock held, and lets everyone re-acquire what they need for the new
+// mapping once it's released. subscribers are free to call back into the config, and callers don't wait on them with the lock held
+func (cc *CanonicalConfig) updateInternalConfig(change func() error) error {
+	cc.internalLock.Lock()
+	err := change()
+	cc.internalLock.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	cc.onConfigReloaded()
+
+	return nil
+}
+
+// writes the changed internal viper to disk, then swaps in a snapshot built from it. if the write fails,
+// the change never reaches the snapshot in use. only call with internalLock held, through updateInternalConfig
+func (cc *CanonicalConfig) applyInternalConfigChange(internalConfig *viper.Viper) error {
+	current := cc.snapshot()
+
+	if err := cc.writeInternalConfig(internalConfig); err != nil {
+		return err
+	}
+
+	// snapshots are never modified once they're in use, so build a new one from the changed internal viper
+	snapshot, err := cc.populateFromVipers(current.userConfig, internalConfig)
+	if err != nil {
+		cc.logger.Warnw("Failed to populate config fields", "error", err)
+		return fmt.Errorf("populate config fields: %w", err)
+	}
+
+	cc.current.Store(snapshot)
+
+	return nil
+}
+
+// copyInternalConfig returns a fresh internal viper holding the same settings, for a change to go into
+func copyInternalConfig(internalConfig *viper.Viper) (*viper.Viper, error) {
+	result := newInternalConfigViper()
+
+	if err := result.MergeConfigMap(internalConfig.AllSettings()); err != nil {
+		return nil, fmt.Errorf("copy internal config: %w", err)
+	}
+
+	return result, nil
+}
+
+// writeInternalConfig atomically replaces the internal config file with the internal viper's current state
+func (cc *CanonicalConfig) writeInternalConfig(internalConfig *viper.Viper) error {
+	contents, err := yaml.Marshal(internalConfig.AllSettings())
+	if err != nil {
+		cc.logger.Warnw("Failed to serialize internal config", "error", err)
+		return fmt.Errorf("serialize internal config: %w", err)
+	}
+
+	if err := util.EnsureDirExists(internalConfigPath); err != nil {
+		cc.logger.Warnw("Failed to ensure internal config directory exists", "error", err)
+		return fmt.Errorf("ensure internal config dir exists: %w", err)
+	}
+
+	if err := util.WriteFileAtomic(internalConfigFilepath, contents, 0644); err != nil {
+		cc.logger.Warnw("Failed to write internal config", "path", internalConfigFilepath, "error", err)
+		return fmt.Errorf("write internal config: %w", err)
+	}
+
+	cc.logger.Debugw("Wrote internal config", "path", internalConfigFilepath)
+
+	return nil
+}
+
+// populateFromVipers builds a new snapshot out of the given vipers, without touching the current one
+func (cc *CanonicalConfig) populateFromVipers(userConfig *viper.Viper, internalConfig *viper.Viper) (*configSnapshot, error) {
+	s := &configSnapshot{
+		userConfig:     userConfig,
+		internalConfig: internalConfig,
+	}
+
+	s.Profiles = []string{defaultProfileName}
+	for name := range userConfig.GetStringMap(configKeyProfiles) {
+		s.Profiles = append(s.Profiles, name)
+	}
+
+	sort.Strings(s.Profiles[1:])
+
+	// the profile that was active last time, unless it has since been removed from the user config
+	s.ActiveProfile = strings.ToLower(internalConfig.GetString(configKeyActiveProfile))
+	if s.ActiveProfile == "" {
+		s.ActiveProfile = defaultProfileName
+	} else if !containsTarget(s.Profiles, s.ActiveProfile) {
+		cc.logger.Warnw("Active profile no longer exists, using default profile", "profile", s.ActiveProfile)
+		s.ActiveProfile = defaultProfileName
+	}
+
+	s.ManualProfile = strings.ToLower(internalConfig.GetString(configKeyManualProfile))
+	if !containsTarget(s.Profiles, s.ManualProfile) {
+		s.ManualProfile = s.ActiveProfile
+	}
+
+	rules := []autoProfileRule{}
+	if err := userConfig.UnmarshalKey(configKeyAutoProfiles, &rules); err != nil {
+		cc.logger.Warnw("Failed to parse automatic profile rules", "error", err)
+		return nil, fmt.Errorf("parse automatic profile rules: %w", err)
+	}
+
+	for _, rule := range rules {
+		rule.Profile = strings.ToLower(rule.Profile)
+
+		if !containsTarget(s.Profiles, rule.Profile) {
+			cc.logger.Warnw("Ignoring automatic profile rule for unknown profile", "profile", rule.Profile)
+			continue
+		}
+
+		s.AutoProfileRules = append(s.AutoProfileRules, rule)
+	}
+
+	// rules listed first win among equal priorities
+	sort.SliceStable(s.AutoProfileRules, func(i, j int) bool {
+		return s.AutoProfileRules[i].Priority > s.AutoProfileRules[j].Priority
+	})
+
+	if err := userConfig.UnmarshalKey(configKeyDucking, &s.DuckingRules); err != nil {
+		cc.logger.Warnw("Failed to parse ducking rules", "error", err)
+		return nil, fmt.Errorf("parse ducking rules: %w", err)
+	}
+
+	// unmarshalled rather than read with GetStringSlice, which would split a single sink's name on its spaces
+	if err := userConfig.UnmarshalKey(configKeyDefaultSinks, &s.DefaultSinks); err != nil {
+		cc.logger.Warnw("Failed to parse default sinks", "error", err)
+		return nil, fmt.Errorf("parse default sinks: %w", err)
+	}
+
+	if err := userConfig.UnmarshalKey(configKeyVirtualDevices, &s.VirtualDevices); err != nil {
+		cc.logger.Warnw("Failed to parse virtual devices", "error", err)
+		return nil, fmt.Errorf("parse virtual devices: %w", err)
+	}
 
 	// merge the slider mappings from the user and internal configs
-	cc.SliderMapping = sliderMapFromConfigs(
-		cc.userConfig.GetStringMapStringSlice(configKeySliderMapping),
-		cc.internalConfig.GetStringMapStringSlice(configKeySliderMapping),
+	s.SliderMapping = sliderMapFromConfigs(
+		userConfig.GetStringMapStringSlice(s.profileSettingKey(configKeySliderMapping)),
+		internalConfig.GetStringMapStringSlice(profileKey(s.ActiveProfile, configKeySliderMapping)),
+		internalConfig.GetStringMapStringSlice(profileKey(s.ActiveProfile, configKeyRemovedSliderTargets)),
 	)
 
+	s.ButtonMapping = userConfig.GetStringMapStringSlice(s.profileSettingKey(configKeyButtonMapping))
+
+	// layers come as a whole, a profile that defines its own replaces all of them
+	layersKey := s.profileSettingKey(configKeyLayers)
+	s.Layers = map[string]*sliderMap{}
+
+	for name := range userConfig.GetStringMap(layersKey) {
+		layerMapping := userConfig.GetStringMapStringSlice(strings.Join([]string{layersKey, name, configKeySliderMapping}, "."))
+		s.Layers[name] = sliderMapFromConfigs(layerMapping, nil, nil)
+	}
+
+	sliderSettingsEntries := map[string]sliderSettingsEntry{}
+	if err := userConfig.UnmarshalKey(s.profileSettingKey(configKeySliderSettings), &sliderSettingsEntries); err != nil {
+		cc.logger.Warnw("Failed to parse slider settings", "error", err)
+		return nil, fmt.Errorf("parse slider settings: %w", err)
+	}
+
+	sliderSettings, err := parseSliderSettings(sliderSettingsEntries)
+	if err != nil {
+		cc.logger.Warnw("Failed to parse slider settings", "error", err)
+		return nil, fmt.Errorf("parse slider settings: %w", err)
+	}
+
+	s.SliderSettings = sliderSettings
+
 	// Get HID Config
-	cc.EnableHidListen = cc.userConfig.GetBool(configKeyEnableHID)
+	s.EnableHidListen = userConfig.GetBool(configKeyEnableHID)
 
-	cc.HidConnectionInfo.ProductId = uint16(cc.userConfig.GetUint32(configKeyProductId))
-	cc.HidConnectionInfo.VendorId = uint16(cc.userConfig.GetUint32(configKeyVendorId))
-	cc.HidConnectionInfo.UsagePage = uint16(cc.userConfig.GetUint32(configKeyUsagePage))
-	cc.HidConnectionInfo.Usage = uint16(cc.userConfig.GetUint32(configKeyUsage))
+	s.EnableLevelMeter = userConfig.GetBool(configKeyEnableLevelMeter)
+	s.SendLevelsToDevice = userConfig.GetBool(configKeySendLevelsToDevice)
+
+	s.HidConnectionInfo.ProductId = uint16(userConfig.GetUint32(configKeyProductId))
+	s.HidConnectionInfo.VendorId = uint16(userConfig.GetUint32(configKeyVendorId))
+	s.HidConnectionInfo.UsagePage = uint16(userConfig.GetUint32(configKeyUsagePage))
+	s.HidConnectionInfo.Usage = uint16(userConfig.GetUint32(configKeyUsage))
 
 	// get the rest of the config fields - viper saves us a lot of effort here
-	cc.SerialConnectionInfo.COMPort = cc.userConfig.GetString(configKeyCOMPort)
+	s.SerialConnectionInfo.COMPort = userConfig.GetString(configKeyCOMPort)
 
-	cc.SerialConnectionInfo.BaudRate = cc.userConfig.GetInt(configKeyBaudRate)
-	if cc.SerialConnectionInfo.BaudRate <= 0 && cc.EnableHidListen == false {
+	s.SerialConnectionInfo.BaudRate = userConfig.GetInt(configKeyBaudRate)
+	if s.SerialConnectionInfo.BaudRate <= 0 && s.EnableHidListen == false {
 		cc.logger.Warnw("Invalid baud rate specified, using default value",
 			"key", configKeyBaudRate,
-			"invalidValue", cc.SerialConnectionInfo.BaudRate,
+			"invalidValue", s.SerialConnectionInfo.BaudRate,
 			"defaultValue", defaultBaudRate)
 
-		cc.SerialConnectionInfo.BaudRate = defaultBaudRate
+		s.SerialConnectionInfo.BaudRate = defaultBaudRate
 	}
 
-	cc.InvertSliders = cc.userConfig.GetBool(configKeyInvertSliders)
-	cc.NoiseReductionLevel = cc.userConfig.GetString(configKeyNoiseReductionLevel)
+	s.InvertSliders = userConfig.GetBool(s.profileSettingKey(configKeyInvertSliders))
+	s.NoiseReductionLevel = userConfig.GetString(s.profileSettingKey(configKeyNoiseReductionLevel))
+
+	s.ReeemiksMatching = userConfig.GetString(configReeemiksMatching)
+	s.AudioBackend = strings.ToLower(userConfig.GetString(configKeyAudioBackend))
+
+	// a single server can be given as pulse_server and pulse_cookie, several need a namespace each
+	s.PulseServers = []pulseServer{{
+		Address: userConfig.GetString(configKeyPulseServer),
+		Cookie:  userConfig.GetString(configKeyPulseCookie),
+	}}
+
+	if userConfig.IsSet(configKeyPulseServers) {
+		servers := []pulseServer{}
+
+		if err := userConfig.UnmarshalKey(configKeyPulseServers, &servers); err != nil {
+			cc.logger.Warnw("Failed to parse PulseAudio servers", "error", err)
+			return nil, fmt.Errorf("parse PulseAudio servers: %w", err)
+		}
 
-	cc.ReeemiksMatching = cc.userConfig.GetString(configReeemiksMatching)
+		for idx := range servers {
+			servers[idx].Namespace = strings.ToLower(servers[idx].Namespace)
+		}
+
+		if len(servers) > 0 {
+			s.PulseServers = servers
+		}
+	}
 
 	cc.logger.Debug("Populated config fields from vipers")
 
-	return nil
+	return s, nil
+}
+
+// profileSettingKey returns where the active profile's value for a setting lives in the user config.
+// profiles only need to list what they change, everything else comes from the top level
+func (s *configSnapshot) profileSettingKey(key string) string {
+	if overrideKey := profileKey(s.ActiveProfile, key); s.userConfig.IsSet(overrideKey) {
+		return overrideKey
+	}
+
+	return key
+}
+
+// profileKey returns the key a profile's own value for a setting is stored under
+func profileKey(profile string, key string) string {
+	if profile == defaultProfileName {
+		return key
+	}
+
+	return strings.Join([]string{configKeyProfiles, profile, key}, ".")
 }
 
 func (cc *CanonicalConfig) onConfigReloaded() {
@@ -0,0 +1,181 @@
+package reeemiks
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// useTempInternalConfig points the internal config at a fresh directory for the rest of the test
+func useTempInternalConfig(t *testing.T) string {
+	t.Helper()
+
+	previousPath, previousFilepath := internalConfigPath, internalConfigFilepath
+
+	internalConfigPath = t.TempDir()
+	internalConfigFilepath = filepath.Join(internalConfigPath, internalConfigName+"."+configType)
+
+	t.Cleanup(func() {
+		internalConfigPath, internalConfigFilepath = previousPath, previousFilepath
+	})
+
+	return internalConfigFilepath
+}
+
+func newTestConfig(t *testing.T) *CanonicalConfig {
+	t.Helper()
+
+	cc, err := NewConfig(zap.NewNop().Sugar(), nopNotifier{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cc
+}
+
+func TestRuntimeChangeNotifiesWithoutLock(t *testing.T) {
+	useTempInternalConfig(t)
+	cc := newTestConfig(t)
+
+	changes := cc.SubscribeToChanges()
+	slowChanges := cc.SubscribeToChanges()
+	lockFree := make(chan bool, 1)
+
+	// the notification is still on its way to a slow subscriber when the first one is done with it
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		<-slowChanges
+	}()
+
+	// subscribers get to call back into the config while they handle the change
+	go func() {
+		<-changes
+
+		locked := cc.internalLock.TryLock()
+		if locked {
+			cc.internalLock.Unlock()
+		}
+
+		lockFree <- locked
+	}()
+
+	if err := cc.AddSliderTarget(1, "spotify"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case free := <-lockFree:
+		if !free {
+			t.Error("expected the config lock to be released before subscribers were notified")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected subscribers to hear about the change")
+	}
+
+	if targets, _ := cc.snapshot().SliderMapping.get(1); !containsTarget(targets, "spotify") {
+		t.Errorf("expected slider 1 to be mapped to spotify, got %v", targets)
+	}
+}
+
+func TestFailedSliderTargetChangeLeavesConfigAlone(t *testing.T) {
+	path := useTempInternalConfig(t)
+	cc := newTestConfig(t)
+
+	if err := cc.AddSliderTarget(1, "spotify"); err != nil {
+		t.Fatal(err)
+	}
+
+	before := cc.snapshot()
+
+	// the internal config file standing where its directory should be makes the next write fail
+	internalConfigPath = filepath.Join(path, "blocked")
+	internalConfigFilepath = filepath.Join(internalConfigPath, internalConfigName+"."+configType)
+
+	if err := cc.AddSliderTarget(1, "discord"); err == nil {
+		t.Fatal("expected the change to fail when the internal config can't be written")
+	}
+
+	if cc.snapshot() != before {
+		t.Error("expected the snapshot in use to stay after a failed change")
+	}
+
+	if targets, _ := before.SliderMapping.get(1); containsTarget(targets, "discord") {
+		t.Errorf("expected the rejected target to be left out, got %v", targets)
+	}
+
+	if containsTarget(before.internalConfig.GetStringMapStringSlice(configKeySliderMapping)["1"], "discord") {
+		t.Error("expected the internal config in use not to pick up the rejected target")
+	}
+
+	// the next change that goes through doesn't bring the rejected one along
+	internalConfigPath = filepath.Dir(path)
+	internalConfigFilepath = path
+
+	if err := cc.AddSliderTarget(2, "firefox"); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(contents), "discord") || !strings.Contains(string(contents), "spotify") {
+		t.Errorf("expected only the targets that were saved in the internal config, got:\n%s", contents)
+	}
+}
+
+func TestFailedProfileSwitchLeavesConfigAlone(t *testing.T) {
+	path := useTempInternalConfig(t)
+	cc := newTestConfig(t)
+
+	userConfig := newUserConfigViper()
+	if err := userConfig.ReadConfig(strings.NewReader("profiles:\n  gaming:\n    invert_sliders: true\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := cc.populateFromVipers(userConfig, newInternalConfigViper())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cc.current.Store(snapshot)
+
+	internalConfigPath = filepath.Join(t.TempDir(), "blocked")
+	internalConfigFilepath = filepath.Join(internalConfigPath, internalConfigName+"."+configType)
+
+	if err := os.WriteFile(internalConfigPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cc.SetActiveProfile("gaming", true); err == nil {
+		t.Fatal("expected the switch to fail when the internal config can't be written")
+	}
+
+	if cc.snapshot() != snapshot || snapshot.internalConfig.IsSet(configKeyActiveProfile) {
+		t.Error("expected the config in use not to pick up the rejected profile")
+	}
+
+	internalConfigPath = filepath.Dir(path)
+	internalConfigFilepath = path
+
+	if err := cc.AddSliderTarget(1, "spotify"); err != nil {
+		t.Fatal(err)
+	}
+
+	if cc.snapshot().ActiveProfile != defaultProfileName {
+		t.Errorf("expected to stay on the default profile, got %s", cc.snapshot().ActiveProfile)
+	}
+
+	if err := cc.SetActiveProfile("gaming", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if current := cc.snapshot(); current.ActiveProfile != "gaming" || !current.InvertSliders {
+		t.Errorf("expected the gaming profile to be in effect, got %s", current.ActiveProfile)
+	}
+}
@@ -0,0 +1,540 @@
+package reeemiks
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configProblem is a single issue found while validating the user's config file
+type configProblem struct {
+	path    string
+	line    int
+	message string
+}
+
+// configValidationError carries every problem found in the config file, not just the first one
+type configValidationError struct {
+	problems []configProblem
+}
+
+// validates a single config value, reporting any problems to the validator
+type configValueValidator func(v *configValidator, path string, node *yaml.Node)
+
+type configValidator struct {
+	problems []configProblem
+}
+
+const (
+	noiseReductionLow     = "low"
+	noiseReductionDefault = "default"
+	noiseReductionHigh    = "high"
+)
+
+// yaml syntax errors carry their line number inside the message, e.g. "yaml: line 12: did not find expected key"
+var yamlErrorLinePattern = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// every key reeemiks understands in config.yaml, and how to check its value.
+// keys are compared case-insensitively, just like viper does
+var userConfigSchema = map[string]configValueValidator{
+	configKeySliderMapping:       validateSliderMapping,
+	configKeyButtonMapping:       validateButtonMapping,
+	configKeyInvertSliders:       validateBool,
+	configKeyCOMPort:             validateString,
+	configKeyBaudRate:            validateInt(1, 4000000),
+	configKeyNoiseReductionLevel: validateOneOf(noiseReductionLow, noiseReductionDefault, noiseReductionHigh),
+	configKeyVendorId:            validateInt(0, 0xFFFF),
+	configKeyProductId:           validateInt(0, 0xFFFF),
+	configKeyUsagePage:           validateInt(0, 0xFFFF),
+	configKeyUsage:               validateInt(0, 0xFFFF),
+	configKeyEnableHID:           validateBool,
+	configKeyEnableLevelMeter:    validateBool,
+	configKeySendLevelsToDevice:  validateBool,
+	configKeyAudioBackend:        validateOneOf(audioBackendPulse, audioBackendPipeWire, audioBackendALSA),
+	configKeyPulseServer:         validateString,
+	configKeyPulseCookie:         validateString,
+	"reeemiks": validateSection(map[string]configValueValidator{
+		"matching": validateString,
+	}),
+	configKeyProfiles:       validateProfiles,
+	configKeyAutoProfiles:   validateList(validateAutoProfileRule),
+	configKeyLayers:         validateLayers,
+	configKeySliderSettings: validateSliderSettings,
+	configKeyDucking:        validateList(validateDuckingRule),
+	configKeyDefaultSinks:   validateStringList,
+	configKeyVirtualDevices: validateList(validateVirtualDevice),
+	configKeyPulseServers:   validatePulseServers,
+}
+
+// the settings a profile can override, everything else is shared by all profiles
+var profileSchema = map[string]configValueValidator{
+	configKeySliderMapping:       validateSliderMapping,
+	configKeyButtonMapping:       validateButtonMapping,
+	configKeyInvertSliders:       validateBool,
+	configKeyNoiseReductionLevel: validateOneOf(noiseReductionLow, noiseReductionDefault, noiseReductionHigh),
+	configKeyLayers:              validateLayers,
+	configKeySliderSettings:      validateSliderSettings,
+}
+
+// the settings every entry under slider_settings can have
+var sliderSettingsSchema = map[string]configValueValidator{
+	"pickup":            validateBool,
+	"pickup_tolerance":  validateNumber(0, 100),
+	"step":              validateNumber(0.01, 100),
+	"acceleration":      validateNumber(1, 20),
+	"step_each_session": validateBool,
+	"group_mode":        validateOneOf(groupModeAbsolute, groupModeRelative),
+	"ramp":              validateNumber(0, 10000),
+}
+
+func (p configProblem) String() string {
+	location := p.path
+	if p.line > 0 && p.path == "" {
+		location = fmt.Sprintf("line %d", p.line)
+	} else if p.line > 0 {
+		location = fmt.Sprintf("%s (line %d)", p.path, p.line)
+	}
+
+	if location == "" {
+		return p.message
+	}
+
+	return fmt.Sprintf("%s: %s", location, p.message)
+}
+
+func (e *configValidationError) Error() string {
+	descriptions := make([]string, len(e.problems))
+	for idx, problem := range e.problems {
+		descriptions[idx] = problem.String()
+	}
+
+	return fmt.Sprintf("invalid config (%d problems): %s", len(e.problems), strings.Join(descriptions, "; "))
+}
+
+// validateUserConfig checks the raw contents of config.yaml and returns every problem it finds
+func validateUserConfig(contents []byte) []configProblem {
+	v := &configValidator{}
+
+	root := yaml.Node{}
+	if err := yaml.Unmarshal(contents, &root); err != nil {
+		problem := configProblem{message: strings.TrimPrefix(err.Error(), "yaml: ")}
+
+		if match := yamlErrorLinePattern.FindStringSubmatch(err.Error()); match != nil {
+			problem.line, _ = strconv.Atoi(match[1])
+			problem.message = strings.TrimPrefix(err.Error(), match[0])
+		}
+
+		return []configProblem{problem}
+	}
+
+	// an empty file is fine, everything just takes its default value
+	if len(root.Content) == 0 {
+		return nil
+	}
+
+	validateSection(userConfigSchema)(v, "", root.Content[0])
+
+	return v.problems
+}
+
+func (v *configValidator) report(node *yaml.Node, path string, format string, args ...interface{}) {
+	v.problems = append(v.problems, configProblem{
+		path:    path,
+		line:    node.Line,
+		message: fmt.Sprintf(format, args...),
+	})
+}
+
+func joinConfigPath(parent string, key string) string {
+	if parent == "" {
+		return key
+	}
+
+	return parent + "." + key
+}
+
+func validateSection(schema map[string]configValueValidator) configValueValidator {
+	return func(v *configValidator, path string, node *yaml.Node) {
+		if node.Kind != yaml.MappingNode {
+			v.report(node, path, "expected a section of settings")
+			return
+		}
+
+		// mapping nodes alternate between keys and values
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			keyNode, valueNode := node.Content[idx], node.Content[idx+1]
+			keyPath := joinConfigPath(path, keyNode.Value)
+
+			validator, ok := schema[strings.ToLower(keyNode.Value)]
+			if !ok {
+				v.report(keyNode, keyPath, "unknown setting")
+				continue
+			}
+
+			validator(v, keyPath, valueNode)
+		}
+	}
+}
+
+func validateBool(v *configValidator, path string, node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!bool" {
+		return
+	}
+
+	// viper parses yaml 1.1, which also takes yes/no and on/off as booleans
+	if node.Kind == yaml.ScalarNode && node.Style == 0 {
+		switch strings.ToLower(node.Value) {
+		case "yes", "no", "on", "off", "y", "n":
+			return
+		}
+	}
+
+	v.report(node, path, "expected true or false, got %q", node.Value)
+}
+
+func validateString(v *configValidator, path string, node *yaml.Node) {
+	if node.Kind != yaml.ScalarNode || node.ShortTag() == "!!null" {
+		v.report(node, path, "expected a single value")
+	}
+}
+
+func validateInt(min int64, max int64) configValueValidator {
+	return func(v *configValidator, path string, node *yaml.Node) {
+		if node.Kind != yaml.ScalarNode {
+			v.report(node, path, "expected a number")
+			return
+		}
+
+		value, err := strconv.ParseInt(node.Value, 0, 64)
+		if err != nil {
+			v.report(node, path, "expected a whole number, got %q", node.Value)
+			return
+		}
+
+		if value < min || value > max {
+			v.report(node, path, "%d is out of range (%d to %d)", value, min, max)
+		}
+	}
+}
+
+func validateNumber(min float64, max float64) configValueValidator {
+	return func(v *configValidator, path string, node *yaml.Node) {
+		if node.Kind != yaml.ScalarNode {
+			v.report(node, path, "expected a number")
+			return
+		}
+
+		value, err := strconv.ParseFloat(node.Value, 64)
+		if err != nil {
+			v.report(node, path, "expected a number, got %q", node.Value)
+			return
+		}
+
+		if value < min || value > max {
+			v.report(node, path, "%g is out of range (%g to %g)", value, min, max)
+		}
+	}
+}
+
+func validateOneOf(allowed ...string) configValueValidator {
+	return func(v *configValidator, path string, node *yaml.Node) {
+		if node.Kind == yaml.ScalarNode {
+			for _, value := range allowed {
+				if node.Value == value {
+					return
+				}
+			}
+		}
+
+		v.report(node, path, "expected one of %s, got %q", strings.Join(allowed, ", "), node.Value)
+	}
+}
+
+// validates a mapping of zero-based indexes to one or more values, calling validateEntry for every value
+func validateIndexedMapping(validateEntry func(v *configValidator, path string, values []*yaml.Node)) configValueValidator {
+	return func(v *configValidator, path string, node *yaml.Node) {
+		if node.Kind != yaml.MappingNode {
+			v.report(node, path, "expected a list of indexes, starting at 0")
+			return
+		}
+
+	indexes:
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			keyNode, valueNode := node.Content[idx], node.Content[idx+1]
+			keyPath := joinConfigPath(path, keyNode.Value)
+
+			if index, err := strconv.Atoi(keyNode.Value); err != nil || index < 0 {
+				v.report(keyNode, keyPath, "index must be a whole number, starting at 0")
+				continue
+			}
+
+			switch valueNode.Kind {
+			case yaml.ScalarNode:
+
+				// an index with nothing after it is the same as not having it at all
+				if valueNode.ShortTag() == "!!null" {
+					continue
+				}
+
+				validateEntry(v, keyPath, []*yaml.Node{valueNode})
+
+			case yaml.SequenceNode:
+				for _, entryNode := range valueNode.Content {
+					if entryNode.Kind != yaml.ScalarNode {
+						v.report(entryNode, keyPath, "expected a single value per list entry")
+						continue indexes
+					}
+				}
+
+				validateEntry(v, keyPath, valueNode.Content)
+
+			default:
+				v.report(valueNode, keyPath, "expected a single value or a list of values")
+			}
+		}
+	}
+}
+
+var validateSliderMapping = validateIndexedMapping(func(v *configValidator, path string, targets []*yaml.Node) {
+	for _, targetNode := range targets {
+		if strings.TrimSpace(targetNode.Value) == "" {
+			v.report(targetNode, path, "empty target")
+		}
+	}
+})
+
+func validateProfiles(v *configValidator, path string, node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		v.report(node, path, "expected a list of named profiles")
+		return
+	}
+
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		keyNode, valueNode := node.Content[idx], node.Content[idx+1]
+		keyPath := joinConfigPath(path, keyNode.Value)
+
+		switch {
+		case strings.TrimSpace(keyNode.Value) == "" || strings.Contains(keyNode.Value, "."):
+			v.report(keyNode, keyPath, "profile names can't be empty or contain dots")
+		case strings.EqualFold(keyNode.Value, defaultProfileName):
+			v.report(keyNode, keyPath, "%q is reserved for the top-level settings", defaultProfileName)
+		case strings.EqualFold(keyNode.Value, profileNext) || strings.EqualFold(keyNode.Value, profileAuto):
+			v.report(keyNode, keyPath, "%q is reserved for switching profiles", keyNode.Value)
+		default:
+			validateSection(profileSchema)(v, keyPath, valueNode)
+		}
+	}
+}
+
+func validateLayers(v *configValidator, path string, node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		v.report(node, path, "expected a list of named layers")
+		return
+	}
+
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		keyNode, valueNode := node.Content[idx], node.Content[idx+1]
+		keyPath := joinConfigPath(path, keyNode.Value)
+
+		if strings.TrimSpace(keyNode.Value) == "" || strings.Contains(keyNode.Value, ".") {
+			v.report(keyNode, keyPath, "layer names can't be empty or contain dots")
+			continue
+		}
+
+		validateSection(map[string]configValueValidator{
+			configKeySliderMapping: validateSliderMapping,
+		})(v, keyPath, valueNode)
+	}
+}
+
+func validateSliderSettings(v *configValidator, path string, node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		v.report(node, path, "expected a list of slider indexes, starting at 0")
+		return
+	}
+
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		keyNode, valueNode := node.Content[idx], node.Content[idx+1]
+		keyPath := joinConfigPath(path, keyNode.Value)
+
+		if index, err := strconv.Atoi(keyNode.Value); err != nil || index < 0 {
+			v.report(keyNode, keyPath, "index must be a whole number, starting at 0")
+			continue
+		}
+
+		validateSection(sliderSettingsSchema)(v, keyPath, valueNode)
+	}
+}
+
+func validateList(validateEntry configValueValidator) configValueValidator {
+	return func(v *configValidator, path string, node *yaml.Node) {
+		if node.Kind != yaml.SequenceNode {
+			v.report(node, path, "expected a list")
+			return
+		}
+
+		for idx, entryNode := range node.Content {
+			validateEntry(v, fmt.Sprintf("%s[%d]", path, idx), entryNode)
+		}
+	}
+}
+
+// validates a single value, or a list of them
+func validateStringList(v *configValidator, path string, node *yaml.Node) {
+	if node.Kind == yaml.SequenceNode {
+		for _, entryNode := range node.Content {
+			validateString(v, path, entryNode)
+		}
+
+		return
+	}
+
+	validateString(v, path, node)
+}
+
+func validateAutoProfileRule(v *configValidator, path string, node *yaml.Node) {
+	validateSection(map[string]configValueValidator{
+		"profile":  validateString,
+		"apps":     validateStringList,
+		"priority": validateInt(math.MinInt32, math.MaxInt32),
+	})(v, path, node)
+
+	// a rule without either of these can never do anything
+	validateRequired(v, path, node, "profile", "apps")
+}
+
+// reports every one of the given settings that's missing from a section
+func validateRequired(v *configValidator, path string, node *yaml.Node, keys ...string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for _, required := range keys {
+		found := false
+
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			if strings.EqualFold(node.Content[idx].Value, required) {
+				found = true
+			}
+		}
+
+		if !found {
+			v.report(node, path, "missing %s", required)
+		}
+	}
+}
+
+func validateDuckingRule(v *configValidator, path string, node *yaml.Node) {
+	validateSection(map[string]configValueValidator{
+		"when":    validateStringList,
+		"sliders": validateIntList(0, math.MaxInt32),
+		"amount":  validateNumber(0, 100),
+		"fade":    validateNumber(0, 10000),
+	})(v, path, node)
+
+	validateRequired(v, path, node, "when", "sliders", "amount")
+}
+
+func validateVirtualDevice(v *configValidator, path string, node *yaml.Node) {
+	validateSection(map[string]configValueValidator{
+		"name":        validateSinkName,
+		"description": validateString,
+		"output":      validateSinkName,
+	})(v, path, node)
+
+	validateRequired(v, path, node, "name")
+}
+
+// sink names go into module arguments as they are, so they can't have spaces or quotes
+func validateSinkName(v *configValidator, path string, node *yaml.Node) {
+	if node.Kind != yaml.ScalarNode || !virtualDeviceNamePattern.MatchString(node.Value) {
+		v.report(node, path, "expected a sink name made of letters, digits, dots, dashes and underscores, got %q", node.Value)
+	}
+}
+
+var pulseServerNamespacePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+func validatePulseServers(v *configValidator, path string, node *yaml.Node) {
+	validateList(validateSection(map[string]configValueValidator{
+		"address":   validateString,
+		"cookie":    validateString,
+		"namespace": validateString,
+	}))(v, path, node)
+
+	if node.Kind != yaml.SequenceNode {
+		return
+	}
+
+	namespaces := map[string]bool{}
+
+	for idx, serverNode := range node.Content {
+		serverPath := fmt.Sprintf("%s[%d]", path, idx)
+
+		// the first server's sessions keep their names, the others need something to set them apart
+		if idx > 0 {
+			validateRequired(v, serverPath, serverNode, "namespace")
+		}
+
+		if serverNode.Kind != yaml.MappingNode {
+			continue
+		}
+
+		for keyIdx := 0; keyIdx+1 < len(serverNode.Content); keyIdx += 2 {
+			if !strings.EqualFold(serverNode.Content[keyIdx].Value, "namespace") {
+				continue
+			}
+
+			valueNode := serverNode.Content[keyIdx+1]
+			namespace := strings.ToLower(valueNode.Value)
+
+			if !pulseServerNamespacePattern.MatchString(namespace) {
+				v.report(valueNode, joinConfigPath(serverPath, "namespace"), "expected letters, digits, dots, dashes and underscores, got %q", valueNode.Value)
+			} else if namespaces[namespace] {
+				v.report(valueNode, joinConfigPath(serverPath, "namespace"), "namespace %q is already used by another server", valueNode.Value)
+			}
+
+			namespaces[namespace] = true
+		}
+	}
+}
+
+// validates a single whole number, or a list of them
+func validateIntList(min int64, max int64) configValueValidator {
+	validateEntry := validateInt(min, max)
+
+	return func(v *configValidator, path string, node *yaml.Node) {
+		if node.Kind == yaml.SequenceNode {
+			for _, entryNode := range node.Content {
+				validateEntry(v, path, entryNode)
+			}
+
+			return
+		}
+
+		validateEntry(v, path, node)
+	}
+}
+
+var validateButtonMapping = validateIndexedMapping(func(v *configValidator, path string, values []*yaml.Node) {
+	if len(values) == 0 {
+		v.report(&yaml.Node{}, path, "no key or button action given")
+		return
+	}
+
+	requiredArgs, ok := buttonActionArgs[strings.ToLower(values[0].Value)]
+	if !ok {
+		if _, err := parseKeyChord(values[0].Value); err != nil {
+			v.report(values[0], path, "expected a key code, key names or a button action, got %q (%v)", values[0].Value, err)
+		}
+
+		return
+	}
+
+	if len(values)-1 < requiredArgs {
+		v.report(values[0], path, "button action %s needs %d argument(s)", values[0].Value, requiredArgs)
+	}
+})
@@ -0,0 +1,135 @@
+package reeemiks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateUserConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+
+		// the key path of every problem expected, in the order they're reported
+		paths []string
+	}{
+		{
+			name:   "empty file",
+			config: "",
+		},
+		{
+			name: "valid config",
+			config: `
+slider_mapping:
+  0: master
+  1:
+    - chrome.exe
+    - firefox.exe
+  2:
+button_mapping:
+  0: playpause
+  1: ctrl+shift+m
+invert_sliders: false
+com_port: COM4
+baud_rate: 9600
+noise_reduction: default
+enable_hid_listen: yes
+`,
+		},
+		{
+			name:   "syntax error",
+			config: "slider_mapping:\n  0: master\n    1: mic\n",
+			paths:  []string{""},
+		},
+		{
+			name:   "unknown setting",
+			config: "slider_maping:\n  0: master\n",
+			paths:  []string{"slider_maping"},
+		},
+		{
+			name:   "settings are case-insensitive",
+			config: "Invert_Sliders: true\n",
+		},
+		{
+			name:   "wrong types and ranges",
+			config: "invert_sliders: maybe\nbaud_rate: 0\nvendor_id: 0x10000\nnoise_reduction: loud\n",
+			paths:  []string{"invert_sliders", "baud_rate", "vendor_id", "noise_reduction"},
+		},
+		{
+			name:   "bad slider indexes",
+			config: "slider_mapping:\n  -1: master\n  one: mic\n  2: spotify\n",
+			paths:  []string{"slider_mapping.-1", "slider_mapping.one"},
+		},
+		{
+			name:   "empty slider target",
+			config: "slider_mapping:\n  0: ''\n",
+			paths:  []string{"slider_mapping.0"},
+		},
+		{
+			name: "a nested list entry doesn't hide later sliders",
+			config: `
+slider_mapping:
+  0:
+    - [master, mic]
+  1: ''
+  2:
+    - ''
+`,
+			paths: []string{"slider_mapping.0", "slider_mapping.1", "slider_mapping.2"},
+		},
+		{
+			name:   "slider mapping that isn't a mapping",
+			config: "slider_mapping:\n  - master\n",
+			paths:  []string{"slider_mapping"},
+		},
+		{
+			name:   "unknown key name",
+			config: "button_mapping:\n  0: ctrl+nothing\n  1: F13\n",
+			paths:  []string{"button_mapping.0"},
+		},
+		{
+			name:   "missing button action argument",
+			config: "button_mapping:\n  0: profile\n",
+			paths:  []string{"button_mapping.0"},
+		},
+		{
+			name:   "reserved profile names",
+			config: "profiles:\n  default:\n    invert_sliders: true\n  gaming:\n    com_port: COM3\n",
+			paths:  []string{"profiles.default", "profiles.gaming.com_port"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			paths := []string{}
+			for _, problem := range validateUserConfig([]byte(test.config)) {
+				paths = append(paths, problem.path)
+			}
+
+			expected := test.paths
+			if expected == nil {
+				expected = []string{}
+			}
+
+			if !reflect.DeepEqual(paths, expected) {
+				t.Errorf("expected problems at %q, got %q", expected, paths)
+			}
+		})
+	}
+}
+
+func TestValidateUserConfigLines(t *testing.T) {
+	problems := validateUserConfig([]byte("com_port: COM4\n\nbaud_rate: fast\n"))
+	if len(problems) != 1 {
+		t.Fatalf("expected a single problem, got %v", problems)
+	}
+
+	if problems[0].line != 3 {
+		t.Errorf("expected the problem on line 3, got line %d", problems[0].line)
+	}
+
+	problems = validateUserConfig([]byte("slider_mapping:\n  0: master\n    1: mic\n"))
+	if len(problems) != 1 || problems[0].line != 3 {
+		t.Errorf("expected a syntax error on line 3, got %v", problems)
+	}
+}
@@ -0,0 +1,634 @@
+package reeemiks
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Red-M/ReeeMiks/pkg/reeemiks/util"
+)
+
+// controlServer exposes a running reeemiks instance to `reeemiks ctl` over a local socket.
+// every connection carries a single JSON request line, answered by a single JSON response line
+// (except for watch, which keeps streaming events until the client goes away)
+type controlServer struct {
+	reeemiks *Reeemiks
+	logger   *zap.SugaredLogger
+
+	listener net.Listener
+
+	watchers     map[chan controlEvent]bool
+	watchersLock sync.Mutex
+}
+
+type controlRequest struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+type controlResponse struct {
+	Error string          `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// controlEvent is a single live event, as streamed to watching clients
+type controlEvent struct {
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+type controlSessionInfo struct {
+	Key     string  `json:"key"`
+	Volume  float32 `json:"volume"`
+	Muted   bool    `json:"muted"`
+	Sliders []int   `json:"sliders,omitempty"`
+
+	// where the session comes from, for process:, scope: and flatpak: targets
+	PID     int    `json:"pid,omitempty"`
+	Parent  string `json:"parent,omitempty"`
+	Scope   string `json:"scope,omitempty"`
+	Flatpak string `json:"flatpak,omitempty"`
+}
+
+type controlLearnResult struct {
+	Slider     int      `json:"slider"`
+	Candidates []string `json:"candidates"`
+}
+
+type controlProfiles struct {
+	Active    string   `json:"active"`
+	Profiles  []string `json:"profiles"`
+	Automatic bool     `json:"automatic"`
+}
+
+type controlSinks struct {
+	Default string   `json:"default"`
+	Sinks   []string `json:"sinks"`
+}
+
+type controlStatus struct {
+	Version       string `json:"version,omitempty"`
+	Profile       string `json:"profile"`
+	Layer         string `json:"layer,omitempty"`
+	Connected     bool   `json:"connected"`
+	Port          string `json:"port"`
+	NumSliders    int    `json:"numSliders"`
+	NumButtons    int    `json:"numButtons"`
+	MappedSliders int    `json:"mappedSliders"`
+	NumSessions   int    `json:"numSessions"`
+	ConfigPath    string `json:"configPath"`
+}
+
+const (
+	controlSocketFilename = "reeemiks.sock"
+
+	controlCommandListSessions = "list-sessions"
+	controlCommandSet          = "set"
+	controlCommandGet          = "get"
+	controlCommandMute         = "mute"
+	controlCommandReload       = "reload"
+	controlCommandStatus       = "status"
+	controlCommandWatch        = "watch"
+	controlCommandLearn        = "learn"
+	controlCommandBind         = "bind"
+	controlCommandUnbind       = "unbind"
+	controlCommandProfile      = "profile"
+	controlCommandLayer        = "layer"
+	controlCommandMove         = "move"
+	controlCommandSink         = "sink"
+
+	controlEventSliderMove      = "slider"
+	controlEventButton          = "button"
+	controlEventConfigReload    = "config_reload"
+	controlEventSessionsRefresh = "sessions_refresh"
+	controlEventProfile         = "profile"
+	controlEventLayer           = "layer"
+	controlEventLevels          = "levels"
+
+	// watchers that can't keep up simply miss events, we never block the run loop on them
+	controlWatcherBufferSize = 64
+)
+
+// the runtime dir is the natural home for sockets on linux, other platforms get the config dir
+var controlSocketPath = func() string {
+	if runtimeDir, ok := os.LookupEnv("XDG_RUNTIME_DIR"); ok && util.Linux() {
+		return filepath.Join(runtimeDir, controlSocketFilename)
+	}
+
+	return filepath.Join(userConfigPath, controlSocketFilename)
+}()
+
+func newControlServer(reeemiks *Reeemiks, logger *zap.SugaredLogger) *controlServer {
+	logger = logger.Named("control")
+
+	cs := &controlServer{
+		reeemiks: reeemiks,
+		logger:   logger,
+		watchers: make(map[chan controlEvent]bool),
+	}
+
+	logger.Debug("Created control server instance")
+
+	return cs
+}
+
+// start subscribes to everything worth watching and begins accepting ctl connections
+func (cs *controlServer) start() error {
+	cs.setupEventForwarding()
+
+	// a socket left behind by a crashed instance would make listening fail, so clean it up.
+	// if something actually answers on it, another instance is running and owns it
+	if conn, err := net.DialTimeout("unix", controlSocketPath, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("control socket already in use: %s", controlSocketPath)
+	}
+
+	if err := os.Remove(controlSocketPath); err != nil && !os.IsNotExist(err) {
+		cs.logger.Warnw("Failed to remove stale control socket", "path", controlSocketPath, "error", err)
+	}
+
+	listener, err := net.Listen("unix", controlSocketPath)
+	if err != nil {
+		cs.logger.Warnw("Failed to listen on control socket", "path", controlSocketPath, "error", err)
+		return fmt.Errorf("listen on control socket: %w", err)
+	}
+
+	cs.listener = listener
+	cs.logger.Debugw("Listening for control connections", "path", controlSocketPath)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					cs.logger.Warnw("Failed to accept control connection", "error", err)
+				}
+
+				return
+			}
+
+			go cs.handleConnection(conn)
+		}
+	}()
+
+	return nil
+}
+
+func (cs *controlServer) stop() {
+	if cs.listener == nil {
+		return
+	}
+
+	if err := cs.listener.Close(); err != nil {
+		cs.logger.Warnw("Failed to close control socket", "error", err)
+	} else {
+		cs.logger.Debug("Control socket closed")
+	}
+
+	cs.listener = nil
+}
+
+// publish hands an event to every watching client, dropping it for the ones that fell behind
+func (cs *controlServer) publish(eventType string, data interface{}) {
+	event := controlEvent{
+		Type: eventType,
+		Time: time.Now(),
+		Data: data,
+	}
+
+	cs.watchersLock.Lock()
+	defer cs.watchersLock.Unlock()
+
+	for watcher := range cs.watchers {
+		select {
+		case watcher <- event:
+		default:
+		}
+	}
+}
+
+func (cs *controlServer) setupEventForwarding() {
+	sliderEventsChannel := cs.reeemiks.reeemiksConnection.SubscribeToSliderMoveEvents()
+	buttonEventsChannel := cs.reeemiks.reeemiksConnection.SubscribeToButtonEvents()
+	configReloadedChannel := cs.reeemiks.config.SubscribeToChanges()
+
+	go func() {
+		activeProfile := cs.reeemiks.config.snapshot().ActiveProfile
+
+		for {
+			select {
+			case event := <-sliderEventsChannel:
+				cs.publish(controlEventSliderMove, event)
+			case event := <-buttonEventsChannel:
+				cs.publish(controlEventButton, event)
+			case <-configReloadedChannel:
+				cs.publish(controlEventConfigReload, nil)
+
+				// profile switches come through as reloads too, tell them apart for watchers that care
+				if current := cs.reeemiks.config.snapshot().ActiveProfile; current != activeProfile {
+					activeProfile = current
+					cs.publish(controlEventProfile, activeProfile)
+				}
+			}
+		}
+	}()
+}
+
+func (cs *controlServer) handleConnection(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		cs.logger.Debugw("Failed to read control request", "error", err)
+		return
+	}
+
+	request := controlRequest{}
+	if err := json.Unmarshal(line, &request); err != nil {
+		cs.respond(conn, nil, fmt.Errorf("malformed request: %w", err))
+		return
+	}
+
+	cs.logger.Debugw("Got control request", "command", request.Command, "args", request.Args)
+
+	if request.Command == controlCommandWatch {
+		cs.watch(conn, reader)
+		return
+	}
+
+	data, err := cs.handleRequest(request)
+	cs.respond(conn, data, err)
+}
+
+func (cs *controlServer) handleRequest(request controlRequest) (interface{}, error) {
+	switch request.Command {
+	case controlCommandListSessions:
+		return cs.listSessions(), nil
+
+	case controlCommandSet:
+		if len(request.Args) != 2 {
+			return nil, errors.New("usage: set <slider|target> <percent>")
+		}
+
+		percent, err := strconv.ParseFloat(request.Args[1], 32)
+		if err != nil || percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("invalid volume percentage: %s", request.Args[1])
+		}
+
+		return cs.setVolume(request.Args[0], float32(percent/100))
+
+	case controlCommandGet:
+		if len(request.Args) != 1 {
+			return nil, errors.New("usage: get <slider>")
+		}
+
+		sliderIdx, err := strconv.Atoi(request.Args[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid slider index: %s", request.Args[0])
+		}
+
+		targets, ok := cs.reeemiks.sessions.activeSliderMapping().get(sliderIdx)
+		if !ok {
+			return nil, fmt.Errorf("slider %d isn't mapped to anything", sliderIdx)
+		}
+
+		return cs.reeemiks.sessions.getSliderVolume(sliderIdx, targets), nil
+
+	case controlCommandMute:
+		if len(request.Args) < 1 || len(request.Args) > 2 {
+			return nil, errors.New("usage: mute <slider|target> [on|off|toggle]")
+		}
+
+		mode := "toggle"
+		if len(request.Args) == 2 {
+			mode = request.Args[1]
+		}
+
+		return cs.setMute(request.Args[0], mode)
+
+	case controlCommandReload:
+		return nil, cs.reeemiks.config.Reload()
+
+	case controlCommandStatus:
+		return cs.status(), nil
+
+	case controlCommandLearn:
+		sliderIdx, candidates, err := cs.reeemiks.learnSlider()
+		if err != nil {
+			return nil, err
+		}
+
+		return controlLearnResult{Slider: sliderIdx, Candidates: candidates}, nil
+
+	case controlCommandBind:
+		if len(request.Args) != 2 {
+			return nil, errors.New("usage: bind <slider> <target>")
+		}
+
+		sliderIdx, err := strconv.Atoi(request.Args[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid slider index: %s", request.Args[0])
+		}
+
+		return nil, cs.reeemiks.bindLearnedTarget(sliderIdx, request.Args[1])
+
+	case controlCommandUnbind:
+		if len(request.Args) != 2 {
+			return nil, errors.New("usage: unbind <slider> <target>")
+		}
+
+		sliderIdx, err := strconv.Atoi(request.Args[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid slider index: %s", request.Args[0])
+		}
+
+		return nil, cs.reeemiks.config.RemoveSliderTarget(sliderIdx, request.Args[1])
+
+	case controlCommandProfile:
+		if len(request.Args) > 1 {
+			return nil, errors.New("usage: profile [name|next|auto]")
+		}
+
+		if len(request.Args) == 1 {
+			if err := cs.reeemiks.switchProfile(request.Args[0]); err != nil {
+				return nil, err
+			}
+		}
+
+		config := cs.reeemiks.config.snapshot()
+
+		return controlProfiles{
+			Active:    config.ActiveProfile,
+			Profiles:  config.Profiles,
+			Automatic: cs.reeemiks.autoProfiles.active(),
+		}, nil
+
+	case controlCommandLayer:
+		if len(request.Args) > 1 {
+			return nil, errors.New("usage: layer [name|off]")
+		}
+
+		if len(request.Args) == 1 {
+			name := strings.ToLower(request.Args[0])
+
+			if name == layerOff {
+				name = ""
+			} else if _, ok := cs.reeemiks.config.snapshot().Layers[name]; !ok {
+				return nil, fmt.Errorf("no such layer: %s", name)
+			}
+
+			cs.reeemiks.sessions.setLayer(name)
+		}
+
+		return cs.reeemiks.sessions.currentLayer(), nil
+
+	case controlCommandMove:
+		if len(request.Args) != 2 {
+			return nil, errors.New("usage: move <slider|target> <sink|next>")
+		}
+
+		return cs.reeemiks.sessions.moveSessions(request.Args[0], request.Args[1])
+
+	case controlCommandSink:
+		if len(request.Args) > 1 {
+			return nil, errors.New("usage: sink [name|next]")
+		}
+
+		if len(request.Args) == 1 {
+			if _, err := cs.reeemiks.sessions.switchDefaultSink(request.Args[0]); err != nil {
+				return nil, err
+			}
+		}
+
+		return cs.sinks()
+	}
+
+	return nil, fmt.Errorf("unknown command: %s", request.Command)
+}
+
+func (cs *controlServer) respond(conn net.Conn, data interface{}, err error) {
+	response := controlResponse{}
+
+	if err != nil {
+		response.Error = err.Error()
+	} else if data != nil {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			cs.logger.Warnw("Failed to encode control response", "error", err)
+			response.Error = "failed to encode response"
+		} else {
+			response.Data = encoded
+		}
+	}
+
+	if err := json.NewEncoder(conn).Encode(response); err != nil {
+		cs.logger.Debugw("Failed to write control response", "error", err)
+	}
+}
+
+func (cs *controlServer) watch(conn net.Conn, reader *bufio.Reader) {
+	events := make(chan controlEvent, controlWatcherBufferSize)
+
+	cs.watchersLock.Lock()
+	cs.watchers[events] = true
+	cs.watchersLock.Unlock()
+
+	defer func() {
+		cs.watchersLock.Lock()
+		delete(cs.watchers, events)
+		cs.watchersLock.Unlock()
+	}()
+
+	cs.logger.Debug("Control client started watching events")
+
+	// clients never send anything after their request, so a finished read means they hung up
+	hangup := make(chan bool)
+	go func() {
+		reader.ReadByte()
+		close(hangup)
+	}()
+
+	cs.respond(conn, nil, nil)
+	encoder := json.NewEncoder(conn)
+
+	for {
+		select {
+		case <-hangup:
+			cs.logger.Debug("Control client stopped watching events")
+			return
+		case event := <-events:
+			if err := encoder.Encode(event); err != nil {
+				cs.logger.Debugw("Failed to deliver event to control client", "error", err)
+				return
+			}
+		}
+	}
+}
+
+func (cs *controlServer) listSessions() []controlSessionInfo {
+
+	// users come here looking for something that just started playing, so give the map a chance to see it
+	cs.reeemiks.sessions.refreshSessions(false)
+
+	// figure out which slider (if any) each session is currently controlled by
+	sessionSliders := map[Session][]int{}
+	cs.reeemiks.sessions.activeSliderMapping().iterate(func(sliderIdx int, targets []string) {
+		for _, session := range cs.reeemiks.sessions.resolveSessions(targets) {
+			sessionSliders[session] = append(sessionSliders[session], sliderIdx)
+		}
+	})
+
+	result := []controlSessionInfo{}
+
+	for _, key := range cs.reeemiks.sessions.keys() {
+		sessions, _ := cs.reeemiks.sessions.get(key)
+
+		for _, session := range sessions {
+			sliders := sessionSliders[session]
+			sort.Ints(sliders)
+
+			info := controlSessionInfo{
+				Key:     key,
+				Volume:  session.GetVolume(),
+				Muted:   session.GetMute(),
+				Sliders: sliders,
+			}
+
+			if process := sessionProcess(session); process != nil {
+				info.PID = process.pid
+				info.Parent = process.parent()
+				info.Scope = process.unit
+				info.Flatpak = process.flatpakID
+			}
+
+			result = append(result, info)
+		}
+	}
+
+	return result
+}
+
+// targetsFromArg treats numeric arguments as slider indexes and anything else as a single target
+func (cs *controlServer) targetsFromArg(arg string) ([]string, error) {
+	if sliderIdx, err := strconv.Atoi(arg); err == nil {
+		targets, ok := cs.reeemiks.sessions.activeSliderMapping().get(sliderIdx)
+		if !ok {
+			return nil, fmt.Errorf("slider %d isn't mapped to anything", sliderIdx)
+		}
+
+		return targets, nil
+	}
+
+	return []string{arg}, nil
+}
+
+func (cs *controlServer) setVolume(arg string, volume float32) (int, error) {
+	targets, err := cs.targetsFromArg(arg)
+	if err != nil {
+		return 0, err
+	}
+
+	sessions := cs.reeemiks.sessions.resolveSessions(targets)
+	if len(sessions) == 0 {
+		return 0, fmt.Errorf("no audio sessions found for %s", arg)
+	}
+
+	// setting a volume directly cancels whatever ramp or fade the session was in
+	for _, session := range sessions {
+		if err := cs.reeemiks.sessions.ramper.setVolume(session, volume, 0); err != nil {
+			return 0, fmt.Errorf("set volume of %s: %w", session.Key(), err)
+		}
+	}
+
+	return len(sessions), nil
+}
+
+func (cs *controlServer) setMute(arg string, mode string) (bool, error) {
+	targets, err := cs.targetsFromArg(arg)
+	if err != nil {
+		return false, err
+	}
+
+	sessions := cs.reeemiks.sessions.resolveSessions(targets)
+	if len(sessions) == 0 {
+		return false, fmt.Errorf("no audio sessions found for %s", arg)
+	}
+
+	var mute bool
+
+	switch mode {
+	case "on":
+		mute = true
+	case "off":
+		mute = false
+	case "toggle":
+
+		// toggle the group as a whole based on the first session, so mixed states converge
+		mute = !sessions[0].GetMute()
+	default:
+		return false, fmt.Errorf("invalid mute mode: %s", mode)
+	}
+
+	for _, session := range sessions {
+		if err := session.SetMute(mute); err != nil {
+			return false, fmt.Errorf("set mute state of %s: %w", session.Key(), err)
+		}
+	}
+
+	return mute, nil
+}
+
+func (cs *controlServer) sinks() (controlSinks, error) {
+	router, err := cs.reeemiks.sessions.sinkRouter()
+	if err != nil {
+		return controlSinks{}, err
+	}
+
+	sinks, err := router.sinkNames()
+	if err != nil {
+		return controlSinks{}, err
+	}
+
+	defaultSink, err := router.defaultSink()
+	if err != nil {
+		return controlSinks{}, err
+	}
+
+	return controlSinks{Default: defaultSink, Sinks: sinks}, nil
+}
+
+func (cs *controlServer) status() controlStatus {
+	connectionStatus := cs.reeemiks.reeemiksConnection.Status()
+
+	mappedSliders := 0
+	cs.reeemiks.sessions.activeSliderMapping().iterate(func(int, []string) {
+		mappedSliders++
+	})
+
+	return controlStatus{
+		Version:       cs.reeemiks.version,
+		Profile:       cs.reeemiks.config.snapshot().ActiveProfile,
+		Layer:         cs.reeemiks.sessions.currentLayer(),
+		Connected:     connectionStatus.Connected,
+		Port:          connectionStatus.Port,
+		NumSliders:    connectionStatus.NumSliders,
+		NumButtons:    connectionStatus.NumButtons,
+		MappedSliders: mappedSliders,
+		NumSessions:   cs.reeemiks.sessions.count(),
+		ConfigPath:    userConfigFilepath,
+	}
+}
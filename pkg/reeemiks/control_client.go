@@ -0,0 +1,338 @@
+package reeemiks
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	controlDialTimeout = 2 * time.Second
+
+	controlUsage = `usage: reeemiks ctl <command> [arguments]
+
+commands:
+  list-sessions                         list audio sessions, ready to paste into slider_mapping
+  set <slider|target> <percent>         set the volume of a slider's targets or a single target
+  get <slider>                          print a slider's current volume
+  mute <slider|target> [on|off|toggle]  mute or unmute a slider's targets or a single target
+  reload                                reload the configuration
+  status                                show the board connection and mapping status
+  watch                                 print live events as JSON lines until interrupted
+  learn                                 move a slider, then pick what it should control
+  bind <slider> <target>                add a target to a slider, saved to preferences.yaml
+  unbind <slider> <target>              remove a target from a slider, saved to preferences.yaml
+  profile [name|next|auto]              list profiles, switch to another one or back to automatic switching
+  layer [name|off]                      show the engaged slider layer, or switch layers
+  move <slider|target> <sink|next>      move a slider's targets or a single target to another sink
+  sink [name|next]                      list sinks, or switch the default sink`
+)
+
+// RunControlCommand sends a single `reeemiks ctl` command to the running reeemiks instance
+// and writes its human-readable result to out. interactive commands read the user's answers from in
+func RunControlCommand(args []string, in io.Reader, out io.Writer) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprintln(out, controlUsage)
+		return nil
+	}
+
+	request := controlRequest{
+		Command: args[0],
+		Args:    args[1:],
+	}
+
+	if request.Command == controlCommandLearn {
+		return runLearnCommand(in, out)
+	}
+
+	response, reader, err := sendControlRequest(request)
+	if err != nil {
+		return err
+	}
+
+	return printControlResponse(out, request, response, reader)
+}
+
+// runLearnCommand walks the user through learn mode: capture a slider, list candidates, bind the chosen one
+func runLearnCommand(in io.Reader, out io.Writer) error {
+	fmt.Fprintln(out, "Move the slider you want to map...")
+
+	response, _, err := sendControlRequest(controlRequest{Command: controlCommandLearn})
+	if err != nil {
+		return err
+	}
+
+	result := controlLearnResult{}
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return fmt.Errorf("decode learn result: %w", err)
+	}
+
+	if len(result.Candidates) == 0 {
+		return errors.New("no audio sessions found to bind")
+	}
+
+	fmt.Fprintf(out, "Got slider %d. What should it control?\n", result.Slider)
+	for idx, candidate := range result.Candidates {
+		fmt.Fprintf(out, "%3d) %s\n", idx+1, candidate)
+	}
+
+	fmt.Fprint(out, "Pick a number: ")
+
+	var choice int
+	if _, err := fmt.Fscanln(in, &choice); err != nil || choice < 1 || choice > len(result.Candidates) {
+		return errors.New("invalid choice, nothing was bound")
+	}
+
+	target := result.Candidates[choice-1]
+	bindRequest := controlRequest{
+		Command: controlCommandBind,
+		Args:    []string{strconv.Itoa(result.Slider), target},
+	}
+
+	if _, _, err := sendControlRequest(bindRequest); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Slider %d now also controls %s\n", result.Slider, quoteYAMLString(target))
+
+	return nil
+}
+
+// sendControlRequest delivers a request and reads the first response line, leaving the reader open for watch
+func sendControlRequest(request controlRequest) (controlResponse, *bufio.Reader, error) {
+	response := controlResponse{}
+
+	conn, err := net.DialTimeout("unix", controlSocketPath, controlDialTimeout)
+	if err != nil {
+		return response, nil, fmt.Errorf("connect to reeemiks at %s (is it running?): %w", controlSocketPath, err)
+	}
+
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		conn.Close()
+		return response, nil, fmt.Errorf("encode request: %w", err)
+	}
+
+	if _, err := conn.Write(append(encoded, '\n')); err != nil {
+		conn.Close()
+		return response, nil, fmt.Errorf("send request: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+
+	if err := readControlLine(reader, &response); err != nil {
+		conn.Close()
+		return response, nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if response.Error != "" {
+		conn.Close()
+		return response, nil, errors.New(response.Error)
+	}
+
+	// only watch keeps reading past the first line, and it's over once the process exits
+	if request.Command != controlCommandWatch {
+		conn.Close()
+	}
+
+	return response, reader, nil
+}
+
+func readControlLine(reader *bufio.Reader, v interface{}) error {
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(line, v)
+}
+
+func printControlResponse(out io.Writer, request controlRequest, response controlResponse, reader *bufio.Reader) error {
+	switch request.Command {
+	case controlCommandListSessions:
+		sessions := []controlSessionInfo{}
+		if err := json.Unmarshal(response.Data, &sessions); err != nil {
+			return fmt.Errorf("decode sessions: %w", err)
+		}
+
+		fmt.Fprintln(out, "# paste any of these under a slider in slider_mapping")
+
+		for _, session := range sessions {
+			comment := fmt.Sprintf("%.0f%%", session.Volume*100)
+
+			if session.Muted {
+				comment += ", muted"
+			}
+
+			for _, sliderIdx := range session.Sliders {
+				comment += fmt.Sprintf(", slider %d", sliderIdx)
+			}
+
+			if session.PID != 0 {
+				comment += fmt.Sprintf(", pid %d", session.PID)
+			}
+
+			if session.Parent != "" {
+				comment += ", parent " + session.Parent
+			}
+
+			if session.Scope != "" {
+				comment += ", scope " + session.Scope
+			}
+
+			if session.Flatpak != "" {
+				comment += ", flatpak " + session.Flatpak
+			}
+
+			fmt.Fprintf(out, "- %s  # %s\n", quoteYAMLString(session.Key), comment)
+		}
+
+	case controlCommandSet:
+		var count int
+		if err := json.Unmarshal(response.Data, &count); err != nil {
+			return fmt.Errorf("decode result: %w", err)
+		}
+
+		fmt.Fprintf(out, "Set %d audio session(s) to %s%%\n", count, request.Args[1])
+
+	case controlCommandGet:
+		var volume float32
+		if err := json.Unmarshal(response.Data, &volume); err != nil {
+			return fmt.Errorf("decode volume: %w", err)
+		}
+
+		fmt.Fprintf(out, "%.0f%%\n", volume*100)
+
+	case controlCommandMute:
+		var muted bool
+		if err := json.Unmarshal(response.Data, &muted); err != nil {
+			return fmt.Errorf("decode result: %w", err)
+		}
+
+		if muted {
+			fmt.Fprintf(out, "Muted %s\n", request.Args[0])
+		} else {
+			fmt.Fprintf(out, "Unmuted %s\n", request.Args[0])
+		}
+
+	case controlCommandReload:
+		fmt.Fprintln(out, "Configuration reloaded")
+
+	case controlCommandBind:
+		fmt.Fprintf(out, "Slider %s now also controls %s\n", request.Args[0], quoteYAMLString(request.Args[1]))
+
+	case controlCommandUnbind:
+		fmt.Fprintf(out, "Slider %s no longer controls %s\n", request.Args[0], quoteYAMLString(request.Args[1]))
+
+	case controlCommandProfile:
+		profiles := controlProfiles{}
+		if err := json.Unmarshal(response.Data, &profiles); err != nil {
+			return fmt.Errorf("decode profiles: %w", err)
+		}
+
+		for _, name := range profiles.Profiles {
+			marker := " "
+			if name == profiles.Active {
+				marker = "*"
+			}
+
+			fmt.Fprintf(out, "%s %s\n", marker, name)
+		}
+
+		if profiles.Automatic {
+			fmt.Fprintln(out, "Automatic switching is on")
+		}
+
+	case controlCommandLayer:
+		var layer string
+		if err := json.Unmarshal(response.Data, &layer); err != nil {
+			return fmt.Errorf("decode layer: %w", err)
+		}
+
+		if layer == "" {
+			layer = "none"
+		}
+
+		fmt.Fprintf(out, "Layer: %s\n", layer)
+
+	case controlCommandMove:
+		var count int
+		if err := json.Unmarshal(response.Data, &count); err != nil {
+			return fmt.Errorf("decode result: %w", err)
+		}
+
+		fmt.Fprintf(out, "Moved %d audio session(s)\n", count)
+
+	case controlCommandSink:
+		sinks := controlSinks{}
+		if err := json.Unmarshal(response.Data, &sinks); err != nil {
+			return fmt.Errorf("decode sinks: %w", err)
+		}
+
+		for _, name := range sinks.Sinks {
+			marker := " "
+			if name == sinks.Default {
+				marker = "*"
+			}
+
+			fmt.Fprintf(out, "%s %s\n", marker, name)
+		}
+
+	case controlCommandStatus:
+		status := controlStatus{}
+		if err := json.Unmarshal(response.Data, &status); err != nil {
+			return fmt.Errorf("decode status: %w", err)
+		}
+
+		connected := "no"
+		if status.Connected {
+			connected = "yes"
+		}
+
+		if status.Version != "" {
+			fmt.Fprintf(out, "Version:        %s\n", status.Version)
+		}
+
+		fmt.Fprintf(out, "Profile:        %s\n", status.Profile)
+
+		if status.Layer != "" {
+			fmt.Fprintf(out, "Layer:          %s\n", status.Layer)
+		}
+
+		fmt.Fprintf(out, "Connected:      %s\n", connected)
+		fmt.Fprintf(out, "Port:           %s\n", status.Port)
+		fmt.Fprintf(out, "Sliders:        %d reported, %d mapped\n", status.NumSliders, status.MappedSliders)
+		fmt.Fprintf(out, "Buttons:        %d\n", status.NumButtons)
+		fmt.Fprintf(out, "Audio sessions: %d\n", status.NumSessions)
+		fmt.Fprintf(out, "Config:         %s\n", status.ConfigPath)
+
+	case controlCommandWatch:
+
+		// events are already JSON lines, pass them along as they come so they can be piped elsewhere
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+
+				return fmt.Errorf("read event: %w", err)
+			}
+
+			fmt.Fprint(out, line)
+		}
+	}
+
+	return nil
+}
+
+// quoteYAMLString single-quotes a session key so it survives being pasted into config.yaml verbatim
+func quoteYAMLString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
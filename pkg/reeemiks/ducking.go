@@ -0,0 +1,264 @@
+package reeemiks
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// duckingRule brings some sliders' targets down while any of its apps is playing, e.g. music while voice chat talks
+type duckingRule struct {
+	When    []string `mapstructure:"when"`
+	Sliders []int    `mapstructure:"sliders"`
+	Amount  float64  `mapstructure:"amount"` // in percent
+	Fade    float64  `mapstructure:"fade"`   // in milliseconds
+}
+
+// duckingChange carries the duck factor every ducked slider should be at, and how long to fade each one there
+type duckingChange struct {
+	factors map[int]float32
+	fades   map[int]time.Duration
+}
+
+// ducker keeps an eye on the apps named in the ducking rules and ducks their sliders while they play.
+// ducking is a multiplier on top of the slider's position, so the slider itself never has to move
+type ducker struct {
+	reeemiks *Reeemiks
+	logger   *zap.SugaredLogger
+
+	// the factors last handed to the session map, which only hears about changes
+	lastFactors map[int]float32
+
+	stopChannel chan bool
+}
+
+const (
+
+	// how often the ducking rules look for apps starting or stopping to play
+	duckingCheckInterval = 500 * time.Millisecond
+
+	// how long ducking fades in and out, unless a rule says otherwise
+	defaultDuckingFade = 300 * time.Millisecond
+)
+
+func newDucker(reeemiks *Reeemiks, logger *zap.SugaredLogger) *ducker {
+	logger = logger.Named("ducking")
+
+	dk := &ducker{
+		reeemiks:    reeemiks,
+		logger:      logger,
+		stopChannel: make(chan bool),
+	}
+
+	logger.Debug("Created ducker instance")
+
+	return dk
+}
+
+func (dk *ducker) start() {
+	ticker := time.NewTicker(duckingCheckInterval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-dk.stopChannel:
+				return
+			case <-ticker.C:
+				dk.evaluate()
+			}
+		}
+	}()
+}
+
+func (dk *ducker) stop() {
+	close(dk.stopChannel)
+}
+
+// evaluate works out how far every slider should be ducked right now and hands that to the session map
+func (dk *ducker) evaluate() {
+	sessions := dk.reeemiks.sessions
+	config := dk.reeemiks.config.snapshot()
+
+	// without rules there's nothing to do, other than letting go of sliders that were ducked before a reload
+	if len(config.DuckingRules) == 0 && !sessions.ducked() {
+		return
+	}
+
+	// playing or not is read off the sessions we already have. apps that start up later are noticed once the
+	// session map refreshes for its own reasons, or once it has gone stale - never every few seconds.
+	// the refresh itself runs on the slider event loop, this goroutine only asks for it
+	if sessions.stale(maxTimeBetweenSessionRefreshes) {
+		sessions.requestRefresh()
+	}
+
+	change := duckingChange{
+		factors: map[int]float32{},
+		fades:   map[int]time.Duration{},
+	}
+
+	for _, rule := range config.DuckingRules {
+		fade := defaultDuckingFade
+		if rule.Fade > 0 {
+			fade = time.Duration(rule.Fade * float64(time.Millisecond))
+		}
+
+		playing := false
+		for _, session := range sessions.resolveSessions(rule.When) {
+			if sessionActive(session) {
+				playing = true
+				break
+			}
+		}
+
+		for _, sliderIdx := range rule.Sliders {
+			if fade > change.fades[sliderIdx] {
+				change.fades[sliderIdx] = fade
+			}
+
+			if _, ok := change.factors[sliderIdx]; !ok {
+				change.factors[sliderIdx] = 1
+			}
+
+			// when several rules duck the same slider, the strongest one wins
+			if factor := float32(1 - rule.Amount/100); playing && factor < change.factors[sliderIdx] {
+				change.factors[sliderIdx] = factor
+			}
+		}
+	}
+
+	if duckFactorsEqual(dk.lastFactors, change.factors) {
+		return
+	}
+
+	// applying the change fills in sliders that stop being ducked, so remember a copy of it
+	dk.lastFactors = make(map[int]float32, len(change.factors))
+	for sliderIdx, factor := range change.factors {
+		dk.lastFactors[sliderIdx] = factor
+	}
+
+	sessions.duckingEvents <- change
+}
+
+func duckFactorsEqual(a map[int]float32, b map[int]float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for sliderIdx, factor := range a {
+		if other, ok := b[sliderIdx]; !ok || other != factor {
+			return false
+		}
+	}
+
+	return true
+}
+
+// duckFactor returns how far ducking currently brings a slider's targets down, 1 when it isn't ducked
+func (m *sessionMap) duckFactor(sliderIdx int) float32 {
+	m.duckLock.Lock()
+	defer m.duckLock.Unlock()
+
+	if factor, ok := m.duckFactors[sliderIdx]; ok {
+		return factor
+	}
+
+	return 1
+}
+
+// sessionLevel returns where a session of the given slider is headed, as a slider position - without ducking
+func (m *sessionMap) sessionLevel(sliderIdx int, session Session) float32 {
+	volume := m.ramper.targetVolume(session)
+
+	// fully ducked sessions don't tell us anything about the slider
+	if factor := m.duckFactor(sliderIdx); factor > 0 && factor < 1 {
+		return clampVolume(volume / factor)
+	}
+
+	return volume
+}
+
+// applyDucking brings every slider whose duck factor changed to its new level. it runs on the slider event loop,
+// so it never races a slider move
+func (m *sessionMap) applyDucking(change duckingChange) {
+
+	// sliders no rule mentions anymore shouldn't stay ducked
+	m.duckLock.Lock()
+	for sliderIdx := range m.duckFactors {
+		if _, ok := change.factors[sliderIdx]; !ok {
+			change.factors[sliderIdx] = 1
+			change.fades[sliderIdx] = defaultDuckingFade
+		}
+	}
+	m.duckLock.Unlock()
+
+	for sliderIdx, factor := range change.factors {
+		previous := m.duckFactor(sliderIdx)
+		if factor == previous {
+			continue
+		}
+
+		if factor < previous {
+			m.logger.Infow("Ducking slider", "slider", sliderIdx, "factor", factor)
+		} else {
+			m.logger.Infow("Restoring ducked slider", "slider", sliderIdx)
+		}
+
+		config := m.reeemiks.config.snapshot()
+		settings := config.sliderSettings(sliderIdx)
+
+		// the slider's own position (or its targets', if it never moved) is what gets ducked
+		targets, ok := m.sliderMappingOf(config).get(sliderIdx)
+		if !ok {
+			m.setDuckFactor(sliderIdx, factor)
+			continue
+		}
+
+		m.layerLock.Lock()
+		level, known := m.sliderLevels[sliderIdx]
+		m.layerLock.Unlock()
+
+		if !known {
+			level = m.sliderLevel(sliderIdx, settings, m.resolveSessions(targets))
+		}
+
+		// sessions stepped on their own have no shared level, so they keep where they were relative to the slider
+		levels := map[Session]float32{}
+		for _, session := range m.resolveSessions(targets) {
+			levels[session] = m.sessionLevel(sliderIdx, session)
+		}
+
+		volumeFor := func(session Session) float32 {
+			return level
+		}
+
+		if settings.StepEachSession {
+			volumeFor = func(session Session) float32 {
+				return levels[session]
+			}
+		}
+
+		m.setDuckFactor(sliderIdx, factor)
+		m.applySliderVolume(SliderMoveEvent{SliderID: sliderIdx, PercentValue: level}, targets, settings, volumeFor, change.fades[sliderIdx])
+	}
+}
+
+// ducked returns true if any slider is currently ducked
+func (m *sessionMap) ducked() bool {
+	m.duckLock.Lock()
+	defer m.duckLock.Unlock()
+
+	return len(m.duckFactors) > 0
+}
+
+func (m *sessionMap) setDuckFactor(sliderIdx int, factor float32) {
+	m.duckLock.Lock()
+	defer m.duckLock.Unlock()
+
+	if factor >= 1 {
+		delete(m.duckFactors, sliderIdx)
+	} else {
+		m.duckFactors[sliderIdx] = factor
+	}
+}
@@ -0,0 +1,76 @@
+package reeemiks
+
+const (
+
+	// every session on the slider goes to wherever the slider is
+	groupModeAbsolute = "absolute"
+
+	// the slider scales its sessions proportionally, like a VCA group: the loudest one follows the slider
+	// and the rest keep their volume relative to it
+	groupModeRelative = "relative"
+
+	// below this a group is too quiet to tell its mix apart from rounding, so the last known mix is kept
+	groupMixMinLevel = 0.01
+)
+
+// sliderLevel returns where a slider's sessions are at (or headed, if they're ramping), in terms of the slider's
+// position: their average volume, or the loudest one in relative group mode since that's the one following the slider
+func (m *sessionMap) sliderLevel(sliderIdx int, settings sliderSettings, sessions []Session) float32 {
+	if len(sessions) == 0 {
+		return 0
+	}
+
+	var level float32
+
+	if settings.GroupMode == groupModeRelative {
+		for _, session := range sessions {
+			if volume := m.sessionLevel(sliderIdx, session); volume > level {
+				level = volume
+			}
+		}
+
+		return level
+	}
+
+	for _, session := range sessions {
+		level += m.sessionLevel(sliderIdx, session)
+	}
+
+	return level / float32(len(sessions))
+}
+
+// groupVolumeFor returns the volume every session in a relative group should be at once the slider is at level.
+// the mix inside the group is taken from the sessions' current volumes, so changing one of them elsewhere
+// changes the mix, and remembered for when the whole group is pulled all the way down
+func (m *sessionMap) groupVolumeFor(sliderIdx int, sessions []Session, level float32) func(Session) float32 {
+	var loudest float32
+	for _, session := range sessions {
+		if volume := m.sessionLevel(sliderIdx, session); volume > loudest {
+			loudest = volume
+		}
+	}
+
+	mix := m.groupMixes[sliderIdx]
+
+	if loudest >= groupMixMinLevel {
+		mix = make(map[string]float32, len(sessions))
+
+		// by identity, so two streams of the same app each keep their own place in the mix
+		for _, session := range sessions {
+			mix[sessionIdentity(session)] = m.sessionLevel(sliderIdx, session) / loudest
+		}
+
+		m.groupMixes[sliderIdx] = mix
+	}
+
+	return func(session Session) float32 {
+
+		// sessions we haven't seen in the group yet start out at the top of the mix
+		ratio, ok := mix[sessionIdentity(session)]
+		if !ok {
+			ratio = 1
+		}
+
+		return clampVolume(level * ratio)
+	}
+}
@@ -0,0 +1,40 @@
+package reeemiks
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestGroupVolumeForKeepsStreamsApart(t *testing.T) {
+	m, _ := newSessionMap(nil, zap.NewNop().Sugar(), nil)
+
+	// two streams of the same app, one half as loud as the other
+	loud := &fakeSession{key: "firefox", identity: "firefox#1", volume: 0.8}
+	quiet := &fakeSession{key: "firefox", identity: "firefox#2", volume: 0.4}
+	sessions := []Session{loud, quiet}
+
+	volumeFor := m.groupVolumeFor(0, sessions, 0.5)
+
+	if volume := volumeFor(loud); volume != 0.5 {
+		t.Errorf("expected the loudest stream to follow the slider to 0.50, got %.2f", volume)
+	}
+
+	if volume := volumeFor(quiet); volume != 0.25 {
+		t.Errorf("expected the quieter stream to keep its half of the mix at 0.25, got %.2f", volume)
+	}
+
+	// pulled all the way down, the group remembers its mix for the way back up
+	loud.SetVolume(0)
+	quiet.SetVolume(0)
+
+	volumeFor = m.groupVolumeFor(0, sessions, 1)
+
+	if volume := volumeFor(quiet); volume != 0.5 {
+		t.Errorf("expected the quieter stream to come back at half the slider, got %.2f", volume)
+	}
+
+	if volume := volumeFor(&fakeSession{key: "firefox", identity: "firefox#3"}); volume != 1 {
+		t.Errorf("expected a new stream to start at the top of the mix, got %.2f", volume)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sstallion/go-hid"
@@ -21,8 +22,12 @@ type HIDRAW struct {
 	logger *zap.SugaredLogger
 
 	stopChannel chan bool
-	connected   bool
-	hidDevice   *hid.Device
+
+	// written by the read loop, read by Status and SendLevels from the control server and the level meter
+	connected           bool
+	hidDevice           *hid.Device
+	lastKnownNumSliders int
+	stateLock           sync.Mutex
 
 	sliderMoveConsumers []chan SliderMoveEvent
 }
@@ -52,16 +57,18 @@ func (hidraw *HIDRAW) Start() error {
 	hid.Init()
 
 	// don't allow multiple concurrent connections
-	if hidraw.connected {
+	if hidraw.isConnected() {
 		hidraw.logger.Warn("Already connected, can't start another without closing first")
 		return errors.New("serial: connection already active")
 	}
 
+	connectionInfo := hidraw.reeemiks.config.snapshot().HidConnectionInfo
+
 	// Get hidraw devices
 	var hidDeviceInfo *hid.DeviceInfo
-	hid.Enumerate(hidraw.reeemiks.config.HidConnectionInfo.VendorId, hidraw.reeemiks.config.HidConnectionInfo.ProductId,
+	hid.Enumerate(connectionInfo.VendorId, connectionInfo.ProductId,
 		func(info *hid.DeviceInfo) error {
-			if info.UsagePage == hidraw.reeemiks.config.HidConnectionInfo.UsagePage && info.Usage == hidraw.reeemiks.config.HidConnectionInfo.Usage {
+			if info.UsagePage == connectionInfo.UsagePage && info.Usage == connectionInfo.Usage {
 				hidDeviceInfo = info
 			}
 			return nil
@@ -70,10 +77,10 @@ func (hidraw *HIDRAW) Start() error {
 
 	if hidDeviceInfo == nil {
 		hidraw.logger.Warnw("Could not find hidraw device",
-			"vendor_id", hidraw.reeemiks.config.HidConnectionInfo.VendorId,
-			"product_id", hidraw.reeemiks.config.HidConnectionInfo.ProductId,
-			"usage_page", hidraw.reeemiks.config.HidConnectionInfo.UsagePage,
-			"usage", hidraw.reeemiks.config.HidConnectionInfo.Usage)
+			"vendor_id", connectionInfo.VendorId,
+			"product_id", connectionInfo.ProductId,
+			"usage_page", connectionInfo.UsagePage,
+			"usage", connectionInfo.Usage)
 		return errors.New("Could not find hidraw device")
 	}
 
@@ -82,8 +89,7 @@ func (hidraw *HIDRAW) Start() error {
 		"Manufacturer", hidDeviceInfo.MfrStr,
 		"Path", hidDeviceInfo.Path)
 
-	var err error
-	hidraw.hidDevice, err = hid.OpenPath(hidDeviceInfo.Path)
+	hidDevice, err := hid.OpenPath(hidDeviceInfo.Path)
 	if err != nil {
 		// might need a user notification here, TBD
 		hidraw.logger.Warnw("Failed to open HID connection", "error", err)
@@ -98,11 +104,15 @@ func (hidraw *HIDRAW) Start() error {
 	)
 
 	namedLogger.Info("Connected")
+
+	hidraw.stateLock.Lock()
+	hidraw.hidDevice = hidDevice
 	hidraw.connected = true
+	hidraw.stateLock.Unlock()
 
 	// read hid_raw comms or await a stop
 	go func() {
-		buffChannel := hidraw.readHID(namedLogger)
+		buffChannel := hidraw.readHID(namedLogger, hidDevice)
 
 		// Send current slider values to controller
 		// hidraw.sendSliderValues(namedLogger)
@@ -121,7 +131,7 @@ func (hidraw *HIDRAW) Start() error {
 }
 
 func (hidraw *HIDRAW) sendSliderValues(logger *zap.SugaredLogger) {
-	hidraw.reeemiks.config.SliderMapping.iterate(func(slider int, targets []string) {
+	hidraw.reeemiks.sessions.activeSliderMapping().iterate(func(slider int, targets []string) {
 		sliderVolume := hidraw.reeemiks.sessions.getSliderVolume(slider, targets)
 
 		sliderVolume *= 100
@@ -139,13 +149,13 @@ func (hidraw *HIDRAW) sendSliderValues(logger *zap.SugaredLogger) {
 	})
 }
 
-func (hidraw *HIDRAW) readHID(logger *zap.SugaredLogger) chan []byte {
+func (hidraw *HIDRAW) readHID(logger *zap.SugaredLogger, hidDevice *hid.Device) chan []byte {
 	ch := make(chan []byte, 32)
 
 	go func() {
 		for {
 			buff := make([]byte, 32)
-			if _, err := hidraw.hidDevice.Read(buff); err != nil {
+			if _, err := hidDevice.Read(buff); err != nil {
 
 				if hidraw.reeemiks.Verbose() {
 					logger.Warn("Failed to read buffer")
@@ -172,29 +182,25 @@ func (hidraw *HIDRAW) handleBuff(logger *zap.SugaredLogger, buff []byte) {
 		slider := int(buff[1])
 		down := buff[2] == 0
 
-		// Get current volume
-		sliderMap, _ := hidraw.reeemiks.config.SliderMapping.get(slider)
-		sliderVolume := hidraw.reeemiks.sessions.getSliderVolume(slider, sliderMap)
-
-		// Set new volume in case of volume down
-		if down && sliderVolume-0.05 >= 0 {
-			sliderVolume -= 0.05
-		} else if down {
-			sliderVolume = 0
+		// the board never tells us how many sliders it has, so go by the highest one we've heard from
+		hidraw.stateLock.Lock()
+		if slider >= hidraw.lastKnownNumSliders {
+			hidraw.lastKnownNumSliders = slider + 1
 		}
+		hidraw.stateLock.Unlock()
 
-		// Set new volume in case of volume up
-		if !down && sliderVolume+0.05 <= 1.0 {
-			sliderVolume += 0.05
-		} else if !down {
-			sliderVolume = 1.0
+		// every report is a single step, the session map turns it into a volume using the slider's step size
+		steps := 1
+		if down {
+			steps = -1
 		}
 
 		// Notify consumers of slider changes
 		for _, consumer := range hidraw.sliderMoveConsumers {
 			moveEvent := SliderMoveEvent{
-				SliderID:     slider,
-				PercentValue: sliderVolume,
+				SliderID: slider,
+				Relative: true,
+				Steps:    steps,
 			}
 
 			consumer <- moveEvent
@@ -203,7 +209,7 @@ func (hidraw *HIDRAW) handleBuff(logger *zap.SugaredLogger, buff []byte) {
 }
 
 func (hidraw *HIDRAW) Stop() {
-	if hidraw.connected {
+	if hidraw.isConnected() {
 		hidraw.logger.Debug("Shutting down hid_raw connection")
 		hidraw.stopChannel <- true
 	} else {
@@ -211,6 +217,22 @@ func (hidraw *HIDRAW) Stop() {
 	}
 }
 
+// Status reports the configured device IDs and how many sliders have reported in so far
+func (hidraw *HIDRAW) Status() ConnectionStatus {
+	hidraw.stateLock.Lock()
+	defer hidraw.stateLock.Unlock()
+
+	connectionInfo := hidraw.reeemiks.config.snapshot().HidConnectionInfo
+
+	return ConnectionStatus{
+		Connected: hidraw.connected,
+		Port: fmt.Sprintf("%04x:%04x",
+			connectionInfo.VendorId,
+			connectionInfo.ProductId),
+		NumSliders: hidraw.lastKnownNumSliders,
+	}
+}
+
 // SubscribeToSliderMoveEvents returns an unbuffered channel that receives
 // a sliderMoveEvent struct every time a slider moves
 func (hidraw *HIDRAW) SubscribeToSliderMoveEvents() chan SliderMoveEvent {
@@ -220,6 +242,37 @@ func (hidraw *HIDRAW) SubscribeToSliderMoveEvents() chan SliderMoveEvent {
 	return ch
 }
 
+// SendLevels reports every slider's level in percent, in a single 0xFE report: the number of sliders,
+// followed by one byte per slider
+func (hidraw *HIDRAW) SendLevels(levels []float32) error {
+	hidraw.stateLock.Lock()
+	defer hidraw.stateLock.Unlock()
+
+	if !hidraw.connected || hidraw.hidDevice == nil {
+		return errors.New("hid_raw: not connected")
+	}
+
+	message := make([]byte, 32)
+	message[0] = 0x03
+	message[1] = 0xFE
+
+	// whatever doesn't fit in a single report is left out
+	if len(levels) > len(message)-3 {
+		levels = levels[:len(message)-3]
+	}
+
+	message[2] = byte(len(levels))
+	for idx, level := range levels {
+		message[3+idx] = byte(level*100 + 0.5)
+	}
+
+	if _, err := hidraw.hidDevice.Write(message); err != nil {
+		return fmt.Errorf("write levels: %w", err)
+	}
+
+	return nil
+}
+
 // TODO: Buttons don't work via HID
 func (hidraw *HIDRAW) SubscribeToButtonEvents() chan ButtonEvent {
 	ch := make(chan ButtonEvent)
@@ -228,7 +281,18 @@ func (hidraw *HIDRAW) SubscribeToButtonEvents() chan ButtonEvent {
 	return ch
 }
 
+// isConnected returns true while the HID device is open
+func (hidraw *HIDRAW) isConnected() bool {
+	hidraw.stateLock.Lock()
+	defer hidraw.stateLock.Unlock()
+
+	return hidraw.connected
+}
+
 func (hidraw *HIDRAW) close(logger *zap.SugaredLogger) {
+	hidraw.stateLock.Lock()
+	defer hidraw.stateLock.Unlock()
+
 	if err := hidraw.hidDevice.Close(); err != nil {
 		logger.Warnw("Failed to close hid_raw connection", "error", err)
 	} else {
@@ -249,10 +313,12 @@ func (hidraw *HIDRAW) setupOnConfigReload() {
 		for {
 			select {
 			case <-configReloadedChannel:
-				if hidraw.reeemiks.config.HidConnectionInfo.ProductId != hidraw.productId ||
-					hidraw.reeemiks.config.HidConnectionInfo.VendorId != hidraw.vendorId ||
-					hidraw.reeemiks.config.HidConnectionInfo.UsagePage != hidraw.UsagePage ||
-					hidraw.reeemiks.config.HidConnectionInfo.Usage != hidraw.Usage {
+				connectionInfo := hidraw.reeemiks.config.snapshot().HidConnectionInfo
+
+				if connectionInfo.ProductId != hidraw.productId ||
+					connectionInfo.VendorId != hidraw.vendorId ||
+					connectionInfo.UsagePage != hidraw.UsagePage ||
+					connectionInfo.Usage != hidraw.Usage {
 
 					hidraw.logger.Info("Detected change in connection parameters, attempting to renew connection")
 					hidraw.Stop()
@@ -0,0 +1,110 @@
+package reeemiks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// keyChord is a set of keys pressed together, like ctrl+shift+m. keys named in the config are Linux input event
+// codes (KEY_*), translated for the platform when pressed. a raw platform key code (a bare number, which is what
+// button_mapping used to take, or code:4219) is passed on as it is instead
+type keyChord struct {
+	codes []int
+	raw   bool
+}
+
+// Linux input event codes by name, as in linux/input-event-codes.h without the KEY_ prefix
+var keyCodes = map[string]int{
+	"esc": 1, "1": 2, "2": 3, "3": 4, "4": 5, "5": 6, "6": 7, "7": 8, "8": 9, "9": 10, "0": 11,
+	"minus": 12, "equal": 13, "backspace": 14, "tab": 15,
+	"q": 16, "w": 17, "e": 18, "r": 19, "t": 20, "y": 21, "u": 22, "i": 23, "o": 24, "p": 25,
+	"leftbrace": 26, "rightbrace": 27, "enter": 28, "leftctrl": 29,
+	"a": 30, "s": 31, "d": 32, "f": 33, "g": 34, "h": 35, "j": 36, "k": 37, "l": 38,
+	"semicolon": 39, "apostrophe": 40, "grave": 41, "leftshift": 42, "backslash": 43,
+	"z": 44, "x": 45, "c": 46, "v": 47, "b": 48, "n": 49, "m": 50,
+	"comma": 51, "dot": 52, "slash": 53, "rightshift": 54, "kpasterisk": 55, "leftalt": 56, "space": 57, "capslock": 58,
+	"f1": 59, "f2": 60, "f3": 61, "f4": 62, "f5": 63, "f6": 64, "f7": 65, "f8": 66, "f9": 67, "f10": 68,
+	"numlock": 69, "scrolllock": 70,
+	"kp7": 71, "kp8": 72, "kp9": 73, "kpminus": 74, "kp4": 75, "kp5": 76, "kp6": 77, "kpplus": 78,
+	"kp1": 79, "kp2": 80, "kp3": 81, "kp0": 82, "kpdot": 83,
+	"f11": 87, "f12": 88, "kpenter": 96, "rightctrl": 97, "kpslash": 98, "sysrq": 99, "rightalt": 100,
+	"home": 102, "up": 103, "pageup": 104, "left": 105, "right": 106, "end": 107, "down": 108, "pagedown": 109,
+	"insert": 110, "delete": 111,
+	"mute": 113, "volumedown": 114, "volumeup": 115, "power": 116, "kpequal": 117, "pause": 119,
+	"leftmeta": 125, "rightmeta": 126, "compose": 127,
+	"stop": 128, "calc": 140, "sleep": 142, "wakeup": 143, "mail": 155, "bookmarks": 156, "computer": 157,
+	"back": 158, "forward": 159, "nextsong": 163, "playpause": 164, "previoussong": 165, "stopcd": 166,
+	"record": 167, "rewind": 168, "homepage": 172, "refresh": 173,
+	"f13": 183, "f14": 184, "f15": 185, "f16": 186, "f17": 187, "f18": 188,
+	"f19": 189, "f20": 190, "f21": 191, "f22": 192, "f23": 193, "f24": 194,
+	"playcd": 200, "pausecd": 201, "fastforward": 208, "print": 210, "search": 217,
+	"brightnessdown": 224, "brightnessup": 225, "media": 226, "micmute": 248,
+}
+
+// friendlier names for the keys people reach for most, modifiers especially
+var keyAliases = map[string]string{
+	"ctrl":        "leftctrl",
+	"control":     "leftctrl",
+	"shift":       "leftshift",
+	"alt":         "leftalt",
+	"altgr":       "rightalt",
+	"super":       "leftmeta",
+	"meta":        "leftmeta",
+	"win":         "leftmeta",
+	"escape":      "esc",
+	"return":      "enter",
+	"del":         "delete",
+	"printscreen": "sysrq",
+	"menu":        "compose",
+}
+
+// raw platform key codes can also be given with this in front, to make it obvious they aren't key names
+const rawKeyCodePrefix = "code:"
+
+// parseKeyChord reads key names joined by +, e.g. F13, KEY_PLAYPAUSE, KEY_1 or ctrl+shift+m, or a raw key code like 4219.
+// a bare number is always a raw code like it's always been, so the number keys on their own are KEY_0 to KEY_9
+func parseKeyChord(value string) (keyChord, error) {
+	trimmed := strings.ToLower(strings.TrimSpace(value))
+
+	if number, ok := strings.CutPrefix(trimmed, rawKeyCodePrefix); ok {
+		return parseRawKeyCode(strings.TrimSpace(number))
+	}
+
+	if _, err := strconv.Atoi(trimmed); err == nil {
+		return parseRawKeyCode(trimmed)
+	}
+
+	chord := keyChord{}
+
+	for _, name := range strings.Split(value, "+") {
+		name = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "key_")
+
+		if alias, ok := keyAliases[name]; ok {
+			name = alias
+		}
+
+		code, ok := keyCodes[name]
+		if !ok {
+			return keyChord{}, fmt.Errorf("unknown key %q", name)
+		}
+
+		chord.codes = append(chord.codes, code)
+	}
+
+	return chord, nil
+}
+
+// parseRawKeyCode reads a platform key code, which has to be one the platform's keyboard can press
+func parseRawKeyCode(value string) (keyChord, error) {
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		return keyChord{}, fmt.Errorf("invalid key code %q", value)
+	}
+
+	if code <= 0 || code > maxRawKeyCode {
+		return keyChord{}, fmt.Errorf("key code %d is out of range, expected 1 to %d", code, maxRawKeyCode)
+	}
+
+	return keyChord{codes: []int{code}, raw: true}, nil
+}
@@ -0,0 +1,194 @@
+package reeemiks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// virtualKeyboard is a keyboard reeemiks creates through uinput, which works the same under X11, every Wayland
+// compositor and on the console. it's created the first time a button presses a key, and lives until we exit
+type virtualKeyboard struct {
+	logger *zap.SugaredLogger
+
+	file *os.File
+	lock sync.Mutex
+}
+
+// uinputUserDev is the legacy uinput device description, which every kernel with uinput understands
+type uinputUserDev struct {
+	Name         [80]byte
+	BusType      uint16
+	Vendor       uint16
+	Product      uint16
+	Version      uint16
+	FFEffectsMax uint32
+	AbsMax       [64]int32
+	AbsMin       [64]int32
+	AbsFuzz      [64]int32
+	AbsFlat      [64]int32
+}
+
+type inputEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+const (
+	uinputName = "Reeemiks virtual keyboard"
+
+	// ioctls from linux/uinput.h
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+
+	evSyn    = 0x00
+	evKey    = 0x01
+	busVirt  = 0x06
+	keyMaxID = 255 // codes above this are buttons, and a device with those looks like a mouse or joystick
+
+	// raw key codes are input event codes here, so only the ones our device has
+	maxRawKeyCode = keyMaxID
+
+	// how long the device gets to show up in the compositor before its first key press, and how long keys are held
+	uinputSettleDelay = 250 * time.Millisecond
+	keyTapDuration    = 20 * time.Millisecond
+)
+
+var uinputPaths = []string{"/dev/uinput", "/dev/input/uinput"}
+
+func newVirtualKeyboard(logger *zap.SugaredLogger) (*virtualKeyboard, error) {
+	kb := &virtualKeyboard{logger: logger.Named("keyboard")}
+
+	var err error
+
+	for _, path := range uinputPaths {
+		if kb.file, err = os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil || !os.IsNotExist(err) {
+			break
+		}
+	}
+
+	if err != nil {
+		if os.IsPermission(err) {
+			return nil, fmt.Errorf("open uinput: %w (reeemiks needs write access to /dev/uinput, e.g. through the input group)", err)
+		}
+
+		return nil, fmt.Errorf("open uinput: %w (is the uinput module loaded?)", err)
+	}
+
+	if err := kb.create(); err != nil {
+		kb.file.Close()
+		return nil, err
+	}
+
+	// compositors and libinput need a moment to pick a new device up, or the first key press gets lost
+	time.Sleep(uinputSettleDelay)
+
+	kb.logger.Debug("Created virtual keyboard")
+
+	return kb, nil
+}
+
+func (kb *virtualKeyboard) create() error {
+	if err := kb.ioctl(uiSetEvBit, evKey); err != nil {
+		return fmt.Errorf("enable key events: %w", err)
+	}
+
+	if err := kb.ioctl(uiSetEvBit, evSyn); err != nil {
+		return fmt.Errorf("enable sync events: %w", err)
+	}
+
+	for code := 1; code <= keyMaxID; code++ {
+		if err := kb.ioctl(uiSetKeyBit, uintptr(code)); err != nil {
+			return fmt.Errorf("enable key %d: %w", code, err)
+		}
+	}
+
+	device := uinputUserDev{BusType: busVirt, Vendor: 0x1, Product: 0x1, Version: 0x1}
+	copy(device.Name[:], uinputName)
+
+	if err := binary.Write(kb.file, binary.NativeEndian, &device); err != nil {
+		return fmt.Errorf("describe virtual keyboard: %w", err)
+	}
+
+	if err := kb.ioctl(uiDevCreate, 0); err != nil {
+		return fmt.Errorf("create virtual keyboard: %w", err)
+	}
+
+	return nil
+}
+
+// tap presses every key of a chord in order, and lets go of them in reverse
+func (kb *virtualKeyboard) tap(chord keyChord) error {
+	for _, code := range chord.codes {
+		if code > keyMaxID {
+			return fmt.Errorf("key code %d is out of range", code)
+		}
+	}
+
+	kb.lock.Lock()
+	defer kb.lock.Unlock()
+
+	if kb.file == nil {
+		return errors.New("virtual keyboard is closed")
+	}
+
+	press := &bytes.Buffer{}
+	release := &bytes.Buffer{}
+
+	for idx := range chord.codes {
+		binary.Write(press, binary.NativeEndian, inputEvent{Type: evKey, Code: uint16(chord.codes[idx]), Value: 1})
+		binary.Write(release, binary.NativeEndian, inputEvent{Type: evKey, Code: uint16(chord.codes[len(chord.codes)-1-idx]), Value: 0})
+	}
+
+	binary.Write(press, binary.NativeEndian, inputEvent{Type: evSyn})
+	binary.Write(release, binary.NativeEndian, inputEvent{Type: evSyn})
+
+	if _, err := kb.file.Write(press.Bytes()); err != nil {
+		return fmt.Errorf("press keys: %w", err)
+	}
+
+	time.Sleep(keyTapDuration)
+
+	if _, err := kb.file.Write(release.Bytes()); err != nil {
+		return fmt.Errorf("release keys: %w", err)
+	}
+
+	return nil
+}
+
+func (kb *virtualKeyboard) close() error {
+	kb.lock.Lock()
+	defer kb.lock.Unlock()
+
+	if kb.file == nil {
+		return nil
+	}
+
+	kb.ioctl(uiDevDestroy, 0)
+
+	err := kb.file.Close()
+	kb.file = nil
+
+	kb.logger.Debug("Destroyed virtual keyboard")
+
+	return err
+}
+
+func (kb *virtualKeyboard) ioctl(request uintptr, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, kb.file.Fd(), request, arg); errno != 0 {
+		return errno
+	}
+
+	return nil
+}
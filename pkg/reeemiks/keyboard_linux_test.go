@@ -0,0 +1,14 @@
+package reeemiks
+
+import (
+	"testing"
+)
+
+// keybd_event's codes from Windows configs don't fit a Linux keyboard, and are caught when the config loads
+func TestValidateWindowsKeyCodeOnLinux(t *testing.T) {
+	problems := validateUserConfig([]byte("button_mapping:\n  0: 4219\n  1: 183\n"))
+
+	if len(problems) != 1 || problems[0].path != "button_mapping.0" {
+		t.Errorf("expected only button 0 to be reported, got %v", problems)
+	}
+}
@@ -0,0 +1,65 @@
+package reeemiks
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParseKeyChord(t *testing.T) {
+	tests := []struct {
+		value string
+		codes []int
+		raw   bool
+	}{
+		{"F13", []int{183}, false},
+		{"KEY_PLAYPAUSE", []int{164}, false},
+		{"ctrl+shift+m", []int{29, 42, 50}, false},
+		{" Ctrl + Alt + Delete ", []int{29, 56, 111}, false},
+
+		// bare numbers are raw codes like they've always been, the number keys need their KEY_ name
+		{"1", []int{1}, true},
+		{"5", []int{5}, true},
+		{"KEY_1", []int{2}, false},
+		{"key_0", []int{11}, false},
+		{"ctrl+1", []int{29, 2}, false},
+		{"code:1", []int{1}, true},
+		{"Code: 30", []int{30}, true},
+		{"30", []int{30}, true},
+	}
+
+	for _, test := range tests {
+		chord, err := parseKeyChord(test.value)
+		if err != nil {
+			t.Errorf("%q: %v", test.value, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(chord.codes, test.codes) || chord.raw != test.raw {
+			t.Errorf("%q: expected %v (raw %v), got %v (raw %v)", test.value, test.codes, test.raw, chord.codes, chord.raw)
+		}
+	}
+}
+
+func TestParseKeyChordErrors(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"nothing",
+		"ctrl+nothing",
+		"ctrl+",
+		"-3",
+		"0",
+		"code:0",
+		"code:f13",
+		strconv.Itoa(maxRawKeyCode + 1),
+		"code:" + strconv.Itoa(maxRawKeyCode+1),
+	} {
+		if chord, err := parseKeyChord(value); err == nil {
+			t.Errorf("%q: expected an error, got %+v", value, chord)
+		}
+	}
+
+	if _, err := parseKeyChord(strconv.Itoa(maxRawKeyCode)); err != nil {
+		t.Errorf("expected the highest raw key code to be accepted, got %v", err)
+	}
+}
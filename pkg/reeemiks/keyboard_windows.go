@@ -0,0 +1,118 @@
+package reeemiks
+
+import (
+	"fmt"
+
+	"github.com/micmonay/keybd_event"
+	"go.uber.org/zap"
+)
+
+// virtualKeyboard presses keys with keybd_event. it has nothing to set up, the type just mirrors linux's
+type virtualKeyboard struct {
+	logger *zap.SugaredLogger
+}
+
+// keybd_event's codes for keys whose Linux code isn't also their scancode: virtual-key codes plus its 0xFFF marker
+const keybdVirtualKey = 0xFFF
+
+// raw key codes are keybd_event's, scancodes or virtual-key codes (which go up to 0xFF) plus its marker
+const maxRawKeyCode = 0xFF + keybdVirtualKey
+
+var windowsKeyCodes = map[int]int{
+	29:  0xA2 + keybdVirtualKey, // left ctrl
+	97:  0xA3 + keybdVirtualKey, // right ctrl
+	42:  0xA0 + keybdVirtualKey, // left shift
+	54:  0xA1 + keybdVirtualKey, // right shift
+	56:  0xA4 + keybdVirtualKey, // left alt
+	100: 0xA5 + keybdVirtualKey, // right alt
+	125: 0x5B + keybdVirtualKey, // left windows key
+	126: 0x5C + keybdVirtualKey, // right windows key
+	127: 0x5D + keybdVirtualKey, // menu
+
+	102: 0x24 + keybdVirtualKey, // home
+	103: 0x26 + keybdVirtualKey, // up
+	104: 0x21 + keybdVirtualKey, // page up
+	105: 0x25 + keybdVirtualKey, // left
+	106: 0x27 + keybdVirtualKey, // right
+	107: 0x23 + keybdVirtualKey, // end
+	108: 0x28 + keybdVirtualKey, // down
+	109: 0x22 + keybdVirtualKey, // page down
+	110: 0x2D + keybdVirtualKey, // insert
+	111: 0x2E + keybdVirtualKey, // delete
+	98:  0x6F + keybdVirtualKey, // keypad slash
+	99:  0x2C + keybdVirtualKey, // print screen
+	119: 0x13 + keybdVirtualKey, // pause
+
+	113: 0xAD + keybdVirtualKey, // mute
+	114: 0xAE + keybdVirtualKey, // volume down
+	115: 0xAF + keybdVirtualKey, // volume up
+	163: 0xB0 + keybdVirtualKey, // next track
+	165: 0xB1 + keybdVirtualKey, // previous track
+	166: 0xB2 + keybdVirtualKey, // stop
+	164: 0xB3 + keybdVirtualKey, // play/pause
+	155: 0xB4 + keybdVirtualKey, // mail
+	226: 0xB5 + keybdVirtualKey, // media
+	157: 0xB6 + keybdVirtualKey, // computer
+	140: 0xB7 + keybdVirtualKey, // calculator
+	158: 0xA6 + keybdVirtualKey, // browser back
+	159: 0xA7 + keybdVirtualKey, // browser forward
+	173: 0xA8 + keybdVirtualKey, // browser refresh
+	128: 0xA9 + keybdVirtualKey, // browser stop
+	217: 0xAA + keybdVirtualKey, // browser search
+	156: 0xAB + keybdVirtualKey, // browser favorites
+	172: 0xAC + keybdVirtualKey, // browser home
+	142: 0x5F + keybdVirtualKey, // sleep
+
+	183: 0x7C + keybdVirtualKey, // F13 to F24
+	184: 0x7D + keybdVirtualKey,
+	185: 0x7E + keybdVirtualKey,
+	186: 0x7F + keybdVirtualKey,
+	187: 0x80 + keybdVirtualKey,
+	188: 0x81 + keybdVirtualKey,
+	189: 0x82 + keybdVirtualKey,
+	190: 0x83 + keybdVirtualKey,
+	191: 0x84 + keybdVirtualKey,
+	192: 0x85 + keybdVirtualKey,
+	193: 0x86 + keybdVirtualKey,
+	194: 0x87 + keybdVirtualKey,
+}
+
+// up to F12, the keys without an entry above send the same scancode Linux numbers them by
+const maxScancodeKey = 88
+
+func newVirtualKeyboard(logger *zap.SugaredLogger) (*virtualKeyboard, error) {
+	return &virtualKeyboard{logger: logger.Named("keyboard")}, nil
+}
+
+func (kb *virtualKeyboard) tap(chord keyChord) error {
+	codes := chord.codes
+
+	if !chord.raw {
+		codes = []int{}
+
+		for _, code := range chord.codes {
+			if windowsCode, ok := windowsKeyCodes[code]; ok {
+				codes = append(codes, windowsCode)
+			} else if code <= maxScancodeKey {
+				codes = append(codes, code)
+			} else {
+				return fmt.Errorf("key %d isn't supported on Windows", code)
+			}
+		}
+	}
+
+	kb.logger.Debugw("Pressing keys", "keycodes", codes)
+
+	bonding, err := keybd_event.NewKeyBonding()
+	if err != nil {
+		return fmt.Errorf("create key binding: %w", err)
+	}
+
+	bonding.SetKeys(codes...)
+
+	return bonding.Launching()
+}
+
+func (kb *virtualKeyboard) close() error {
+	return nil
+}
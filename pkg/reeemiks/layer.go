@@ -0,0 +1,105 @@
+package reeemiks
+
+import "strings"
+
+// a layer swaps in an alternate slider mapping while it's engaged, e.g. [layer, shift, hold] on a button.
+// sliders a layer doesn't map keep controlling what they normally do
+const (
+	buttonActionLayer = "layer"
+
+	// the layer is engaged for as long as the button is held down
+	layerModeHold = "hold"
+
+	// every press engages or disengages the layer
+	layerModeToggle = "toggle"
+
+	// what `reeemiks ctl layer` calls having no layer engaged
+	layerOff = "off"
+)
+
+// currentLayer returns the name of the engaged layer, or an empty string when there's none
+func (m *sessionMap) currentLayer() string {
+	m.layerLock.Lock()
+	defer m.layerLock.Unlock()
+
+	return m.activeLayer
+}
+
+// activeSliderMapping returns the slider mapping currently in effect, with the engaged layer (if any) on top
+func (m *sessionMap) activeSliderMapping() *sliderMap {
+	return m.sliderMappingOf(m.reeemiks.config.snapshot())
+}
+
+// sliderMappingOf returns the slider mapping in effect for the given config, for callers that hold on to a snapshot
+func (m *sessionMap) sliderMappingOf(config *configSnapshot) *sliderMap {
+	if layerMapping, ok := config.Layers[m.currentLayer()]; ok {
+		return config.SliderMapping.overlay(layerMapping)
+	}
+
+	return config.SliderMapping
+}
+
+// setLayer engages the named layer, or disengages any layer when name is empty
+func (m *sessionMap) setLayer(name string) {
+	m.layerLock.Lock()
+
+	if m.activeLayer == name {
+		m.layerLock.Unlock()
+		return
+	}
+
+	m.activeLayer = name
+	m.layerLock.Unlock()
+
+	// the sliders now sit on top of other targets, don't let them jump
+	m.armPickup()
+
+	m.logger.Infow("Switched slider layer", "layer", name)
+	m.reeemiks.control.publish(controlEventLayer, name)
+}
+
+func (m *sessionMap) handleLayerButton(buttonID int, args []string, pressed bool) {
+	name := strings.ToLower(args[0])
+
+	if _, ok := m.reeemiks.config.snapshot().Layers[name]; !ok {
+		m.logger.Warnw("Button mapped to unknown layer", "button", buttonID, "layer", name)
+		return
+	}
+
+	mode := layerModeHold
+	if len(args) > 1 {
+		mode = strings.ToLower(args[1])
+	}
+
+	switch mode {
+	case layerModeHold:
+		if pressed {
+			m.setLayer(name)
+		} else {
+			m.setLayer("")
+		}
+
+	case layerModeToggle:
+		if !pressed {
+			return
+		}
+
+		if m.currentLayer() == name {
+			m.setLayer("")
+		} else {
+			m.setLayer(name)
+		}
+
+	default:
+		m.logger.Warnw("Unknown layer mode", "button", buttonID, "mode", mode)
+	}
+}
+
+// forgetRemovedLayer disengages the active layer once a config change has taken it away
+func (m *sessionMap) forgetRemovedLayer() {
+	layer := m.currentLayer()
+
+	if _, ok := m.reeemiks.config.snapshot().Layers[layer]; layer != "" && !ok {
+		m.setLayer("")
+	}
+}
@@ -0,0 +1,48 @@
+package reeemiks
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+
+	// how long learn mode waits for the user to touch a slider before giving up
+	learnSliderTimeout = 30 * time.Second
+)
+
+var errLearnTimeout = errors.New("no slider moved in time")
+
+// learnSlider waits for the user to move a physical slider, then returns that slider's index together with
+// the keys of every session and device it could be bound to. the move itself doesn't change any volumes
+func (d *Reeemiks) learnSlider() (int, []string, error) {
+	d.logger.Info("Learn mode started, waiting for a slider to move")
+
+	sliderIdx, err := d.sessions.captureNextSliderMove(learnSliderTimeout)
+	if err != nil {
+		d.logger.Infow("Learn mode stopped without a slider", "error", err)
+		return 0, nil, err
+	}
+
+	// performance: forcing a refresh is fine here, learn mode only ever runs because a human asked for it
+	d.sessions.refreshSessions(true)
+	candidates := d.sessions.keys()
+
+	d.logger.Infow("Learn mode captured slider", "slider", sliderIdx, "candidates", len(candidates))
+
+	return sliderIdx, candidates, nil
+}
+
+// bindLearnedTarget persists the user's pick from learn mode to the internal config
+func (d *Reeemiks) bindLearnedTarget(sliderIdx int, target string) error {
+	if err := d.config.AddSliderTarget(sliderIdx, target); err != nil {
+		d.logger.Warnw("Failed to bind learned target", "slider", sliderIdx, "target", target, "error", err)
+		return fmt.Errorf("bind learned target: %w", err)
+	}
+
+	d.notifier.Notify(fmt.Sprintf("Slider %d mapped!", sliderIdx),
+		fmt.Sprintf("It now also controls %s.", target))
+
+	return nil
+}
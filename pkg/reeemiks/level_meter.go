@@ -0,0 +1,128 @@
+package reeemiks
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// peakSession is implemented by sessions that can tell how loud they're playing
+type peakSession interface {
+
+	// Peak returns the session's most recent peak level, from 0 to 1
+	Peak() float32
+}
+
+// levelMeter works out how loud every mapped slider's sessions are playing, and passes that on to
+// `reeemiks ctl watch` and (if asked to) the board, so it can light up LED bars
+type levelMeter struct {
+	reeemiks *Reeemiks
+	logger   *zap.SugaredLogger
+
+	lastLevels []float32
+
+	stopChannel chan bool
+}
+
+const (
+
+	// how often levels are measured and sent out
+	levelMeterInterval = 50 * time.Millisecond
+
+	// smaller changes than this aren't worth sending anywhere
+	levelMeterThreshold = 0.01
+)
+
+func newLevelMeter(reeemiks *Reeemiks, logger *zap.SugaredLogger) *levelMeter {
+	logger = logger.Named("levels")
+
+	lm := &levelMeter{
+		reeemiks:    reeemiks,
+		logger:      logger,
+		stopChannel: make(chan bool),
+	}
+
+	logger.Debug("Created level meter instance")
+
+	return lm
+}
+
+func (lm *levelMeter) start() {
+	ticker := time.NewTicker(levelMeterInterval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-lm.stopChannel:
+				return
+			case <-ticker.C:
+				if lm.reeemiks.config.snapshot().EnableLevelMeter {
+					lm.update()
+				}
+			}
+		}
+	}()
+}
+
+func (lm *levelMeter) stop() {
+	close(lm.stopChannel)
+}
+
+// update measures every slider and hands the levels out, if any of them changed noticeably
+func (lm *levelMeter) update() {
+	levels := lm.sliderLevels()
+	if !levelsChanged(lm.lastLevels, levels) {
+		return
+	}
+
+	lm.lastLevels = levels
+	lm.reeemiks.control.publish(controlEventLevels, levels)
+
+	if !lm.reeemiks.config.snapshot().SendLevelsToDevice {
+		return
+	}
+
+	if err := lm.reeemiks.reeemiksConnection.SendLevels(levels); err != nil && lm.reeemiks.Verbose() {
+		lm.logger.Debugw("Failed to send levels to device", "error", err)
+	}
+}
+
+// sliderLevels returns the loudest peak among each slider's sessions, indexed by slider.
+// sliders without sessions that can be metered stay at 0
+func (lm *levelMeter) sliderLevels() []float32 {
+	sessions := lm.reeemiks.sessions
+	levels := []float32{}
+
+	// metering can mean opening peak streams, which mustn't hold up slider moves waiting on the mapping's lock
+	for sliderIdx, targets := range sessions.activeSliderMapping().entries() {
+		for len(levels) <= sliderIdx {
+			levels = append(levels, 0)
+		}
+
+		for _, session := range sessions.resolveSessions(targets) {
+			if metered, ok := session.(peakSession); ok {
+				if peak := metered.Peak(); peak > levels[sliderIdx] {
+					levels[sliderIdx] = peak
+				}
+			}
+		}
+	}
+
+	return levels
+}
+
+func levelsChanged(previous []float32, current []float32) bool {
+	if len(previous) != len(current) {
+		return true
+	}
+
+	for idx := range current {
+		if diff := current[idx] - previous[idx]; diff >= levelMeterThreshold || diff <= -levelMeterThreshold {
+			return true
+		}
+	}
+
+	return false
+}
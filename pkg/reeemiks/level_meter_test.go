@@ -0,0 +1,65 @@
+package reeemiks
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// meteredSession is a fakeSession with a peak level, which checks the slider mapping isn't locked while it's metered
+type meteredSession struct {
+	fakeSession
+	peak float32
+
+	mapping   *sliderMap
+	lockFree  bool
+	checkLock sync.Mutex
+}
+
+func (s *meteredSession) Peak() float32 {
+	s.checkLock.Lock()
+	defer s.checkLock.Unlock()
+
+	if locker, ok := s.mapping.lock.(*sync.Mutex); ok && locker.TryLock() {
+		locker.Unlock()
+		s.lockFree = true
+	}
+
+	return s.peak
+}
+
+func TestSliderLevels(t *testing.T) {
+	d := newTestReeemiks(t, slowSessionFinder{})
+
+	userConfig := newUserConfigViper()
+	if err := userConfig.ReadConfig(strings.NewReader("slider_mapping:\n  0: spotify\n  2: [discord, firefox]\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := d.config.populateFromVipers(userConfig, newInternalConfigViper())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.config.current.Store(snapshot)
+
+	spotify := &meteredSession{fakeSession: fakeSession{key: "spotify"}, peak: 0.4, mapping: snapshot.SliderMapping}
+	discord := &meteredSession{fakeSession: fakeSession{key: "discord"}, peak: 0.2, mapping: snapshot.SliderMapping}
+	firefox := &meteredSession{fakeSession: fakeSession{key: "firefox"}, peak: 0.7, mapping: snapshot.SliderMapping}
+
+	for _, session := range []Session{spotify, discord, firefox} {
+		d.sessions.add(session)
+	}
+
+	levels := newLevelMeter(d, zap.NewNop().Sugar()).sliderLevels()
+
+	if len(levels) != 3 || levels[0] != 0.4 || levels[1] != 0 || levels[2] != 0.7 {
+		t.Errorf("expected each slider's loudest session, got %v", levels)
+	}
+
+	if !spotify.lockFree || !firefox.lockFree {
+		t.Error("expected sessions to be metered without the slider mapping locked")
+	}
+}
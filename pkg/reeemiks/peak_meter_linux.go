@@ -0,0 +1,263 @@
+package reeemiks
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jfreymuth/pulse/proto"
+	"go.uber.org/zap"
+)
+
+// paPeakMeter opens a peak-detecting monitor stream for every session that gets metered, on a connection of its own
+// since recorded data arrives through the client's callbacks. streams are only opened once something asks for a
+// session's peak, and closed again once nothing has for a while (usually because the session is gone)
+type paPeakMeter struct {
+	logger *zap.SugaredLogger
+	server string
+	cookie string
+
+	client  *paConnection
+	streams map[string]*paPeakStream
+	lock    sync.Mutex
+
+	// the open streams by their index, for the client's callbacks to find. it has a lock of its own since
+	// the callbacks must never wait on the meter's lock: the meter holds it while making requests, and their
+	// replies come in on the same goroutine as the callbacks
+	recording     map[uint32]*paPeakStream
+	recordingLock sync.Mutex
+}
+
+type paPeakStream struct {
+	index    uint32
+	open     bool // false if it couldn't be opened, it's retried after peakStreamRetryDelay
+	failedAt time.Time
+	lastRead time.Time
+
+	// written from the client's callbacks
+	peak     float32
+	lastData time.Time
+	peakLock sync.Mutex
+}
+
+const (
+
+	// peak detection hands us one value per sample, which makes this the meter's update rate
+	peakMeterSampleRate = 25
+
+	// streams nobody has read from for this long get closed
+	peakStreamExpiry = 5 * time.Second
+
+	// how long to wait before trying to open a stream that failed to open again
+	peakStreamRetryDelay = 2 * time.Second
+
+	// corked streams stop sending samples rather than sending silence, so a peak this old has gone quiet
+	peakStaleAfter = 4 * time.Second / peakMeterSampleRate
+)
+
+func newPAPeakMeter(logger *zap.SugaredLogger, server string, cookie string) *paPeakMeter {
+	return &paPeakMeter{
+		logger:    logger.Named("peak_meter"),
+		server:    server,
+		cookie:    cookie,
+		streams:   make(map[string]*paPeakStream),
+		recording: make(map[uint32]*paPeakStream),
+	}
+}
+
+// peak returns the latest peak of the stream identified by key, opening it first if needed.
+// locate is only called to open it, and returns the source to record from and the sink input to single out (if any)
+func (pm *paPeakMeter) peak(key string, locate func() (uint32, uint32, error)) float32 {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+
+	pm.expireStreams()
+
+	ps, ok := pm.streams[key]
+	if !ok {
+		ps = &paPeakStream{}
+		pm.streams[key] = ps
+	}
+
+	ps.lastRead = time.Now()
+
+	if !ps.open {
+		if ok && time.Since(ps.failedAt) < peakStreamRetryDelay {
+			return 0
+		}
+
+		if err := pm.open(ps, locate); err != nil {
+			pm.logger.Warnw("Failed to open peak meter stream", "stream", key, "error", err)
+			ps.failedAt = time.Now()
+		}
+
+		return 0
+	}
+
+	ps.peakLock.Lock()
+	defer ps.peakLock.Unlock()
+
+	if time.Since(ps.lastData) > peakStaleAfter {
+		ps.peak = 0
+	}
+
+	return ps.peak
+}
+
+// open connects to the server if we aren't yet, and starts recording the located stream. the lock must be held
+func (pm *paPeakMeter) open(ps *paPeakStream, locate func() (uint32, uint32, error)) error {
+	if pm.client == nil {
+		client := newPAConnection(pm.logger, "Reeemiks level meter", pm.server, pm.cookie, pm.handleServerEvent)
+		if err := client.connect(); err != nil {
+			return fmt.Errorf("connect to PulseAudio: %w", err)
+		}
+
+		pm.client = client
+	}
+
+	sourceIndex, sinkInputIndex, err := locate()
+	if err != nil {
+		return fmt.Errorf("locate stream: %w", err)
+	}
+
+	request := proto.CreateRecordStream{
+		SampleSpec:      proto.SampleSpec{Format: proto.FormatFloat32LE, Channels: 1, Rate: peakMeterSampleRate},
+		ChannelMap:      proto.ChannelMap{proto.ChannelMono},
+		SourceIndex:     sourceIndex,
+		BufferMaxLength: proto.Undefined,
+		BufferFragSize:  proto.Undefined,
+
+		// with peak detection on, every sample is already the peak of its slice of time
+		PeakDetect:             true,
+		DirectOnInputIndex:     sinkInputIndex,
+		DontInhibitAutoSuspend: true,
+		Properties: proto.PropList{
+			"media.name": proto.PropListString("Peak meter"),
+		},
+		ChannelVolumes: proto.ChannelVolumes{maxVolume},
+	}
+
+	reply := proto.CreateRecordStreamReply{}
+	if err := pm.client.Request(&request, &reply); err != nil {
+
+		// the next attempt gets a fresh connection, the streams on this one are gone with it
+		if errors.Is(err, errPulseDisconnected) {
+			pm.disconnect()
+		}
+
+		return fmt.Errorf("create record stream: %w", err)
+	}
+
+	ps.index = reply.StreamIndex
+	ps.open = true
+
+	pm.recordingLock.Lock()
+	pm.recording[ps.index] = ps
+	pm.recordingLock.Unlock()
+
+	return nil
+}
+
+// handleServerEvent takes the samples of our streams. it runs on the client's read goroutine
+func (pm *paPeakMeter) handleServerEvent(message interface{}) {
+	packet, ok := message.(*proto.DataPacket)
+	if !ok {
+		return
+	}
+
+	pm.recordingLock.Lock()
+	ps, ok := pm.recording[packet.StreamIndex]
+	pm.recordingLock.Unlock()
+
+	if !ok {
+		return
+	}
+
+	var peak float32
+	for i := 0; i+4 <= len(packet.Data); i += 4 {
+		sample := math.Float32frombits(binary.LittleEndian.Uint32(packet.Data[i:]))
+		if sample < 0 {
+			sample = -sample
+		}
+
+		if sample > peak {
+			peak = sample
+		}
+	}
+
+	ps.peakLock.Lock()
+	ps.peak = peak
+	ps.lastData = time.Now()
+	ps.peakLock.Unlock()
+}
+
+// expireStreams closes every stream nobody read from lately. the lock must be held
+func (pm *paPeakMeter) expireStreams() {
+	for key, ps := range pm.streams {
+		if time.Since(ps.lastRead) < peakStreamExpiry {
+			continue
+		}
+
+		if ps.open {
+			pm.recordingLock.Lock()
+			delete(pm.recording, ps.index)
+			pm.recordingLock.Unlock()
+
+			if err := pm.client.Request(&proto.DeleteRecordStream{StreamIndex: ps.index}, nil); err != nil {
+				pm.logger.Debugw("Failed to close peak meter stream", "stream", key, "error", err)
+			}
+		}
+
+		delete(pm.streams, key)
+	}
+}
+
+// disconnect drops the connection along with every stream on it. the lock must be held
+func (pm *paPeakMeter) disconnect() {
+	if pm.client == nil {
+		return
+	}
+
+	pm.client.close()
+	pm.client = nil
+	pm.streams = make(map[string]*paPeakStream)
+
+	pm.recordingLock.Lock()
+	pm.recording = make(map[uint32]*paPeakStream)
+	pm.recordingLock.Unlock()
+}
+
+func (pm *paPeakMeter) release() {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+
+	pm.disconnect()
+}
+
+// locates the monitor of a sink
+func sinkMonitor(client *paConnection, sinkIndex uint32) (uint32, uint32, error) {
+	reply := proto.GetSinkInfoReply{}
+	if err := client.Request(&proto.GetSinkInfo{SinkIndex: sinkIndex}, &reply); err != nil {
+		return 0, 0, fmt.Errorf("get sink info: %w", err)
+	}
+
+	return reply.MonitorSourceIndex, proto.Undefined, nil
+}
+
+// locates the monitor of the sink a sink input plays on, narrowed down to just that sink input
+func sinkInputMonitor(client *paConnection, sinkInputIndex uint32) (uint32, uint32, error) {
+	reply := proto.GetSinkInputInfoReply{}
+	if err := client.Request(&proto.GetSinkInputInfo{SinkInputIndex: sinkInputIndex}, &reply); err != nil {
+		return 0, 0, fmt.Errorf("get sink input info: %w", err)
+	}
+
+	sourceIndex, _, err := sinkMonitor(client, reply.SinkIndex)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return sourceIndex, sinkInputIndex, nil
+}
@@ -0,0 +1,47 @@
+package reeemiks
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jfreymuth/pulse/proto"
+	"go.uber.org/zap"
+)
+
+func peakPacket(index uint32, samples ...float32) *proto.DataPacket {
+	data := make([]byte, 4*len(samples))
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(sample))
+	}
+
+	return &proto.DataPacket{StreamIndex: index, Data: data}
+}
+
+func TestPAPeakMeterReadsSamples(t *testing.T) {
+	pm := newPAPeakMeter(zap.NewNop().Sugar(), "", "")
+
+	ps := &paPeakStream{index: 7, open: true, lastRead: time.Now()}
+	pm.streams["sink-input:1"] = ps
+	pm.recording[7] = ps
+
+	locate := func() (uint32, uint32, error) {
+		t.Fatal("expected an open stream not to be located again")
+		return 0, 0, nil
+	}
+
+	pm.handleServerEvent(peakPacket(7, 0.25, -0.75, 0.5))
+	pm.handleServerEvent(peakPacket(8, 1))
+
+	if peak := pm.peak("sink-input:1", locate); peak != 0.75 {
+		t.Errorf("expected a peak of 0.75, got %.2f", peak)
+	}
+
+	// a stream that stops sending has gone quiet
+	time.Sleep(peakStaleAfter + 10*time.Millisecond)
+
+	if peak := pm.peak("sink-input:1", locate); peak != 0 {
+		t.Errorf("expected a stale peak to read as 0, got %.2f", peak)
+	}
+}
@@ -0,0 +1,83 @@
+package reeemiks
+
+import "math"
+
+const (
+
+	// how close a slider has to get to its targets' level to pick them up, without having to cross it
+	defaultPickupTolerance = 0.02
+
+	// audio backends round volumes a little, so anything smaller than this isn't someone else changing them
+	pickupDriftThreshold = 0.01
+)
+
+// armPickup makes every slider we know the position of wait for soft takeover. call this whenever the targets
+// underneath the sliders change, so that the next move doesn't make their volume jump to wherever the slider is
+func (m *sessionMap) armPickup() {
+	m.layerLock.Lock()
+	defer m.layerLock.Unlock()
+
+	for sliderIdx := range m.sliderPositions {
+		m.pickupArmed[sliderIdx] = true
+	}
+}
+
+// pickedUp records a slider's new position and returns whether the move should reach the slider's targets.
+// a slider waiting for soft takeover only does once its position has crossed (or come within tolerance of)
+// its targets' level. sliders in pickup mode also start waiting whenever their targets' volume was changed
+// by something else since they last set it
+func (m *sessionMap) pickedUp(event SliderMoveEvent, targets []string, settings sliderSettings) bool {
+	m.layerLock.Lock()
+	defer m.layerLock.Unlock()
+
+	sliderIdx, position := event.SliderID, event.PercentValue
+
+	previous, known := m.sliderPositions[sliderIdx]
+	m.sliderPositions[sliderIdx] = position
+
+	// relative moves start out from the targets' current level, so they can't make anything jump
+	if event.Relative {
+		delete(m.pickupArmed, sliderIdx)
+		m.sliderLevels[sliderIdx] = position
+
+		return true
+	}
+
+	if !m.pickupArmed[sliderIdx] && !settings.Pickup {
+		m.sliderLevels[sliderIdx] = position
+		return true
+	}
+
+	sessions := m.resolveSessions(targets)
+
+	// nothing to jump, so nothing to wait for
+	if len(sessions) == 0 {
+		delete(m.pickupArmed, sliderIdx)
+		return true
+	}
+
+	level := m.sliderLevel(sliderIdx, settings, sessions)
+
+	// in pickup mode, the slider lets go as soon as its targets aren't where it left them
+	if settings.Pickup {
+		if lastLevel, ok := m.sliderLevels[sliderIdx]; !ok || math.Abs(float64(level-lastLevel)) > pickupDriftThreshold {
+			m.pickupArmed[sliderIdx] = true
+		}
+	}
+
+	if m.pickupArmed[sliderIdx] {
+		reached := math.Abs(float64(position-level)) <= settings.PickupTolerance
+		crossed := known && (previous-level)*(position-level) <= 0
+
+		if !reached && !crossed {
+			return false
+		}
+
+		m.logger.Debugw("Slider picked up its targets", "slider", sliderIdx, "level", level)
+		delete(m.pickupArmed, sliderIdx)
+	}
+
+	m.sliderLevels[sliderIdx] = position
+
+	return true
+}
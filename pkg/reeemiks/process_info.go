@@ -0,0 +1,110 @@
+package reeemiks
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+// processInfo describes the process behind a session, for targets that pick sessions by where they come from
+// rather than by name: a game started by steam, a browser's audio helper, everything in a systemd scope
+type processInfo struct {
+	pid int
+
+	// the process's own binary name first, then its parent's and so on up the tree (init excluded)
+	tree []string
+
+	// the PIDs above it, parent first (init excluded)
+	ancestors []int
+
+	// the systemd unit the process runs in, like app-firefox-1234.scope, and its Flatpak app id if it's sandboxed
+	unit      string
+	flatpakID string
+}
+
+// targets that match on a session's process, followed by a name or a shell pattern: process:steam,
+// scope:app-firefox-*.scope, flatpak:org.mozilla.firefox. pid: takes a single process id instead, pid:4312
+const (
+	processTargetPrefix = "process:"
+	scopeTargetPrefix   = "scope:"
+	flatpakTargetPrefix = "flatpak:"
+	pidTargetPrefix     = "pid:"
+)
+
+// parseProcessTarget splits a (lowercase) process target into its prefix and pattern
+func parseProcessTarget(target string) (string, string, bool) {
+	for _, prefix := range []string{processTargetPrefix, scopeTargetPrefix, flatpakTargetPrefix, pidTargetPrefix} {
+		pattern, ok := strings.CutPrefix(target, prefix)
+		if !ok || pattern == "" {
+			continue
+		}
+
+		if prefix == pidTargetPrefix {
+			if pid, err := strconv.Atoi(pattern); err != nil || pid <= 0 {
+				return "", "", false
+			}
+		}
+
+		return prefix, pattern, true
+	}
+
+	return "", "", false
+}
+
+// matches tells whether a process target picks this process. process: and pid: match the process or any of its
+// ancestors, which is what groups a helper's audio under the app that started it
+func (p *processInfo) matches(prefix string, pattern string) bool {
+	if p == nil {
+		return false
+	}
+
+	switch prefix {
+	case processTargetPrefix:
+		for _, name := range p.tree {
+			if targetPatternMatches(pattern, name) {
+				return true
+			}
+		}
+
+	case scopeTargetPrefix:
+		return targetPatternMatches(pattern, p.unit)
+
+	case flatpakTargetPrefix:
+		return targetPatternMatches(pattern, p.flatpakID)
+
+	case pidTargetPrefix:
+		pid, _ := strconv.Atoi(pattern)
+		if p.pid == pid {
+			return true
+		}
+
+		for _, ancestor := range p.ancestors {
+			if ancestor == pid {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// parent returns the name of the process that started this one, if we know it
+func (p *processInfo) parent() string {
+	if p == nil || len(p.tree) < 2 {
+		return ""
+	}
+
+	return p.tree[1]
+}
+
+func targetPatternMatches(pattern string, value string) bool {
+	if value == "" {
+		return false
+	}
+
+	value = strings.ToLower(value)
+
+	// a broken pattern can still match literally
+	matched, err := path.Match(pattern, value)
+	return matched || (err != nil && pattern == value)
+}
@@ -0,0 +1,187 @@
+package reeemiks
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mitchellh/go-ps"
+
+	"github.com/Red-M/ReeeMiks/pkg/reeemiks/util"
+)
+
+// Flatpak starts every app in a scope named after it, which works even when .flatpak-info can't be read
+var flatpakScopePattern = regexp.MustCompile(`^app-flatpak-(.+)-\d+\.scope$`)
+
+// processInfoCache keeps what we read about every process we've seen, since sessions are looked up again on every
+// refresh. entries are keyed by PID and checked against the process's start time, so a reused PID isn't mistaken
+// for the process that had it before
+type processInfoCache struct {
+	entries map[int]processInfoCacheEntry
+	lock    sync.Mutex
+}
+
+type processInfoCacheEntry struct {
+	startTime string
+	info      *processInfo
+}
+
+// once there are this many entries, the ones for processes that are gone are dropped
+const processInfoCacheSize = 256
+
+var processInfos = &processInfoCache{entries: make(map[int]processInfoCacheEntry)}
+
+// readProcessInfo looks a process up in /proc, or in the cache if we've seen it before.
+// a PID of 0 (unknown) or one that's gone by now gives nil
+func readProcessInfo(pid int) *processInfo {
+	if pid <= 0 {
+		return nil
+	}
+
+	startTime, ok := processStartTime(pid)
+	if !ok {
+		return nil
+	}
+
+	processInfos.lock.Lock()
+	defer processInfos.lock.Unlock()
+
+	if entry, ok := processInfos.entries[pid]; ok && entry.startTime == startTime {
+		return entry.info
+	}
+
+	info := lookUpProcessInfo(pid)
+	if info == nil {
+		return nil
+	}
+
+	if len(processInfos.entries) >= processInfoCacheSize {
+		for cachedPID, entry := range processInfos.entries {
+			if current, ok := processStartTime(cachedPID); !ok || current != entry.startTime {
+				delete(processInfos.entries, cachedPID)
+			}
+		}
+	}
+
+	processInfos.entries[pid] = processInfoCacheEntry{startTime: startTime, info: info}
+
+	return info
+}
+
+// processStartTime reads when a process started (in clock ticks since boot) from /proc/<pid>/stat
+func processStartTime(pid int) (string, bool) {
+	contents, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return "", false
+	}
+
+	// the process name comes in parentheses and may contain anything, the fields after it start with the state
+	closing := bytes.LastIndexByte(contents, ')')
+	if closing == -1 {
+		return "", false
+	}
+
+	fields := strings.Fields(string(contents[closing+1:]))
+	if len(fields) < 20 {
+		return "", false
+	}
+
+	return fields[19], true
+}
+
+func lookUpProcessInfo(pid int) *processInfo {
+	name, ok := util.ProcessName(pid)
+	if !ok {
+		return nil
+	}
+
+	info := &processInfo{
+		pid:  pid,
+		tree: []string{name},
+		unit: processUnit(pid),
+	}
+
+	// walk up to (but not including) init, guarding against loops from PIDs reused mid-walk
+	seen := map[int]bool{pid: true}
+
+	for current := pid; ; {
+		process, err := ps.FindProcess(current)
+		if err != nil || process == nil || process.PPid() <= 1 || seen[process.PPid()] {
+			break
+		}
+
+		current = process.PPid()
+		seen[current] = true
+		info.ancestors = append(info.ancestors, current)
+
+		if parentName, ok := util.ProcessName(current); ok {
+			info.tree = append(info.tree, parentName)
+		}
+	}
+
+	info.flatpakID = processFlatpakID(pid, info.unit)
+
+	return info
+}
+
+// processUnit returns the systemd scope or service a process belongs to, from its cgroup path
+func processUnit(pid int) string {
+	contents, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return ""
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+
+	for scanner.Scan() {
+
+		// cgroup v2 has a single "0::/path" line, v1 the systemd hierarchy is the one we want
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 || (parts[0] != "0" && parts[1] != "name=systemd") {
+			continue
+		}
+
+		elements := strings.Split(parts[2], "/")
+
+		for idx := len(elements) - 1; idx >= 0; idx-- {
+			if strings.HasSuffix(elements[idx], ".scope") || strings.HasSuffix(elements[idx], ".service") {
+				return elements[idx]
+			}
+		}
+	}
+
+	return ""
+}
+
+// processFlatpakID reads the app id Flatpak leaves in every sandbox, falling back to the name of its scope
+func processFlatpakID(pid int, unit string) string {
+	contents, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "root", ".flatpak-info"))
+	if err == nil {
+		section := ""
+		scanner := bufio.NewScanner(bytes.NewReader(contents))
+
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+
+			if strings.HasPrefix(line, "[") {
+				section = line
+				continue
+			}
+
+			if name, ok := strings.CutPrefix(line, "name="); ok && section == "[Application]" {
+				return name
+			}
+		}
+	}
+
+	if match := flatpakScopePattern.FindStringSubmatch(unit); match != nil {
+		return match[1]
+	}
+
+	return ""
+}
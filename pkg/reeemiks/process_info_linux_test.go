@@ -0,0 +1,55 @@
+package reeemiks
+
+import (
+	"os"
+	"testing"
+)
+
+func TestReadProcessInfo(t *testing.T) {
+	pid := os.Getpid()
+
+	info := readProcessInfo(pid)
+	if info == nil {
+		t.Fatal("expected to read our own process")
+	}
+
+	if info.pid != pid || len(info.tree) == 0 {
+		t.Errorf("unexpected process info %+v", info)
+	}
+
+	if len(info.ancestors) > 0 && info.ancestors[0] != os.Getppid() {
+		t.Errorf("expected our parent %d first, got %v", os.Getppid(), info.ancestors)
+	}
+
+	if cached := readProcessInfo(pid); cached != info {
+		t.Error("expected the second lookup to come from the cache")
+	}
+
+	// a PID that now belongs to a process started at another time is looked up again
+	processInfos.lock.Lock()
+	processInfos.entries[pid] = processInfoCacheEntry{startTime: "0", info: &processInfo{pid: pid}}
+	processInfos.lock.Unlock()
+
+	if fresh := readProcessInfo(pid); fresh == nil || len(fresh.tree) == 0 {
+		t.Errorf("expected a reused PID to be read again, got %+v", fresh)
+	}
+
+	if info := readProcessInfo(0); info != nil {
+		t.Errorf("expected nothing for an unknown PID, got %+v", info)
+	}
+}
+
+func TestProcessStartTime(t *testing.T) {
+	first, ok := processStartTime(os.Getpid())
+	if !ok || first == "" {
+		t.Fatal("expected to read our own start time")
+	}
+
+	if second, _ := processStartTime(os.Getpid()); second != first {
+		t.Errorf("expected the start time to stay put, got %s and %s", first, second)
+	}
+
+	if _, ok := processStartTime(-1); ok {
+		t.Error("expected no start time for a process that doesn't exist")
+	}
+}
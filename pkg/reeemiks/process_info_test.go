@@ -0,0 +1,65 @@
+package reeemiks
+
+import (
+	"testing"
+)
+
+func TestParseProcessTarget(t *testing.T) {
+	tests := []struct {
+		target  string
+		prefix  string
+		pattern string
+		ok      bool
+	}{
+		{"process:steam", processTargetPrefix, "steam", true},
+		{"scope:app-firefox-*.scope", scopeTargetPrefix, "app-firefox-*.scope", true},
+		{"flatpak:org.mozilla.firefox", flatpakTargetPrefix, "org.mozilla.firefox", true},
+		{"pid:4312", pidTargetPrefix, "4312", true},
+		{"pid:firefox", "", "", false},
+		{"pid:0", "", "", false},
+		{"process:", "", "", false},
+		{"firefox", "", "", false},
+	}
+
+	for _, test := range tests {
+		prefix, pattern, ok := parseProcessTarget(test.target)
+		if prefix != test.prefix || pattern != test.pattern || ok != test.ok {
+			t.Errorf("%s: expected %q %q %v, got %q %q %v", test.target, test.prefix, test.pattern, test.ok, prefix, pattern, ok)
+		}
+	}
+}
+
+func TestProcessInfoMatches(t *testing.T) {
+	info := &processInfo{
+		pid:       4312,
+		tree:      []string{"wine64-preloader", "steam", "bash"},
+		ancestors: []int{4000, 1200},
+		unit:      "app-steam-1200.scope",
+	}
+
+	tests := []struct {
+		target  string
+		matches bool
+	}{
+		{"process:steam", true},
+		{"process:wine*", true},
+		{"process:lutris", false},
+		{"scope:app-steam-*.scope", true},
+		{"flatpak:com.valvesoftware.steam", false},
+		{"pid:4312", true},
+		{"pid:1200", true},
+		{"pid:4313", false},
+	}
+
+	for _, test := range tests {
+		prefix, pattern, _ := parseProcessTarget(test.target)
+		if matches := info.matches(prefix, pattern); matches != test.matches {
+			t.Errorf("%s: expected %v, got %v", test.target, test.matches, matches)
+		}
+	}
+
+	var unknown *processInfo
+	if unknown.matches(pidTargetPrefix, "4312") {
+		t.Error("expected a session without a process not to match")
+	}
+}
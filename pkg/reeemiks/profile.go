@@ -0,0 +1,47 @@
+package reeemiks
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+
+	// switching to this "profile" moves on to the next one instead, wrapping around at the end
+	profileNext = "next"
+
+	// switching to this "profile" hands the choice back to the automatic profile rules
+	profileAuto = "auto"
+)
+
+// switchProfile activates the named profile (or the next one) and lets the user know it happened.
+// picking a profile by hand pauses automatic switching until the user switches to "auto"
+func (d *Reeemiks) switchProfile(name string) error {
+	if strings.EqualFold(name, profileAuto) {
+		d.autoProfiles.release()
+		d.notifier.Notify("Automatic profile switching resumed", "Profiles will follow your running applications again.")
+
+		return nil
+	}
+
+	if strings.EqualFold(name, profileNext) {
+		name = d.config.NextProfile()
+	}
+
+	// lock before switching, so the reload that follows already sees the override.
+	// a typo shouldn't pause automatic switching though, so only lock for profiles that exist
+	if containsTarget(d.config.snapshot().Profiles, name) {
+		d.autoProfiles.overrideManually()
+	}
+
+	if err := d.config.SetActiveProfile(name, true); err != nil {
+		d.logger.Warnw("Failed to switch profile", "profile", name, "error", err)
+		d.notifier.Notify("Failed to switch profile!", err.Error())
+
+		return fmt.Errorf("switch profile: %w", err)
+	}
+
+	d.notifier.Notify("Profile switched!", fmt.Sprintf("Now using the %s profile.", d.config.snapshot().ActiveProfile))
+
+	return nil
+}
@@ -0,0 +1,367 @@
+package reeemiks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jfreymuth/pulse/proto"
+	"go.uber.org/zap"
+)
+
+// paConnection is our end of the connection to a PulseAudio server. it notices the moment the server goes away
+// (pipewire-pulse restarts on every PipeWire upgrade) and reconnects with a growing delay until it's back.
+// requests made while it's down fail right away instead of hanging
+type paConnection struct {
+	logger *zap.SugaredLogger
+	name   string
+	server string
+	cookie string
+
+	// handed every message from the server that isn't a reply, on every connection we make
+	callback func(interface{})
+
+	client *proto.Client
+	conn   net.Conn
+	closed chan bool
+	lock   sync.Mutex
+
+	stopChannel chan bool
+	stopOnce    sync.Once
+}
+
+// paWatchedConn closes its channel once reading from the server fails, which is how a lost connection shows up
+type paWatchedConn struct {
+	net.Conn
+
+	closed    chan bool
+	closeOnce sync.Once
+}
+
+const (
+	paReconnectMinDelay = 500 * time.Millisecond
+	paReconnectMaxDelay = 30 * time.Second
+
+	paDialTimeout = 5 * time.Second
+
+	// servers started with auth-anonymous=1 take any cookie of the right size
+	paCookieSize = 256
+
+	// what module-native-protocol-tcp listens on unless told otherwise
+	paDefaultPort = "4713"
+)
+
+var errPulseDisconnected = errors.New("not connected to the PulseAudio server")
+
+func newPAConnection(logger *zap.SugaredLogger, name string, server string, cookie string, callback func(interface{})) *paConnection {
+	return &paConnection{
+		logger:      logger.Named("pulse"),
+		name:        name,
+		server:      server,
+		cookie:      cookie,
+		callback:    callback,
+		stopChannel: make(chan bool),
+	}
+}
+
+// connect dials the server, authenticates and introduces us
+func (c *paConnection) connect() error {
+	client, conn, err := dialPulse(c.server, c.cookie, c.callback)
+	if err != nil {
+		return err
+	}
+
+	request := proto.SetClientName{
+		Props: proto.PropList{
+			"application.name": proto.PropListString(c.name),
+		},
+	}
+
+	if err := paRequest(client, conn.closed, &request, &proto.SetClientNameReply{}); err != nil {
+		conn.Close()
+		return fmt.Errorf("set client name: %w", err)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// closed while we were connecting
+	select {
+	case <-c.stopChannel:
+		conn.Close()
+		return errPulseDisconnected
+	default:
+	}
+
+	c.client = client
+	c.conn = conn
+	c.closed = conn.closed
+
+	return nil
+}
+
+// markDisconnected sets the connection up as one that was just lost, so maintain starts trying to connect right away
+func (c *paConnection) markDisconnected() {
+	closed := make(chan bool)
+	close(closed)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.client = nil
+	c.closed = closed
+}
+
+// connected tells whether there's a connection to make requests on
+func (c *paConnection) connected() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.client != nil
+}
+
+// Request sends a request on the current connection, failing right away while there isn't one
+func (c *paConnection) Request(request proto.RequestArgs, reply proto.Reply) error {
+	c.lock.Lock()
+	client, closed := c.client, c.closed
+	c.lock.Unlock()
+
+	if client == nil {
+		return errPulseDisconnected
+	}
+
+	return paRequest(client, closed, request, reply)
+}
+
+// paRequest gives up on a request as soon as the connection is lost. the client doesn't fail its requests
+// when reading stops: it hands them a nil error at best (which would pass for an empty reply) and leaves them
+// waiting forever at worst, and any request made on it afterwards is never answered either
+func paRequest(client *proto.Client, closed chan bool, request proto.RequestArgs, reply proto.Reply) error {
+	select {
+	case <-closed:
+		return errPulseDisconnected
+	default:
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Request(request, reply)
+	}()
+
+	select {
+	case err := <-done:
+
+		// a nil error from a client that just lost its connection isn't an answer
+		select {
+		case <-closed:
+			return errPulseDisconnected
+		default:
+			return err
+		}
+	case <-closed:
+		return errPulseDisconnected
+	}
+}
+
+// maintain waits for the connection to drop and reconnects, calling reconnected every time it's back
+func (c *paConnection) maintain(reconnected func()) {
+	for {
+		c.lock.Lock()
+		closed := c.closed
+		c.lock.Unlock()
+
+		select {
+		case <-c.stopChannel:
+			return
+		case <-closed:
+		}
+
+		c.lock.Lock()
+		conn := c.conn
+		c.client, c.conn = nil, nil
+		c.lock.Unlock()
+
+		// the client has no way to be closed, but with its socket gone anything still writing through it fails
+		// right away, and the descriptor doesn't linger until the next connection replaces it
+		if conn != nil {
+			conn.Close()
+		}
+
+		c.logger.Warnw("Lost connection to the PulseAudio server, reconnecting", "server", c.server)
+
+		delay := paReconnectMinDelay
+
+		for {
+			select {
+			case <-c.stopChannel:
+				return
+			case <-time.After(delay):
+			}
+
+			err := c.connect()
+			if err == nil {
+				break
+			}
+
+			c.logger.Debugw("Failed to reconnect to the PulseAudio server", "error", err, "retryIn", delay*2)
+
+			delay *= 2
+			if delay > paReconnectMaxDelay {
+				delay = paReconnectMaxDelay
+			}
+		}
+
+		c.logger.Infow("Reconnected to the PulseAudio server", "server", c.server)
+		reconnected()
+	}
+}
+
+func (c *paConnection) close() error {
+	c.stopOnce.Do(func() {
+		close(c.stopChannel)
+	})
+
+	c.lock.Lock()
+	conn := c.conn
+	c.client, c.conn = nil, nil
+	c.lock.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	return conn.Close()
+}
+
+// dialPulse connects to the first server in a server string that answers, like libpulse does.
+// an empty server string means $PULSE_SERVER, or the local server, and an empty cookie path the usual cookie
+func dialPulse(server string, cookiePath string, callback func(interface{})) (*proto.Client, *paWatchedConn, error) {
+	if server == "" {
+		server = os.Getenv("PULSE_SERVER")
+	}
+
+	if server == "" {
+		server = localPulseServer()
+	}
+
+	var lastErr error = errors.New("no valid PulseAudio server address")
+
+	for _, address := range strings.Fields(server) {
+		network, addr, ok := parsePulseAddress(address)
+		if !ok {
+			continue
+		}
+
+		rawConn, err := net.DialTimeout(network, addr, paDialTimeout)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		conn := &paWatchedConn{Conn: rawConn, closed: make(chan bool)}
+
+		// the callback has to be in place before the client starts reading
+		client := &proto.Client{Callback: callback}
+		client.Open(conn)
+
+		cookie, err := pulseCookie(cookiePath)
+		if err != nil {
+			conn.Close()
+			lastErr = err
+			continue
+		}
+
+		reply := proto.AuthReply{}
+		if err := paRequest(client, conn.closed, &proto.Auth{Version: client.Version(), Cookie: cookie}, &reply); err != nil {
+			conn.Close()
+			lastErr = fmt.Errorf("authenticate with %s: %w", address, err)
+			continue
+		}
+
+		client.SetVersion(reply.Version)
+
+		return client, conn, nil
+	}
+
+	return nil, nil, lastErr
+}
+
+func localPulseServer() string {
+	runtimeDir, ok := os.LookupEnv("XDG_RUNTIME_DIR")
+	if !ok {
+		runtimeDir = fmt.Sprint("/run/user/", os.Getuid())
+	}
+
+	return filepath.Join(runtimeDir, "pulse", "native")
+}
+
+// parsePulseAddress splits a single server address, either a socket path or one prefixed by its protocol
+func parsePulseAddress(address string) (string, string, bool) {
+	switch {
+	case strings.HasPrefix(address, "/"):
+		return "unix", address, true
+	case strings.HasPrefix(address, "unix:"):
+		return "unix", strings.TrimPrefix(address, "unix:"), true
+	case strings.HasPrefix(address, "tcp4:"):
+		return "tcp4", withPulsePort(strings.TrimPrefix(address, "tcp4:")), true
+	case strings.HasPrefix(address, "tcp6:"):
+		return "tcp6", withPulsePort(strings.TrimPrefix(address, "tcp6:")), true
+	case strings.HasPrefix(address, "tcp:"):
+		return "tcp", withPulsePort(strings.TrimPrefix(address, "tcp:")), true
+	}
+
+	return "", "", false
+}
+
+// withPulsePort adds the default port to a host given without one, bracketed IPv6 addresses included
+func withPulsePort(host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+
+	return net.JoinHostPort(strings.Trim(host, "[]"), paDefaultPort)
+}
+
+// pulseCookie reads the cookie we authenticate with, from the given path or else wherever libpulse would look.
+// only the usual cookie may be missing (servers with auth-anonymous=1 don't hand one out), a cookie that was
+// asked for by name has to be there
+func pulseCookie(cookiePath string) ([]byte, error) {
+	fallback := false
+
+	if cookiePath == "" {
+		var ok bool
+		if cookiePath, ok = os.LookupEnv("PULSE_COOKIE"); !ok {
+			cookiePath = filepath.Join(os.Getenv("HOME"), ".config", "pulse", "cookie")
+			fallback = true
+		}
+	} else if rest, ok := strings.CutPrefix(cookiePath, "~/"); ok {
+		cookiePath = filepath.Join(os.Getenv("HOME"), rest)
+	}
+
+	cookie, err := os.ReadFile(cookiePath)
+	if err != nil {
+		if fallback && os.IsNotExist(err) {
+			return make([]byte, paCookieSize), nil
+		}
+
+		return nil, fmt.Errorf("read PulseAudio cookie: %w", err)
+	}
+
+	return cookie, nil
+}
+
+func (c *paWatchedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if err != nil {
+		c.closeOnce.Do(func() {
+			close(c.closed)
+		})
+	}
+
+	return n, err
+}
@@ -0,0 +1,235 @@
+package reeemiks
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jfreymuth/pulse/proto"
+	"go.uber.org/zap"
+)
+
+// fakePulseServer answers every request with a reply holding a single number, which is all authenticating
+// and setting the client name need. it hands the test every connection it accepts
+type fakePulseServer struct {
+	listener net.Listener
+	conns    chan net.Conn
+
+	// stops it answering, while still reading what it's sent
+	silent atomic.Bool
+}
+
+func newFakePulseServer(t *testing.T) *fakePulseServer {
+	t.Helper()
+
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "native"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &fakePulseServer{listener: listener, conns: make(chan net.Conn, 10)}
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			server.conns <- conn
+			go server.serve(conn)
+		}
+	}()
+
+	return server
+}
+
+func (s *fakePulseServer) address() string {
+	return "unix:" + s.listener.Addr().String()
+}
+
+func (s *fakePulseServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	header := make([]byte, 20)
+
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+
+		payload := make([]byte, binary.BigEndian.Uint32(header))
+		if _, err := io.ReadFull(conn, payload); err != nil {
+			return
+		}
+
+		if s.silent.Load() {
+			continue
+		}
+
+		// 'L' op 'L' tag, answered with 'L' reply 'L' tag 'L' 32
+		reply := []byte{'L', 0, 0, 0, 2, 'L', 0, 0, 0, 0, 'L', 0, 0, 0, 32}
+		copy(reply[6:10], payload[6:10])
+
+		response := make([]byte, 20, 20+len(reply))
+		binary.BigEndian.PutUint32(response[0:4], uint32(len(reply)))
+		binary.BigEndian.PutUint32(response[4:8], 0xFFFFFFFF)
+
+		if _, err := conn.Write(append(response, reply...)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakePulseServer) accepted(t *testing.T) net.Conn {
+	t.Helper()
+
+	select {
+	case conn := <-s.conns:
+		return conn
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a connection to the server")
+		return nil
+	}
+}
+
+func setClientName(c *paConnection) error {
+	request := proto.SetClientName{Props: proto.PropList{"application.name": proto.PropListString("test")}}
+	return c.Request(&request, &proto.SetClientNameReply{})
+}
+
+func TestPAConnectionReconnects(t *testing.T) {
+	server := newFakePulseServer(t)
+
+	connection := newPAConnection(zap.NewNop().Sugar(), "test", server.address(), "", func(interface{}) {})
+	if err := connection.connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer connection.close()
+
+	first := server.accepted(t)
+
+	reconnected := make(chan bool, 1)
+	go connection.maintain(func() {
+		reconnected <- true
+	})
+
+	if err := setClientName(connection); err != nil {
+		t.Fatalf("expected the request to be answered, got %v", err)
+	}
+
+	first.Close()
+
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected to reconnect after the server dropped us")
+	}
+
+	server.accepted(t)
+
+	if err := setClientName(connection); err != nil {
+		t.Fatalf("expected the request to be answered after reconnecting, got %v", err)
+	}
+}
+
+func TestPAConnectionFailsWaitingRequests(t *testing.T) {
+	server := newFakePulseServer(t)
+
+	connection := newPAConnection(zap.NewNop().Sugar(), "test", server.address(), "", func(interface{}) {})
+	if err := connection.connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer connection.close()
+
+	conn := server.accepted(t)
+	server.silent.Store(true)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- setClientName(connection)
+	}()
+
+	// let the request reach the server before it goes away
+	time.Sleep(50 * time.Millisecond)
+	conn.Close()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, errPulseDisconnected) {
+			t.Errorf("expected the waiting request to fail as disconnected, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the waiting request to give up once the connection was lost")
+	}
+
+	connection.close()
+
+	if err := setClientName(connection); !errors.Is(err, errPulseDisconnected) {
+		t.Errorf("expected requests to fail right away once closed, got %v", err)
+	}
+}
+
+func TestParsePulseAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		network string
+		addr    string
+	}{
+		{"/run/user/1000/pulse/native", "unix", "/run/user/1000/pulse/native"},
+		{"unix:/tmp/pulse", "unix", "/tmp/pulse"},
+		{"tcp:media-box", "tcp", "media-box:4713"},
+		{"tcp:media-box:4714", "tcp", "media-box:4714"},
+		{"tcp4:192.168.1.5", "tcp4", "192.168.1.5:4713"},
+		{"tcp6:[::1]", "tcp6", "[::1]:4713"},
+		{"tcp6:[::1]:4714", "tcp6", "[::1]:4714"},
+	}
+
+	for _, test := range tests {
+		network, addr, ok := parsePulseAddress(test.address)
+		if !ok || network != test.network || addr != test.addr {
+			t.Errorf("%s: expected %s %s, got %s %s (%v)", test.address, test.network, test.addr, network, addr, ok)
+		}
+	}
+
+	if _, _, ok := parsePulseAddress("{machine-id}unix:/tmp/pulse"); ok {
+		t.Error("expected an address with a machine id in front not to parse")
+	}
+}
+
+func TestPulseCookie(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	// only the usual cookie may be missing
+	cookie, err := pulseCookie("")
+	if err != nil || len(cookie) != paCookieSize {
+		t.Errorf("expected an empty cookie in place of a missing default one, got %d bytes, %v", len(cookie), err)
+	}
+
+	if _, err := pulseCookie("~/mediapc-cookie"); err == nil {
+		t.Error("expected a missing configured cookie to fail")
+	}
+
+	t.Setenv("PULSE_COOKIE", filepath.Join(home, "env-cookie"))
+	if _, err := pulseCookie(""); err == nil {
+		t.Error("expected a missing $PULSE_COOKIE to fail")
+	}
+
+	if err := os.WriteFile(filepath.Join(home, "mediapc-cookie"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if cookie, err := pulseCookie("~/mediapc-cookie"); err != nil || string(cookie) != "secret" {
+		t.Errorf("expected the configured cookie to be read, got %q, %v", cookie, err)
+	}
+}
@@ -0,0 +1,208 @@
+package reeemiks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// multiPASessionFinder puts the sessions of several PulseAudio servers side by side, like a media PC's on top of
+// the local ones. sessions and sinks of every server but the first are prefixed with its namespace (mediapc/spotify),
+// which is also how requests about them find their way back to the right server
+type multiPASessionFinder struct {
+	logger *zap.SugaredLogger
+
+	// the first one is the primary server, the only one without a namespace
+	finders []*paSessionFinder
+
+	stopChannel          chan bool
+	sessionsResetChannel chan bool
+}
+
+var errCrossServerMove = errors.New("can't move audio between PulseAudio servers")
+
+// newPulseSessionFinder connects to every configured PulseAudio server, and only needs the first one to answer
+func newPulseSessionFinder(logger *zap.SugaredLogger, config *CanonicalConfig) (SessionFinder, error) {
+	servers := config.snapshot().PulseServers
+	if len(servers) == 0 {
+		servers = []pulseServer{{}}
+	}
+
+	primary, err := newPASessionFinder(logger, config, servers[0], true)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(servers) == 1 {
+		return primary, nil
+	}
+
+	msf := &multiPASessionFinder{
+		logger:               logger.Named("session_finder"),
+		finders:              []*paSessionFinder{primary},
+		stopChannel:          make(chan bool),
+		sessionsResetChannel: make(chan bool, 1),
+	}
+
+	for _, server := range servers[1:] {
+		sf, err := newPASessionFinder(logger, config, server, false)
+		if err != nil {
+			msf.logger.Warnw("Failed to set up PulseAudio server, leaving it out", "namespace", server.Namespace, "error", err)
+			continue
+		}
+
+		msf.finders = append(msf.finders, sf)
+	}
+
+	for _, sf := range msf.finders {
+		go msf.forwardSessionsReset(sf)
+	}
+
+	msf.logger.Debugw("Created multi-server PA session finder instance", "servers", len(msf.finders))
+
+	return msf, nil
+}
+
+func (msf *multiPASessionFinder) GetAllSessions() ([]Session, error) {
+	sessions := []Session{}
+
+	for _, sf := range msf.finders {
+
+		// a server we lost (or never reached) has nothing to offer until it's back, and saying so every time is noise
+		if !sf.primary && !sf.client.connected() {
+			continue
+		}
+
+		serverSessions, err := sf.GetAllSessions()
+		if err != nil {
+			if sf.primary {
+				return nil, err
+			}
+
+			msf.logger.Warnw("Failed to get audio sessions from PulseAudio server", "namespace", sf.server.Namespace, "error", err)
+			continue
+		}
+
+		sessions = append(sessions, serverSessions...)
+	}
+
+	return sessions, nil
+}
+
+func (msf *multiPASessionFinder) Release() error {
+	close(msf.stopChannel)
+
+	var result error
+
+	for _, sf := range msf.finders {
+		if err := sf.Release(); err != nil && result == nil {
+			result = err
+		}
+	}
+
+	msf.logger.Debug("Released multi-server PA session finder instance")
+
+	return result
+}
+
+func (msf *multiPASessionFinder) sessionsReset() <-chan bool {
+	return msf.sessionsResetChannel
+}
+
+// forwardSessionsReset passes on a server's sessions going stale. the session map starts over from every server's
+// sessions either way, which is also how a server that came back for the first time gets its sessions in
+func (msf *multiPASessionFinder) forwardSessionsReset(sf *paSessionFinder) {
+	for {
+		select {
+		case <-msf.stopChannel:
+			return
+		case <-sf.sessionsReset():
+			select {
+			case msf.sessionsResetChannel <- true:
+			default:
+			}
+		}
+	}
+}
+
+// virtual devices only ever live on the primary server
+func (msf *multiPASessionFinder) syncVirtualDevices() error {
+	return msf.finders[0].syncVirtualDevices()
+}
+
+// finderFor picks the server a namespaced name belongs to, and returns the name as that server knows it
+func (msf *multiPASessionFinder) finderFor(name string) (*paSessionFinder, string) {
+	for _, sf := range msf.finders[1:] {
+		if len(name) > len(sf.namespace) && strings.EqualFold(name[:len(sf.namespace)], sf.namespace) {
+			return sf, name[len(sf.namespace):]
+		}
+	}
+
+	return msf.finders[0], name
+}
+
+func (msf *multiPASessionFinder) resolveSink(sink string) (string, error) {
+	sf, name := msf.finderFor(sink)
+
+	resolved, err := sf.resolveSink(name)
+	if err != nil {
+		return "", err
+	}
+
+	return sf.namespace + resolved, nil
+}
+
+func (msf *multiPASessionFinder) sinkNames() ([]string, error) {
+	names := []string{}
+
+	for _, sf := range msf.finders {
+		if !sf.client.connected() {
+			continue
+		}
+
+		serverNames, err := sf.sinkNames()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range serverNames {
+			names = append(names, sf.namespace+name)
+		}
+	}
+
+	return names, nil
+}
+
+// the primary server's default sink is the one that counts, the others have defaults of their own
+func (msf *multiPASessionFinder) defaultSink() (string, error) {
+	return msf.finders[0].defaultSink()
+}
+
+func (msf *multiPASessionFinder) setDefaultSink(name string) error {
+	sf, name := msf.finderFor(name)
+	return sf.setDefaultSink(name)
+}
+
+func (msf *multiPASessionFinder) sessionSink(session Session) (string, error) {
+	sf, _ := msf.finderFor(session.Key())
+
+	name, err := sf.sessionSink(session)
+	if err != nil {
+		return "", err
+	}
+
+	return sf.namespace + name, nil
+}
+
+func (msf *multiPASessionFinder) moveSession(session Session, name string) error {
+	sessionFinder, _ := msf.finderFor(session.Key())
+	sinkFinder, name := msf.finderFor(name)
+
+	if sessionFinder != sinkFinder {
+		return fmt.Errorf("move %s to %s: %w", session.Key(), sinkFinder.namespace+name, errCrossServerMove)
+	}
+
+	return sinkFinder.moveSession(session, name)
+}
@@ -0,0 +1,217 @@
+package reeemiks
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+
+	// how often a ramp moves its session's volume along. small enough to not be heard as steps
+	rampInterval = 10 * time.Millisecond
+)
+
+// volumeRamp takes a session from one volume to another over a set duration
+type volumeRamp struct {
+	session  Session
+	from     float32
+	to       float32
+	start    time.Time
+	duration time.Duration
+}
+
+// volumeRamper moves session volumes gradually instead of jumping them, which clicks and zippers.
+// every session has at most one ramp: a new target replaces the current one and starts from wherever the
+// session is at, so fast slider moves coalesce into a single ramp that follows them.
+// ramps are kept by session identity, since refreshing sessions replaces every Session we hold
+type volumeRamper struct {
+	logger *zap.SugaredLogger
+
+	ramps   map[string]*volumeRamp
+	running map[string]bool
+	lock    sync.Mutex
+}
+
+func newVolumeRamper(logger *zap.SugaredLogger) *volumeRamper {
+	return &volumeRamper{
+		logger:  logger.Named("ramp"),
+		ramps:   make(map[string]*volumeRamp),
+		running: make(map[string]bool),
+	}
+}
+
+// setVolume takes a session to the given volume over duration, or right away (cancelling any ramp it's in)
+// if duration is zero. only immediate changes can fail here, ramps log their own failures
+func (r *volumeRamper) setVolume(session Session, volume float32, duration time.Duration) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	identity := sessionIdentity(session)
+
+	// set while holding the lock, so a ramp tick that's already running can't overwrite it afterwards
+	if duration <= 0 {
+		delete(r.ramps, identity)
+		return session.SetVolume(volume)
+	}
+
+	r.start(identity, &volumeRamp{
+		session:  session,
+		from:     session.GetVolume(),
+		to:       volume,
+		start:    time.Now(),
+		duration: duration,
+	})
+
+	return nil
+}
+
+// start puts a ramp in place, and runs it unless the session is already ramping - in which case it picks up
+// its new target on the next tick. call with the lock held
+func (r *volumeRamper) start(identity string, ramp *volumeRamp) {
+	r.ramps[identity] = ramp
+
+	if !r.running[identity] {
+		r.running[identity] = true
+		go r.run(identity)
+	}
+}
+
+// targetVolume returns where a session is headed: the end of its ramp, or its current volume if it isn't ramping
+func (r *volumeRamper) targetVolume(session Session) float32 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if ramp, ok := r.ramps[sessionIdentity(session)]; ok {
+		return ramp.to
+	}
+
+	return session.GetVolume()
+}
+
+// stop cancels every ramp before the sessions they move are released, and returns them for resume
+func (r *volumeRamper) stop() map[string]*volumeRamp {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	stopped := r.ramps
+	r.ramps = make(map[string]*volumeRamp)
+
+	return stopped
+}
+
+// resume carries ramps stopped before a session refresh on to the sessions that replaced theirs, over whatever
+// was left of their duration. ramps of sessions that are gone for good stay cancelled
+func (r *volumeRamper) resume(stopped map[string]*volumeRamp, lookup func(key string) ([]Session, bool)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+
+	for identity, ramp := range stopped {
+
+		// something else already took over the session in the meantime
+		if _, ok := r.ramps[identity]; ok {
+			continue
+		}
+
+		sessions, _ := lookup(ramp.session.Key())
+
+		for _, session := range sessions {
+			if sessionIdentity(session) != identity {
+				continue
+			}
+
+			remaining := ramp.start.Add(ramp.duration).Sub(now)
+			if remaining < rampInterval {
+				remaining = rampInterval
+			}
+
+			r.start(identity, &volumeRamp{
+				session:  session,
+				from:     session.GetVolume(),
+				to:       ramp.to,
+				start:    now,
+				duration: remaining,
+			})
+
+			break
+		}
+	}
+}
+
+func (r *volumeRamper) run(identity string) {
+	ticker := time.NewTicker(rampInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !r.tick(identity) {
+			return
+		}
+	}
+}
+
+// tick moves a ramp along, returning false once there's nothing left for it to do. the volume is set with the
+// lock held, so an immediate change or a new target can't land between working it out and applying it
+func (r *volumeRamper) tick(identity string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// cancelled, or superseded by an immediate change
+	ramp, ok := r.ramps[identity]
+	if !ok {
+		delete(r.running, identity)
+		return false
+	}
+
+	progress := float32(time.Since(ramp.start)) / float32(ramp.duration)
+	if progress >= 1 {
+		progress = 1
+		delete(r.ramps, identity)
+	}
+
+	if err := ramp.session.SetVolume(ramp.from + (ramp.to-ramp.from)*progress); err != nil {
+		r.logger.Warnw("Failed to ramp session volume, giving up on it", "session", ramp.session.Key(), "error", err)
+		delete(r.ramps, identity)
+	}
+
+	return true
+}
+
+// fade ramps every session of a target (or of a slider, given its index) to a volume in percent over some seconds
+func (m *sessionMap) fade(target string, volumeArg string, secondsArg string) error {
+	volume, err := strconv.ParseFloat(volumeArg, 64)
+	if err != nil || volume < 0 || volume > 100 {
+		return fmt.Errorf("fade volume must be between 0 and 100, got %q", volumeArg)
+	}
+
+	seconds, err := strconv.ParseFloat(secondsArg, 64)
+	if err != nil || seconds < 0 {
+		return fmt.Errorf("fade duration must be a positive number of seconds, got %q", secondsArg)
+	}
+
+	targets := []string{target}
+	if sliderIdx, err := strconv.Atoi(target); err == nil {
+		targets, _ = m.activeSliderMapping().get(sliderIdx)
+	}
+
+	sessions := m.resolveSessions(targets)
+	if len(sessions) == 0 {
+		return fmt.Errorf("no audio sessions found for %s", target)
+	}
+
+	duration := time.Duration(seconds * float64(time.Second))
+
+	for _, session := range sessions {
+		if err := m.ramper.setVolume(session, float32(volume/100), duration); err != nil {
+			return fmt.Errorf("set volume of %s: %w", session.Key(), err)
+		}
+	}
+
+	m.logger.Debugw("Fading sessions", "target", target, "volume", volume, "duration", duration)
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package reeemiks
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeSession is a session that only remembers its volume
+type fakeSession struct {
+	key      string
+	identity string
+
+	volume float32
+	lock   sync.Mutex
+}
+
+func (s *fakeSession) GetVolume() float32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.volume
+}
+
+func (s *fakeSession) SetVolume(v float32) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.volume = v
+	return nil
+}
+
+func (s *fakeSession) GetMute() bool        { return false }
+func (s *fakeSession) SetMute(m bool) error { return nil }
+func (s *fakeSession) Key() string          { return s.key }
+func (s *fakeSession) Identity() string     { return s.identity }
+func (s *fakeSession) Release()             {}
+
+func waitForVolume(t *testing.T, session *fakeSession, volume float32) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for session.GetVolume() != volume {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %s to reach %.2f, it's at %.2f", session.identity, volume, session.GetVolume())
+		}
+
+		time.Sleep(rampInterval)
+	}
+}
+
+func TestVolumeRamperImmediateChangeWins(t *testing.T) {
+	ramper := newVolumeRamper(zap.NewNop().Sugar())
+	session := &fakeSession{key: "app", identity: "app#1"}
+
+	if err := ramper.setVolume(session, 1, 200*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(3 * rampInterval)
+
+	if err := ramper.setVolume(session, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// give a stale tick every chance to overwrite the immediate change
+	time.Sleep(5 * rampInterval)
+
+	if volume := session.GetVolume(); volume != 0 {
+		t.Errorf("expected the immediate change to stick, volume is %.2f", volume)
+	}
+
+	if target := ramper.targetVolume(session); target != 0 {
+		t.Errorf("expected no ramp to be left, target is %.2f", target)
+	}
+}
+
+func TestVolumeRamperKeepsSessionsApart(t *testing.T) {
+	ramper := newVolumeRamper(zap.NewNop().Sugar())
+	first := &fakeSession{key: "app", identity: "app#1"}
+	second := &fakeSession{key: "app", identity: "app#2"}
+
+	ramper.setVolume(first, 1, 50*time.Millisecond)
+	ramper.setVolume(second, 0.5, 50*time.Millisecond)
+
+	waitForVolume(t, first, 1)
+	waitForVolume(t, second, 0.5)
+}
+
+func TestVolumeRamperResumesOnRefreshedSessions(t *testing.T) {
+	ramper := newVolumeRamper(zap.NewNop().Sugar())
+	old := &fakeSession{key: "app", identity: "app#1"}
+	gone := &fakeSession{key: "app", identity: "app#2"}
+
+	ramper.setVolume(old, 1, 100*time.Millisecond)
+	ramper.setVolume(gone, 1, 100*time.Millisecond)
+	time.Sleep(2 * rampInterval)
+
+	stopped := ramper.stop()
+	stoppedAt := old.GetVolume()
+
+	refreshed := &fakeSession{key: "app", identity: "app#1", volume: stoppedAt}
+	ramper.resume(stopped, func(key string) ([]Session, bool) {
+		return []Session{refreshed}, true
+	})
+
+	if target := ramper.targetVolume(refreshed); target != 1 {
+		t.Errorf("expected the refreshed session to be headed for 1, got %.2f", target)
+	}
+
+	waitForVolume(t, refreshed, 1)
+
+	if volume := old.GetVolume(); volume != stoppedAt {
+		t.Errorf("expected the released session to be left alone at %.2f, it's at %.2f", stoppedAt, volume)
+	}
+
+	if volume := gone.GetVolume(); volume == 1 {
+		t.Errorf("expected the ramp of a session that's gone to stay cancelled")
+	}
+}
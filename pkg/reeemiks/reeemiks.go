@@ -25,6 +25,10 @@ type Reeemiks struct {
 	config         *CanonicalConfig
 	reeemiksConnection ReeemiksConnection
 	sessions       *sessionMap
+	control        *controlServer
+	autoProfiles   *autoProfiler
+	ducking        *ducker
+	levels         *levelMeter
 
 	stopChannel chan bool
 	version     string
@@ -55,6 +59,11 @@ func NewReeemiks(logger *zap.SugaredLogger, verbose bool) (*Reeemiks, error) {
 		verbose:     verbose,
 	}
 
+	d.control = newControlServer(d, logger)
+	d.autoProfiles = newAutoProfiler(d, logger)
+	d.ducking = newDucker(d, logger)
+	d.levels = newLevelMeter(d, logger)
+
 	sessionFinder, err := newSessionFinder(logger, config)
 	if err != nil {
 		logger.Errorw("Failed to create SessionFinder", "error", err)
@@ -84,7 +93,7 @@ func (d *Reeemiks) Initialize() error {
 		return fmt.Errorf("load config during init: %w", err)
 	}
 
-	if d.config.EnableHidListen {
+	if d.config.snapshot().EnableHidListen {
 		hid, err := NewHIDRAW(d, d.logger)
 		if err != nil {
 			d.logger.Errorw("Failed to create HIDRAW", "error", err)
@@ -110,6 +119,15 @@ func (d *Reeemiks) Initialize() error {
 		return fmt.Errorf("init session map: %w", err)
 	}
 
+	d.autoProfiles.start()
+	d.ducking.start()
+	d.levels.start()
+
+	// listen for `reeemiks ctl` - not being able to is a shame, but no reason to stop the show
+	if err := d.control.start(); err != nil {
+		d.logger.Warnw("Failed to start control server", "error", err)
+	}
+
 	// decide whether to run with/without tray
 	if _, noTraySet := os.LookupEnv(envNoTray); noTraySet {
 
@@ -158,12 +176,14 @@ func (d *Reeemiks) run() {
 		if err := d.reeemiksConnection.Start(); err != nil {
 			d.logger.Warnw("Failed to start first-time serial connection", "error", err)
 
+			comPort := d.config.snapshot().SerialConnectionInfo.COMPort
+
 			// If the port is busy, that's because something else is connected - notify and quit
 			if errors.Is(err, os.ErrPermission) {
 				d.logger.Warnw("Serial port seems busy, notifying user and closing",
-					"comPort", d.config.SerialConnectionInfo.COMPort)
+					"comPort", comPort)
 
-				d.notifier.Notify(fmt.Sprintf("Can't connect to %s!", d.config.SerialConnectionInfo.COMPort),
+				d.notifier.Notify(fmt.Sprintf("Can't connect to %s!", comPort),
 					"This serial port is busy, make sure to close any serial monitor or other reeemiks instance.")
 
 				d.signalStop()
@@ -171,9 +191,9 @@ func (d *Reeemiks) run() {
 				// also notify if the COM port they gave isn't found, maybe their config is wrong
 			} else if errors.Is(err, os.ErrNotExist) {
 				d.logger.Warnw("Provided COM port seems wrong, notifying user and closing",
-					"comPort", d.config.SerialConnectionInfo.COMPort)
+					"comPort", comPort)
 
-				d.notifier.Notify(fmt.Sprintf("Can't connect to %s!", d.config.SerialConnectionInfo.COMPort),
+				d.notifier.Notify(fmt.Sprintf("Can't connect to %s!", comPort),
 					"This serial port doesn't exist, check your configuration and make sure it's set correctly.")
 
 				d.signalStop()
@@ -204,6 +224,10 @@ func (d *Reeemiks) stop() error {
 
 	d.reeemiksConnection.Stop()
 	d.config.StopWatchingConfigFile()
+	d.control.stop()
+	d.autoProfiles.stop()
+	d.ducking.stop()
+	d.levels.stop()
 
 	// release the session map
 	if err := d.sessions.release(); err != nil {
@@ -0,0 +1,50 @@
+package reeemiks
+
+import "time"
+
+const (
+
+	// turning faster than this many steps per second starts accelerating, up to the slider's acceleration factor
+	accelerationThreshold = 10.0
+
+	// steps further apart than this are a new turn, not a continuation of the last one
+	accelerationWindow = 250 * time.Millisecond
+)
+
+// relativeStep works out how far a relative move (an encoder turn or a HID up/down) should change a slider's targets:
+// the slider's step size for every step turned, scaled up by its acceleration when it's being turned quickly
+func (m *sessionMap) relativeStep(event SliderMoveEvent, settings sliderSettings) float32 {
+	now := time.Now()
+	last, known := m.lastRelativeMove[event.SliderID]
+	m.lastRelativeMove[event.SliderID] = now
+
+	multiplier := 1.0
+
+	if elapsed := now.Sub(last); settings.Acceleration > 1 && known && elapsed < accelerationWindow {
+		steps := event.Steps
+		if steps < 0 {
+			steps = -steps
+		}
+
+		velocity := float64(steps) / elapsed.Seconds()
+
+		multiplier = velocity / accelerationThreshold
+		if multiplier < 1 {
+			multiplier = 1
+		} else if multiplier > settings.Acceleration {
+			multiplier = settings.Acceleration
+		}
+	}
+
+	return float32(float64(event.Steps) * settings.Step * multiplier)
+}
+
+func clampVolume(volume float32) float32 {
+	if volume < 0 {
+		return 0
+	} else if volume > 1 {
+		return 1
+	}
+
+	return volume
+}
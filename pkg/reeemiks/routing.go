@@ -0,0 +1,170 @@
+package reeemiks
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sinkNext picks the sink after the current one, from default_sinks or (without it) every sink there is
+const sinkNext = "next"
+
+var errRoutingUnsupported = errors.New("moving audio between devices isn't supported on this platform")
+
+// returned for sessions that can't move at all, like master or a device
+var errSessionNotMovable = errors.New("not an application stream")
+
+func (m *sessionMap) sinkRouter() (sinkRouter, error) {
+	router, ok := m.sessionFinder.(sinkRouter)
+	if !ok {
+		return nil, errRoutingUnsupported
+	}
+
+	return router, nil
+}
+
+// moveSessions moves every session of a target (or of a slider, given its index) to a sink, and returns how many it moved.
+// with "next", each session moves on to the sink after the one it's currently playing on. sessions that can't move
+// (a slider's master, say) are skipped, and it only fails if none of them could be moved
+func (m *sessionMap) moveSessions(target string, sink string) (int, error) {
+	router, err := m.sinkRouter()
+	if err != nil {
+		return 0, err
+	}
+
+	targets := []string{target}
+	if sliderIdx, err := strconv.Atoi(target); err == nil {
+		targets, _ = m.activeSliderMapping().get(sliderIdx)
+	}
+
+	sessions := m.resolveSessions(targets)
+	if len(sessions) == 0 {
+		return 0, fmt.Errorf("no audio sessions found for %s", target)
+	}
+
+	cycle := strings.EqualFold(sink, sinkNext)
+
+	name := ""
+	if !cycle {
+		if name, err = router.resolveSink(sink); err != nil {
+			return 0, err
+		}
+	}
+
+	moved := 0
+	errs := []error{}
+
+	for _, session := range sessions {
+		if err := m.moveSession(router, session, name, cycle); err != nil {
+			if errors.Is(err, errSessionNotMovable) {
+				m.logger.Debugw("Not moving audio session", "session", session.Key(), "reason", err)
+			} else {
+				m.logger.Warnw("Failed to move audio session", "session", session.Key(), "error", err)
+			}
+
+			errs = append(errs, err)
+			continue
+		}
+
+		moved++
+	}
+
+	if moved == 0 {
+		return 0, errors.Join(errs...)
+	}
+
+	return moved, nil
+}
+
+func (m *sessionMap) moveSession(router sinkRouter, session Session, name string, cycle bool) error {
+	if cycle {
+		current, err := router.sessionSink(session)
+		if err != nil {
+			return fmt.Errorf("get sink of %s: %w", session.Key(), err)
+		}
+
+		if name, err = m.nextSink(router, current); err != nil {
+			return err
+		}
+	}
+
+	if err := router.moveSession(session, name); err != nil {
+		return fmt.Errorf("move %s: %w", session.Key(), err)
+	}
+
+	m.logger.Infow("Moved audio session", "session", session.Key(), "sink", name)
+
+	return nil
+}
+
+// switchDefaultSink makes a sink the default one, or cycles to the next one, and returns its name
+func (m *sessionMap) switchDefaultSink(sink string) (string, error) {
+	router, err := m.sinkRouter()
+	if err != nil {
+		return "", err
+	}
+
+	var name string
+
+	if strings.EqualFold(sink, sinkNext) {
+		current, err := router.defaultSink()
+		if err != nil {
+			return "", fmt.Errorf("get default sink: %w", err)
+		}
+
+		name, err = m.nextSink(router, current)
+	} else {
+		name, err = router.resolveSink(sink)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := router.setDefaultSink(name); err != nil {
+		return "", fmt.Errorf("set default sink: %w", err)
+	}
+
+	m.logger.Infow("Switched default sink", "sink", name)
+
+	return name, nil
+}
+
+// nextSink returns the sink after current in default_sinks, or the first one if current isn't listed.
+// sinks from the config that aren't around right now (an unplugged headset, say) are skipped
+func (m *sessionMap) nextSink(router sinkRouter, current string) (string, error) {
+	available := []string{}
+	defaultSinks := m.reeemiks.config.snapshot().DefaultSinks
+
+	if len(defaultSinks) == 0 {
+		names, err := router.sinkNames()
+		if err != nil {
+			return "", fmt.Errorf("list sinks: %w", err)
+		}
+
+		available = names
+	} else {
+		for _, sink := range defaultSinks {
+			name, err := router.resolveSink(sink)
+			if err != nil {
+				m.logger.Debugw("Skipping unavailable sink", "sink", sink, "error", err)
+				continue
+			}
+
+			available = append(available, name)
+		}
+	}
+
+	if len(available) == 0 {
+		return "", errors.New("no sinks to switch to")
+	}
+
+	for idx, name := range available {
+		if name == current {
+			return available[(idx+1)%len(available)], nil
+		}
+	}
+
+	return available[0], nil
+}
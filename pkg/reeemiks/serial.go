@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jacobsa/go-serial/serial"
@@ -25,20 +26,24 @@ type SerialIO struct {
 	logger *zap.SugaredLogger
 
 	stopChannel chan bool
-	connected   bool
-	connOptions serial.OpenOptions
-	conn        io.ReadWriteCloser
 
-	lastKnownNumSliders        int
+	// written by the read loop, read by Status and SendLevels from the control server and the level meter
+	connected           bool
+	connOptions         serial.OpenOptions
+	conn                io.ReadWriteCloser
+	lastKnownNumSliders int
+	lastKnownNumButtons int
+	stateLock           sync.Mutex
+
 	currentSliderPercentValues []float32
-	lastKnownNumButtons        int
 	currentButtonValues 			 []int
 
 	sliderMoveConsumers []chan SliderMoveEvent
 	buttonEventConsumers []chan ButtonEvent
 }
 
-var expectedLinePattern = regexp.MustCompile(`^\w{1}\d{1,4}(\|\w{1}\d{1,4})*\r\n$|^\d{1,4}(\|\d{1,4})*\r\n$`)
+// values are sliders (s512), buttons (b1) or encoders (e+3, e-1), or plain numbers for boards that only have sliders
+var expectedLinePattern = regexp.MustCompile(`^(\w{1}\d{1,4}|e[+-]\d{1,4})(\|(\w{1}\d{1,4}|e[+-]\d{1,4}))*\r\n$|^\d{1,4}(\|\d{1,4})*\r\n$`)
 var maxRetryDelay = 100 * time.Second
 
 // NewSerialIO creates a SerialIO instance that uses the provided reeemiks
@@ -68,7 +73,7 @@ func NewSerialIO(reeemiks *Reeemiks, logger *zap.SugaredLogger) (*SerialIO, erro
 func (sio *SerialIO) Start() error {
 
 	// don't allow multiple concurrent connections
-	if sio.connected {
+	if sio.isConnected() {
 		sio.logger.Warn("Already connected, can't start another without closing first")
 		return errors.New("serial: connection already active")
 	}
@@ -81,23 +86,30 @@ func (sio *SerialIO) Start() error {
 		minimumReadSize = 1
 	}
 
-	sio.connOptions = serial.OpenOptions{
-		PortName:        sio.reeemiks.config.SerialConnectionInfo.COMPort,
-		BaudRate:        uint(sio.reeemiks.config.SerialConnectionInfo.BaudRate),
+	connectionInfo := sio.reeemiks.config.snapshot().SerialConnectionInfo
+
+	connOptions := serial.OpenOptions{
+		PortName:        connectionInfo.COMPort,
+		BaudRate:        uint(connectionInfo.BaudRate),
 		DataBits:        8,
 		StopBits:        1,
 		MinimumReadSize: uint(minimumReadSize),
 	}
 
+	sio.stateLock.Lock()
+	sio.connOptions = connOptions
+	sio.stateLock.Unlock()
+
 	sio.logger.Debugw("Attempting serial connection",
-		"comPort", sio.connOptions.PortName,
-		"baudRate", sio.connOptions.BaudRate,
+		"comPort", connOptions.PortName,
+		"baudRate", connOptions.BaudRate,
 		"minReadSize", minimumReadSize)
 
+	var conn io.ReadWriteCloser
 	var err error
 	delay := 1 * time.Second
 	for i := int64(1); ; i++ {
-		sio.conn, err = serial.Open(sio.connOptions)
+		conn, err = serial.Open(connOptions)
 		if err == nil {
 			break
 		}
@@ -116,14 +128,18 @@ func (sio *SerialIO) Start() error {
 		return fmt.Errorf("open serial connection: %w", err)
 	}
 
-	namedLogger := sio.logger.Named(strings.ToLower(sio.connOptions.PortName))
+	namedLogger := sio.logger.Named(strings.ToLower(connOptions.PortName))
 
-	namedLogger.Infow("Connected", "conn", sio.conn)
+	namedLogger.Infow("Connected", "conn", conn)
+
+	sio.stateLock.Lock()
+	sio.conn = conn
 	sio.connected = true
+	sio.stateLock.Unlock()
 
 	// read lines or await a stop
 	go func() {
-		connReader := bufio.NewReader(sio.conn)
+		connReader := bufio.NewReader(conn)
 		lineChannel := sio.readLine(namedLogger, connReader)
 
 		for {
@@ -133,7 +149,10 @@ func (sio *SerialIO) Start() error {
 			case line, ok := <-lineChannel:
 				sio.handleLine(namedLogger, line)
 				if !ok {
+					sio.stateLock.Lock()
 					sio.connected = false
+					sio.stateLock.Unlock()
+
 					sio.Start()
 					return
 				}
@@ -146,7 +165,7 @@ func (sio *SerialIO) Start() error {
 
 // Stop signals us to shut down our serial connection, if one is active
 func (sio *SerialIO) Stop() {
-	if sio.connected {
+	if sio.isConnected() {
 		sio.logger.Debug("Shutting down serial connection")
 		sio.stopChannel <- true
 	} else {
@@ -163,6 +182,24 @@ func (sio *SerialIO) SubscribeToSliderMoveEvents() chan SliderMoveEvent {
 	return ch
 }
 
+// Status reports the serial port in use and how many sliders and buttons the board last sent
+func (sio *SerialIO) Status() ConnectionStatus {
+	sio.stateLock.Lock()
+	defer sio.stateLock.Unlock()
+
+	port := sio.connOptions.PortName
+	if port == "" {
+		port = sio.reeemiks.config.snapshot().SerialConnectionInfo.COMPort
+	}
+
+	return ConnectionStatus{
+		Connected:  sio.connected,
+		Port:       port,
+		NumSliders: sio.lastKnownNumSliders,
+		NumButtons: sio.lastKnownNumButtons,
+	}
+}
+
 func (sio *SerialIO) SubscribeToButtonEvents() chan ButtonEvent {
 	ch := make(chan ButtonEvent)
 	sio.buttonEventConsumers = append(sio.buttonEventConsumers, ch)
@@ -170,6 +207,28 @@ func (sio *SerialIO) SubscribeToButtonEvents() chan ButtonEvent {
 	return ch
 }
 
+// SendLevels writes a line with every slider's level in percent, in the same shape as the lines the board sends
+// (e.g. "l0|l57|l100"), for boards that want to show them on LEDs
+func (sio *SerialIO) SendLevels(levels []float32) error {
+	sio.stateLock.Lock()
+	defer sio.stateLock.Unlock()
+
+	if !sio.connected || sio.conn == nil {
+		return errors.New("serial: not connected")
+	}
+
+	values := make([]string, len(levels))
+	for idx, level := range levels {
+		values[idx] = fmt.Sprintf("l%d", int(level*100+0.5))
+	}
+
+	if _, err := io.WriteString(sio.conn, strings.Join(values, "|")+"\r\n"); err != nil {
+		return fmt.Errorf("write levels: %w", err)
+	}
+
+	return nil
+}
+
 func (sio *SerialIO) setupOnConfigReload() {
 	configReloadedChannel := sio.reeemiks.config.SubscribeToChanges()
 
@@ -187,12 +246,21 @@ func (sio *SerialIO) setupOnConfigReload() {
 				// is still cleared. this is kind of ugly, but shouldn't cause any issues
 				go func() {
 					<-time.After(stopDelay)
+
+					sio.stateLock.Lock()
 					sio.lastKnownNumSliders = 0
+					sio.stateLock.Unlock()
 				}()
 
 				// if connection params have changed, attempt to stop and start the connection
-				if sio.reeemiks.config.SerialConnectionInfo.COMPort != sio.connOptions.PortName ||
-					uint(sio.reeemiks.config.SerialConnectionInfo.BaudRate) != sio.connOptions.BaudRate {
+				connectionInfo := sio.reeemiks.config.snapshot().SerialConnectionInfo
+
+				sio.stateLock.Lock()
+				connOptions := sio.connOptions
+				sio.stateLock.Unlock()
+
+				if connectionInfo.COMPort != connOptions.PortName ||
+					uint(connectionInfo.BaudRate) != connOptions.BaudRate {
 
 					sio.logger.Info("Detected change in connection parameters, attempting to renew connection")
 					sio.Stop()
@@ -211,7 +279,18 @@ func (sio *SerialIO) setupOnConfigReload() {
 	}()
 }
 
+// isConnected returns true while a serial connection is open
+func (sio *SerialIO) isConnected() bool {
+	sio.stateLock.Lock()
+	defer sio.stateLock.Unlock()
+
+	return sio.connected
+}
+
 func (sio *SerialIO) close(logger *zap.SugaredLogger) {
+	sio.stateLock.Lock()
+	defer sio.stateLock.Unlock()
+
 	if err := sio.conn.Close(); err != nil {
 		logger.Warnw("Failed to close serial connection", "error", err)
 	} else {
@@ -263,17 +342,23 @@ func (sio *SerialIO) handleLine(logger *zap.SugaredLogger, line string) {
 	// trim the suffix
 	line = strings.TrimSuffix(line, "\r\n")
 
+	// the whole line is read with the same config, even if it gets reloaded halfway through
+	config := sio.reeemiks.config.snapshot()
+
 	// split on pipe (|), this gives a slice of numerical strings between "0" and "1023"
 	splitLine := strings.Split(line, "|")
 
 	splitLineSliders := []string{}
 	splitLineButtons := []string{}
+	splitLineEncoders := []string{}
 
 	for _, splitValue := range splitLine {
 		if splitValue[0] == 's' {
 			splitLineSliders = append(splitLineSliders, strings.Replace(splitValue, "s", "", -1))
 		} else if splitValue[0] == 'b' {
 			splitLineButtons = append(splitLineButtons, strings.Replace(splitValue, "b", "", -1))
+		} else if splitValue[0] == 'e' {
+			splitLineEncoders = append(splitLineEncoders, strings.TrimPrefix(splitValue, "e"))
 		} else {
 			splitLineSliders = append(splitLineSliders, splitValue)
 		}
@@ -282,10 +367,16 @@ func (sio *SerialIO) handleLine(logger *zap.SugaredLogger, line string) {
 	numSliders := len(splitLineSliders)
 	numButtons := len(splitLineButtons)
 
+	sio.stateLock.Lock()
+	slidersChanged := numSliders != sio.lastKnownNumSliders
+	buttonsChanged := numButtons != sio.lastKnownNumButtons
+	sio.lastKnownNumSliders = numSliders
+	sio.lastKnownNumButtons = numButtons
+	sio.stateLock.Unlock()
+
 	// update our slider count, if needed - this will send slider move events for all
-	if numSliders != sio.lastKnownNumSliders {
+	if slidersChanged {
 		logger.Infow("Detected sliders", "amount", numSliders)
-		sio.lastKnownNumSliders = numSliders
 		sio.currentSliderPercentValues = make([]float32, numSliders)
 
 		// reset everything to be an impossible value to force the slider move event later
@@ -294,9 +385,8 @@ func (sio *SerialIO) handleLine(logger *zap.SugaredLogger, line string) {
 		}
 	}
 
-	if numButtons != sio.lastKnownNumButtons {
+	if buttonsChanged {
 		logger.Infow("Detected buttons", "amount", numButtons)
-		sio.lastKnownNumButtons = numButtons
 		sio.currentButtonValues = make([]int, numButtons)
 
 		// reset everything to be an impossible value to force the slider move event later
@@ -329,12 +419,12 @@ func (sio *SerialIO) handleLine(logger *zap.SugaredLogger, line string) {
 			normalizedScalar := util.NormalizeScalar(dirtyFloat)
 
 			// if sliders are inverted, take the complement of 1.0
-			if sio.reeemiks.config.InvertSliders {
+			if config.InvertSliders {
 				normalizedScalar = 1 - normalizedScalar
 			}
 
 			// check if it changes the desired state (could just be a jumpy raw slider value)
-			if util.SignificantlyDifferent(sio.currentSliderPercentValues[sliderIdx], normalizedScalar, sio.reeemiks.config.NoiseReductionLevel) {
+			if util.SignificantlyDifferent(sio.currentSliderPercentValues[sliderIdx], normalizedScalar, config.NoiseReductionLevel) {
 
 				// if it does, update the saved value and create a move event
 				sio.currentSliderPercentValues[sliderIdx] = normalizedScalar
@@ -352,6 +442,25 @@ func (sio *SerialIO) handleLine(logger *zap.SugaredLogger, line string) {
 
 	}
 
+	// encoders report how many steps they turned since the last line, and take the slider indexes after the sliders.
+	// their push switches are sent as regular buttons
+	for encoderIdx, stringValue := range splitLineEncoders {
+		steps, _ := strconv.Atoi(stringValue)
+		if steps == 0 {
+			continue
+		}
+
+		moveEvents = append(moveEvents, SliderMoveEvent{
+			SliderID: numSliders + encoderIdx,
+			Relative: true,
+			Steps:    steps,
+		})
+
+		if sio.reeemiks.Verbose() {
+			logger.Debugw("Encoder turned", "event", moveEvents[len(moveEvents)-1])
+		}
+	}
+
 	buttonEvents := []ButtonEvent{}
 	for buttonId, stringValue := range splitLineButtons {
 
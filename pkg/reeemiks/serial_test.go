@@ -0,0 +1,64 @@
+package reeemiks
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// bufferConn turns a buffer into a serial connection that remembers what was written to it
+type bufferConn struct {
+	bytes.Buffer
+}
+
+func (c *bufferConn) Close() error { return nil }
+
+func TestSerialStatusWhileReading(t *testing.T) {
+	sio := &SerialIO{reeemiks: newTestReeemiks(t, slowSessionFinder{}), logger: zap.NewNop().Sugar()}
+
+	conn := &bufferConn{}
+	sio.conn = conn
+	sio.connected = true
+	sio.connOptions.PortName = "/dev/ttyUSB0"
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	// the read loop and the control server get at the board's state at the same time
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 100; i++ {
+			sio.handleLine(sio.logger, "s512|s1023|b1\r\n")
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		sio.Status()
+	}
+
+	wg.Wait()
+
+	status := sio.Status()
+	if !status.Connected || status.Port != "/dev/ttyUSB0" || status.NumSliders != 2 || status.NumButtons != 1 {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	if err := sio.SendLevels([]float32{0, 0.57, 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if written := conn.String(); written != "l0|l57|l100\r\n" {
+		t.Errorf("expected the levels line, got %q", written)
+	}
+}
+
+func TestSerialSendLevelsDisconnected(t *testing.T) {
+	sio := &SerialIO{reeemiks: newTestReeemiks(t, slowSessionFinder{}), logger: zap.NewNop().Sugar()}
+
+	if err := sio.SendLevels([]float32{0.5}); err == nil {
+		t.Error("expected sending levels without a connection to fail")
+	}
+}
@@ -11,14 +11,31 @@ type Session interface {
 	GetVolume() float32
 	SetVolume(v float32) error
 
-	// TODO: future mute support
-	// GetMute() bool
-	// SetMute(m bool) error
+	GetMute() bool
+	SetMute(m bool) error
 
 	Key() string
 	Release()
 }
 
+// activeSession is implemented by sessions that can tell whether they're playing anything right now.
+// sessions that can't are taken to be playing for as long as they exist
+type activeSession interface {
+	Active() bool
+}
+
+// processSession is implemented by sessions that know the process playing them, on this machine.
+// nil means they don't (like device sessions, or streams from another machine's server)
+type processSession interface {
+	Process() *processInfo
+}
+
+// identifiedSession is implemented by sessions that share their key with others, like several streams of the same app.
+// the identity tells them apart, and stays the same when the session is looked up again
+type identifiedSession interface {
+	Identity() string
+}
+
 const (
 
 	// ideally these would share a common ground in baseSession
@@ -52,3 +69,28 @@ func (s *baseSession) Key() string {
 
 	return strings.ToLower(s.name)
 }
+
+func sessionActive(session Session) bool {
+	if active, ok := session.(activeSession); ok {
+		return active.Active()
+	}
+
+	return true
+}
+
+// sessionIdentity returns what tells a session apart from every other one, across session refreshes
+func sessionIdentity(session Session) string {
+	if identified, ok := session.(identifiedSession); ok {
+		return identified.Identity()
+	}
+
+	return session.Key()
+}
+
+func sessionProcess(session Session) *processInfo {
+	if process, ok := session.(processSession); ok {
+		return process.Process()
+	}
+
+	return nil
+}
@@ -0,0 +1,291 @@
+package reeemiks
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// alsaSession is a single simple mixer control on a card, in one direction (playback or capture).
+// every amixer call is a process of its own, so the control's state is cached and volume changes are coalesced
+type alsaSession struct {
+	baseSession
+
+	card         alsaCard
+	control      string
+	controlName  string
+	controlIndex int
+	playback     bool
+
+	// the channels' levels and switches as the last refresh, amixer call or our own change left them.
+	// other sessions of the same control share these slices, so they're replaced rather than changed
+	levels    []int
+	switches  []bool
+	stateTime time.Time
+
+	// volume changes that came in too soon after the last one, and what became of the one sent for them
+	lastWrite     time.Time
+	pendingVolume float32
+	volumePending bool
+	flushErr      error
+
+	lock sync.Mutex
+}
+
+const (
+
+	// the cached state is asked for again once it's this old, to notice changes made in alsamixer and the like
+	alsaStateMaxAge = time.Second
+
+	// volume changes closer together than this only send the latest one, ramps ask for one every 10ms
+	alsaWriteInterval = 50 * time.Millisecond
+)
+
+func newALSASession(
+	logger *zap.SugaredLogger,
+	card alsaCard,
+	control alsaControl,
+	playback bool,
+	masterName string,
+) *alsaSession {
+
+	s := &alsaSession{
+		card:         card,
+		control:      control.name + "," + strconv.Itoa(control.index),
+		controlName:  control.name,
+		controlIndex: control.index,
+		playback:     playback,
+		levels:       control.levels[playback],
+		switches:     control.switches[playback],
+		stateTime:    time.Now(),
+	}
+
+	if masterName != "" {
+		s.master = true
+		s.name = masterName
+	} else {
+
+		// named like the other backends' devices, with the card standing in for the node name
+		name := control.name
+		if control.index > 0 {
+			name += " " + strconv.Itoa(control.index)
+		}
+
+		if !playback && control.playback {
+			name += " Capture"
+		}
+
+		s.name = "reeemiks.device: " + name + "~" + card.id
+	}
+
+	s.humanReadableDesc = s.name
+
+	s.logger = logger.Named(s.Key())
+	s.logger.Debugw(sessionCreationLogMessage, "session", s)
+
+	return s
+}
+
+// refreshState asks amixer for the channels' levels and switches once the cached ones are too old.
+// call with the lock held
+func (s *alsaSession) refreshState() error {
+	if time.Since(s.stateTime) < alsaStateMaxAge {
+		return nil
+	}
+
+	control, err := s.get()
+	if err != nil {
+		return err
+	}
+
+	s.levels = control.levels[s.playback]
+	s.switches = control.switches[s.playback]
+	s.stateTime = time.Now()
+
+	// a change that's yet to be sent is where the control is about to be
+	if s.volumePending {
+		s.cacheVolume(s.pendingVolume)
+	}
+
+	return nil
+}
+
+// cacheVolume puts every cached channel level at the given volume. call with the lock held
+func (s *alsaSession) cacheVolume(v float32) {
+	levels := make([]int, len(s.levels))
+	for idx := range levels {
+		levels[idx] = int(v*100 + 0.5)
+	}
+
+	s.levels = levels
+}
+
+func (s *alsaSession) get() (alsaControl, error) {
+	output, err := runAmixer("-c", strconv.Itoa(s.card.index), "sget", s.control)
+	if err != nil {
+		return alsaControl{}, err
+	}
+
+	for _, control := range parseAmixerControls(output) {
+		if control.name == s.controlName && control.index == s.controlIndex {
+			return control, nil
+		}
+	}
+
+	return alsaControl{}, fmt.Errorf("no such mixer control: %s", s.control)
+}
+
+func (s *alsaSession) direction() string {
+	if s.playback {
+		return "playback"
+	}
+
+	return "capture"
+}
+
+func (s *alsaSession) GetVolume() float32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.refreshState(); err != nil {
+		s.logger.Warnw("Failed to get session volume", "error", err)
+		return 0
+	}
+
+	if len(s.levels) == 0 {
+		return 0
+	}
+
+	var level int
+	for _, channelLevel := range s.levels {
+		level += channelLevel
+	}
+
+	return float32(level) / float32(len(s.levels)) / 100
+}
+
+// SetVolume sends the volume to amixer right away, unless it sent one less than alsaWriteInterval ago. then the
+// latest volume goes out once the interval is up, and if that fails the next call returns the error
+func (s *alsaSession) SetVolume(v float32) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.cacheVolume(v)
+
+	if wait := alsaWriteInterval - time.Since(s.lastWrite); wait > 0 {
+		if !s.volumePending {
+			time.AfterFunc(wait, s.flushVolume)
+		}
+
+		s.pendingVolume = v
+		s.volumePending = true
+
+		err := s.flushErr
+		s.flushErr = nil
+
+		return err
+	}
+
+	return s.writeVolume(v)
+}
+
+// flushVolume sends the latest volume that had to wait
+func (s *alsaSession) flushVolume() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.volumePending {
+		return
+	}
+
+	s.volumePending = false
+	s.flushErr = s.writeVolume(s.pendingVolume)
+}
+
+// writeVolume has amixer set the volume. call with the lock held
+func (s *alsaSession) writeVolume(v float32) error {
+	percent := fmt.Sprintf("%d%%", int(v*100+0.5))
+	s.lastWrite = time.Now()
+
+	if _, err := runAmixer("-q", "-c", strconv.Itoa(s.card.index), "sset", s.control, percent, s.direction()); err != nil {
+		s.logger.Warnw("Failed to set session volume", "error", err, "volume", v)
+
+		// whatever the control is at now, it isn't what we cached
+		s.stateTime = time.Time{}
+
+		return fmt.Errorf("adjust session volume: %w", err)
+	}
+
+	s.logger.Debugw("Adjusting session volume", "to", fmt.Sprintf("%.2f", v))
+
+	return nil
+}
+
+// GetMute returns true when every channel's switch is off. controls without a switch can't be muted
+func (s *alsaSession) GetMute() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.refreshState(); err != nil {
+		s.logger.Warnw("Failed to get session mute state", "error", err)
+		return false
+	}
+
+	if len(s.switches) == 0 {
+		return false
+	}
+
+	for _, on := range s.switches {
+		if on {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *alsaSession) SetMute(m bool) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// capture switches are called "cap" rather than "unmute" in amixer's words
+	var state string
+
+	switch {
+	case s.playback && m:
+		state = "mute"
+	case s.playback:
+		state = "unmute"
+	case m:
+		state = "nocap"
+	default:
+		state = "cap"
+	}
+
+	if _, err := runAmixer("-q", "-c", strconv.Itoa(s.card.index), "sset", s.control, state); err != nil {
+		s.logger.Warnw("Failed to set session mute state", "error", err, "mute", m)
+		return fmt.Errorf("adjust session mute state: %w", err)
+	}
+
+	switches := make([]bool, len(s.switches))
+	for idx := range switches {
+		switches[idx] = !m
+	}
+
+	s.switches = switches
+
+	s.logger.Debugw("Adjusting session mute state", "to", m)
+
+	return nil
+}
+
+func (s *alsaSession) Release() {
+	s.logger.Debug("Releasing audio session")
+}
+
+func (s *alsaSession) String() string {
+	return fmt.Sprintf(sessionStringFormat, s.humanReadableDesc, s.GetVolume())
+}
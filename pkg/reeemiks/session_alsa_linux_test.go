@@ -0,0 +1,261 @@
+package reeemiks
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeAmixer stands in for amixer, answering sget with canned output and remembering every call
+type fakeAmixer struct {
+	output string
+	err    error
+	calls  [][]string
+	lock   sync.Mutex
+}
+
+func (fake *fakeAmixer) fail(err error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.err = err
+}
+
+func (fake *fakeAmixer) madeCalls() [][]string {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	return append([][]string{}, fake.calls...)
+}
+
+func useFakeAmixer(t *testing.T, output string) *fakeAmixer {
+	t.Helper()
+
+	fake := &fakeAmixer{output: output}
+	previous := runAmixer
+
+	runAmixer = func(args ...string) ([]byte, error) {
+		fake.lock.Lock()
+		defer fake.lock.Unlock()
+
+		fake.calls = append(fake.calls, args)
+		if fake.err != nil {
+			return nil, fake.err
+		}
+
+		return []byte(fake.output), nil
+	}
+
+	t.Cleanup(func() {
+		runAmixer = previous
+	})
+
+	return fake
+}
+
+const fakeSgetOutput = `Simple mixer control 'Line',0
+  Capabilities: pvolume cvolume pswitch cswitch
+  Playback channels: Front Left - Front Right
+  Capture channels: Front Left - Front Right
+  Limits: Playback 0 - 31 Capture 0 - 31
+  Front Left: Playback 16 [50%] [-12.00dB] [off] Capture 23 [80%] [6.00dB] [on]
+  Front Right: Playback 22 [70%] [-3.00dB] [off] Capture 23 [80%] [6.00dB] [on]
+`
+
+func newFakeALSASession(playback bool) *alsaSession {
+	session := newALSASession(
+		zap.NewNop().Sugar(),
+		alsaCard{index: 1, id: "PCH"},
+		alsaControl{name: "Line", playback: true, capture: true},
+		playback,
+		"",
+	)
+
+	// whatever the refresh saw is long out of date
+	session.stateTime = time.Time{}
+
+	return session
+}
+
+func TestALSASessionGet(t *testing.T) {
+	fake := useFakeAmixer(t, fakeSgetOutput)
+
+	playback := newFakeALSASession(true)
+	capture := newFakeALSASession(false)
+
+	if key := capture.Key(); key != "reeemiks.device: line capture~pch" {
+		t.Errorf("unexpected capture session key %q", key)
+	}
+
+	if volume := playback.GetVolume(); volume != 0.6 {
+		t.Errorf("expected the playback channels to average out at 0.6, got %.2f", volume)
+	}
+
+	if volume := capture.GetVolume(); volume != 0.8 {
+		t.Errorf("expected a capture volume of 0.8, got %.2f", volume)
+	}
+
+	if !playback.GetMute() {
+		t.Error("expected playback to be muted with every channel off")
+	}
+
+	if capture.GetMute() {
+		t.Error("expected capture not to be muted")
+	}
+
+	expected := []string{"-c", "1", "sget", "Line,0"}
+	if !reflect.DeepEqual(fake.calls[0], expected) {
+		t.Errorf("expected amixer %q, got %q", expected, fake.calls[0])
+	}
+}
+
+func TestALSASessionGetMissingControl(t *testing.T) {
+	useFakeAmixer(t, strings.Replace(fakeSgetOutput, "'Line'", "'Mic'", 1))
+
+	session := newFakeALSASession(true)
+
+	if _, err := session.get(); err == nil {
+		t.Error("expected an error for a control amixer doesn't report")
+	}
+
+	if volume := session.GetVolume(); volume != 0 {
+		t.Errorf("expected a missing control to read as 0, got %.2f", volume)
+	}
+}
+
+func TestALSASessionSet(t *testing.T) {
+	fake := useFakeAmixer(t, "")
+
+	playback := newFakeALSASession(true)
+	capture := newFakeALSASession(false)
+
+	playback.SetVolume(0.5)
+	capture.SetVolume(0.333)
+	playback.SetMute(true)
+	playback.SetMute(false)
+	capture.SetMute(true)
+	capture.SetMute(false)
+
+	expected := [][]string{
+		{"-q", "-c", "1", "sset", "Line,0", "50%", "playback"},
+		{"-q", "-c", "1", "sset", "Line,0", "33%", "capture"},
+		{"-q", "-c", "1", "sset", "Line,0", "mute"},
+		{"-q", "-c", "1", "sset", "Line,0", "unmute"},
+		{"-q", "-c", "1", "sset", "Line,0", "nocap"},
+		{"-q", "-c", "1", "sset", "Line,0", "cap"},
+	}
+
+	if !reflect.DeepEqual(fake.calls, expected) {
+		t.Errorf("expected amixer calls %q, got %q", expected, fake.calls)
+	}
+}
+
+func TestALSASessionSetFailure(t *testing.T) {
+	fake := useFakeAmixer(t, "")
+	fake.fail(errors.New("amixer: no such card"))
+
+	session := newFakeALSASession(true)
+
+	if err := session.SetVolume(0.5); !errors.Is(err, fake.err) {
+		t.Errorf("expected the amixer error to come through, got %v", err)
+	}
+
+	if err := session.SetMute(true); !errors.Is(err, fake.err) {
+		t.Errorf("expected the amixer error to come through, got %v", err)
+	}
+}
+
+// newRefreshedALSASession makes a playback session the way a refresh does, from the state it just read
+func newRefreshedALSASession() *alsaSession {
+	control := alsaControl{
+		name:     "Line",
+		playback: true,
+		levels:   map[bool][]int{true: {40, 60}},
+		switches: map[bool][]bool{true: {true, true}},
+	}
+
+	return newALSASession(zap.NewNop().Sugar(), alsaCard{index: 1, id: "PCH"}, control, true, "")
+}
+
+func TestALSASessionCachesState(t *testing.T) {
+	fake := useFakeAmixer(t, fakeSgetOutput)
+	session := newRefreshedALSASession()
+
+	if volume := session.GetVolume(); volume != 0.5 {
+		t.Errorf("expected the volume the refresh saw, got %.2f", volume)
+	}
+
+	if session.GetMute() {
+		t.Error("expected the mute state the refresh saw")
+	}
+
+	if calls := fake.madeCalls(); len(calls) != 0 {
+		t.Errorf("expected no amixer calls while the refresh's state is fresh, got %q", calls)
+	}
+
+	// once it's old, amixer gets asked again
+	session.lock.Lock()
+	session.stateTime = time.Now().Add(-alsaStateMaxAge)
+	session.lock.Unlock()
+
+	if volume := session.GetVolume(); volume != 0.6 {
+		t.Errorf("expected the volume amixer reports, got %.2f", volume)
+	}
+
+	if calls := fake.madeCalls(); len(calls) != 1 || calls[0][2] != "sget" {
+		t.Errorf("expected a single sget, got %q", calls)
+	}
+}
+
+func TestALSASessionCoalescesVolumeChanges(t *testing.T) {
+	fake := useFakeAmixer(t, "")
+	session := newRefreshedALSASession()
+
+	// like a ramp, far quicker than amixer should be run
+	for _, volume := range []float32{0.1, 0.2, 0.3, 0.4} {
+		if err := session.SetVolume(volume); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if volume := session.GetVolume(); volume != 0.4 {
+		t.Errorf("expected the latest volume right away, got %.2f", volume)
+	}
+
+	time.Sleep(2 * alsaWriteInterval)
+
+	expected := [][]string{
+		{"-q", "-c", "1", "sset", "Line,0", "10%", "playback"},
+		{"-q", "-c", "1", "sset", "Line,0", "40%", "playback"},
+	}
+
+	if calls := fake.madeCalls(); !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected the first and the latest volume to be sent, got %q", calls)
+	}
+}
+
+func TestALSASessionReportsDelayedFailure(t *testing.T) {
+	fake := useFakeAmixer(t, "")
+	session := newFakeALSASession(true)
+
+	if err := session.SetVolume(0.1); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.fail(errors.New("amixer: no such card"))
+
+	if err := session.SetVolume(0.2); err != nil {
+		t.Errorf("expected a volume that has to wait not to fail yet, got %v", err)
+	}
+
+	time.Sleep(2 * alsaWriteInterval)
+
+	if err := session.SetVolume(0.3); !errors.Is(err, fake.err) {
+		t.Errorf("expected the failed write to be reported by the next change, got %v", err)
+	}
+}
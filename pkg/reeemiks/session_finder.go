@@ -6,3 +6,28 @@ type SessionFinder interface {
 
 	Release() error
 }
+
+// sinkRouter is implemented by session finders whose audio server can move streams between output devices.
+// sinks are referred to by name, and resolveSink also accepts their description or device session key
+type sinkRouter interface {
+	resolveSink(sink string) (string, error)
+	sinkNames() ([]string, error)
+
+	defaultSink() (string, error)
+	setDefaultSink(name string) error
+
+	sessionSink(session Session) (string, error)
+	moveSession(session Session, name string) error
+}
+
+// virtualDeviceManager is implemented by session finders that create the config's virtual_devices,
+// and need to hear about config changes to keep them in line
+type virtualDeviceManager interface {
+	syncVirtualDevices() error
+}
+
+// sessionResetNotifier is implemented by session finders that can tell when every session they handed out
+// went stale at once, like after reconnecting to the audio server
+type sessionResetNotifier interface {
+	sessionsReset() <-chan bool
+}
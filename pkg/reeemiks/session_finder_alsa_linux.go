@@ -0,0 +1,244 @@
+package reeemiks
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// alsaSessionFinder exposes ALSA's simple mixer controls as sessions, for systems without a sound server.
+// it drives amixer rather than linking against alsa-lib, which keeps reeemiks a single binary
+type alsaSessionFinder struct {
+	logger        *zap.SugaredLogger
+	sessionLogger *zap.SugaredLogger
+	config        *CanonicalConfig
+}
+
+// alsaCard is a sound card as listed in /proc/asound/cards
+type alsaCard struct {
+	index int
+	id    string
+}
+
+// alsaControl is a single simple mixer control, as amixer describes it
+type alsaControl struct {
+	name     string
+	index    int
+	playback bool
+	capture  bool
+
+	// percentages and switch states per channel, for playback and capture separately
+	levels   map[bool][]int
+	switches map[bool][]bool
+}
+
+const (
+	alsaCardsPath = "/proc/asound/cards"
+
+	// the controls master and mic stand for, on the first card
+	alsaMasterControl   = "Master"
+	alsaFallbackControl = "PCM"
+	alsaCaptureControl  = "Capture"
+)
+
+// runAmixer runs amixer with the mapped (perceptual) volume scale alsamixer uses, which suits a fader far better
+// than the raw one. it's a variable so a fake mixer can stand in for it
+var runAmixer = func(args ...string) ([]byte, error) {
+	output, err := exec.Command("amixer", append([]string{"-M"}, args...)...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("amixer %s: %w: %s", strings.Join(args, " "), err, bytes.TrimSpace(output))
+	}
+
+	return output, nil
+}
+
+var (
+	alsaCardPattern    = regexp.MustCompile(`^\s*(\d+)\s+\[(\S+)\s*\]`)
+	alsaControlPattern = regexp.MustCompile(`^Simple mixer control '(.*)',(\d+)$`)
+	alsaChannelPattern = regexp.MustCompile(`^\s+[^:]+: (.*\[\d+%\].*)$`)
+
+	// a channel line carries a level per direction, like "Playback 23 [74%] [0.00dB] [off] Capture 0 [0%] [on]"
+	alsaLevelPattern = regexp.MustCompile(`(?:(Playback|Capture) )?(?:-?\d+ )?\[(\d+)%\]((?: \[[^\]]*\])*)`)
+)
+
+var errNoSoundCards = errors.New("no sound cards found")
+
+func newALSASessionFinder(logger *zap.SugaredLogger, config *CanonicalConfig) (*alsaSessionFinder, error) {
+	sf := &alsaSessionFinder{
+		logger:        logger.Named("session_finder"),
+		sessionLogger: logger.Named("sessions"),
+		config:        config,
+	}
+
+	cards, err := alsaCards()
+	if err != nil {
+		return nil, err
+	}
+
+	// make sure amixer is around and can talk to the cards before we commit to it
+	if _, err := runAmixer("-c", strconv.Itoa(cards[0].index), "scontrols"); err != nil {
+		return nil, err
+	}
+
+	sf.logger.Debugw("Created ALSA session finder instance", "cards", len(cards))
+
+	return sf, nil
+}
+
+func (sf *alsaSessionFinder) GetAllSessions() ([]Session, error) {
+	cards, err := alsaCards()
+	if err != nil {
+		return nil, fmt.Errorf("list sound cards: %w", err)
+	}
+
+	sessions := []Session{}
+
+	for idx, card := range cards {
+		output, err := runAmixer("-c", strconv.Itoa(card.index), "scontents")
+		if err != nil {
+			sf.logger.Warnw("Failed to get mixer controls", "card", card.id, "error", err)
+			continue
+		}
+
+		controls := parseAmixerControls(output)
+
+		// master and mic come from the first card, like ALSA's own default device
+		if idx == 0 {
+			if control, ok := findALSAControl(controls, alsaMasterControl, true); ok {
+				sessions = append(sessions, newALSASession(sf.sessionLogger, card, control, true, masterSessionName))
+			} else if control, ok := findALSAControl(controls, alsaFallbackControl, true); ok {
+				sessions = append(sessions, newALSASession(sf.sessionLogger, card, control, true, masterSessionName))
+			}
+
+			if control, ok := findALSAControl(controls, alsaCaptureControl, false); ok {
+				sessions = append(sessions, newALSASession(sf.sessionLogger, card, control, false, inputSessionName))
+			}
+		}
+
+		for _, control := range controls {
+			if control.playback {
+				sessions = append(sessions, newALSASession(sf.sessionLogger, card, control, true, ""))
+			}
+
+			if control.capture {
+				sessions = append(sessions, newALSASession(sf.sessionLogger, card, control, false, ""))
+			}
+		}
+	}
+
+	return sessions, nil
+}
+
+func (sf *alsaSessionFinder) Release() error {
+	sf.logger.Debug("Released ALSA session finder instance")
+
+	return nil
+}
+
+func alsaCards() ([]alsaCard, error) {
+	contents, err := os.ReadFile(alsaCardsPath)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", alsaCardsPath, err)
+	}
+
+	cards := []alsaCard{}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+
+	for scanner.Scan() {
+		match := alsaCardPattern.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+
+		index, _ := strconv.Atoi(match[1])
+		cards = append(cards, alsaCard{index: index, id: match[2]})
+	}
+
+	if len(cards) == 0 {
+		return nil, errNoSoundCards
+	}
+
+	return cards, nil
+}
+
+// parseAmixerControls reads the output of `amixer scontents` (or `sget`) into controls that have a volume
+func parseAmixerControls(output []byte) []alsaControl {
+	controls := []alsaControl{}
+	var current *alsaControl
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if match := alsaControlPattern.FindStringSubmatch(line); match != nil {
+			index, _ := strconv.Atoi(match[2])
+			controls = append(controls, alsaControl{
+				name:     match[1],
+				index:    index,
+				levels:   map[bool][]int{},
+				switches: map[bool][]bool{},
+			})
+
+			current = &controls[len(controls)-1]
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		if capabilities, ok := strings.CutPrefix(strings.TrimSpace(line), "Capabilities:"); ok {
+			for _, capability := range strings.Fields(capabilities) {
+				switch capability {
+				case "pvolume", "volume":
+					current.playback = true
+				case "cvolume":
+					current.capture = true
+				}
+			}
+
+			continue
+		}
+
+		if match := alsaChannelPattern.FindStringSubmatch(line); match != nil {
+			for _, level := range alsaLevelPattern.FindAllStringSubmatch(match[1], -1) {
+				// controls with a single joined volume don't say which way it goes, and they're always outputs
+				playback := level[1] != "Capture"
+				percent, _ := strconv.Atoi(level[2])
+
+				current.levels[playback] = append(current.levels[playback], percent)
+				current.switches[playback] = append(current.switches[playback], !strings.Contains(level[3], "[off]"))
+			}
+		}
+	}
+
+	// controls without a volume (plain switches and enums) can't follow a slider
+	result := []alsaControl{}
+
+	for _, control := range controls {
+		if control.playback || control.capture {
+			result = append(result, control)
+		}
+	}
+
+	return result
+}
+
+func findALSAControl(controls []alsaControl, name string, playback bool) (alsaControl, bool) {
+	for _, control := range controls {
+		if control.name == name && control.index == 0 && ((playback && control.playback) || (!playback && control.capture)) {
+			return control, true
+		}
+	}
+
+	return alsaControl{}, false
+}
@@ -0,0 +1,94 @@
+package reeemiks
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseAmixerControls(t *testing.T) {
+	output, err := os.ReadFile("testdata/amixer-scontents.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []alsaControl{
+		{
+			name:     "Master",
+			playback: true,
+			levels:   map[bool][]int{true: {75}},
+			switches: map[bool][]bool{true: {true}},
+		},
+		{
+			name:     "Headphone",
+			playback: true,
+			levels:   map[bool][]int{true: {100, 100}},
+			switches: map[bool][]bool{true: {false, false}},
+		},
+		{
+			name:     "PCM",
+			playback: true,
+			levels:   map[bool][]int{true: {50, 70}},
+			switches: map[bool][]bool{true: {true, true}},
+		},
+		{
+			name:     "Line",
+			playback: true,
+			capture:  true,
+			levels:   map[bool][]int{true: {74, 74}, false: {0, 0}},
+			switches: map[bool][]bool{true: {true, true}, false: {false, false}},
+		},
+		{
+			name:     "Mic Boost",
+			playback: true,
+			levels:   map[bool][]int{true: {33, 33}},
+			switches: map[bool][]bool{true: {true, true}},
+		},
+		{
+			name:     "Capture",
+			capture:  true,
+			levels:   map[bool][]int{false: {62, 62}},
+			switches: map[bool][]bool{false: {true, true}},
+		},
+		{
+			name:     "Capture",
+			index:    1,
+			capture:  true,
+			levels:   map[bool][]int{false: {0, 0}},
+			switches: map[bool][]bool{false: {false, false}},
+		},
+	}
+
+	controls := parseAmixerControls(output)
+
+	if len(controls) != len(expected) {
+		t.Fatalf("expected %d controls, got %d: %+v", len(expected), len(controls), controls)
+	}
+
+	for i, control := range controls {
+		if !reflect.DeepEqual(control, expected[i]) {
+			t.Errorf("control %d: expected %+v, got %+v", i, expected[i], control)
+		}
+	}
+}
+
+func TestFindALSAControl(t *testing.T) {
+	output, err := os.ReadFile("testdata/amixer-scontents.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	controls := parseAmixerControls(output)
+
+	if control, ok := findALSAControl(controls, "Capture", false); !ok || control.index != 0 {
+		t.Errorf("expected the first Capture control, got %+v", control)
+	}
+
+	if _, ok := findALSAControl(controls, "Capture", true); ok {
+		t.Error("expected a capture-only control not to be found for playback")
+	}
+
+	if _, ok := findALSAControl(controls, "IEC958", true); ok {
+		t.Error("expected a control without a volume not to be found")
+	}
+}
@@ -2,7 +2,10 @@ package reeemiks
 
 import (
 	"fmt"
-	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	// "regexp"
 
 	"github.com/jfreymuth/pulse/proto"
@@ -14,41 +17,152 @@ type paSessionFinder struct {
 	sessionLogger *zap.SugaredLogger
 	config        *CanonicalConfig
 
-	client *proto.Client
-	conn   net.Conn
+	client *paConnection
+	server pulseServer
+
+	// put in front of every session name, empty for the first (primary) server and "<namespace>/" for the others
+	namespace string
+
+	// only the primary server gets the config's virtual devices
+	primary bool
+
+	// meters the sessions we hand out, when asked to
+	peakMeter *paPeakMeter
+
+	// the master sessions we handed out last, rebound when the server's defaults change
+	masterSink   *masterSession
+	masterSource *masterSession
+	masterLock   sync.Mutex
+
+	serverChanges chan bool
+	stopChannel   chan bool
+
+	// told whenever every session we handed out went stale, which happens when we had to reconnect
+	sessionsResetChannel chan bool
 }
 
+// PulseAudio's subscription mask and event facility values, as defined in pulse/def.h
+const (
+	paSubscriptionMaskServer = 0x0080
+	paEventFacilityMask      = 0x000F
+	paEventFacilityServer    = 0x0007
+)
+
 func newSessionFinder(logger *zap.SugaredLogger, config *CanonicalConfig) (SessionFinder, error) {
-	client, conn, err := proto.Connect("")
-	if err != nil {
-		logger.Warnw("Failed to establish PulseAudio connection", "error", err)
-		return nil, fmt.Errorf("establish PulseAudio connection: %w", err)
+	switch config.snapshot().AudioBackend {
+	case audioBackendALSA:
+		sf, err := newALSASessionFinder(logger, config)
+		if err != nil {
+			logger.Warnw("Failed to start ALSA backend", "error", err)
+			return nil, fmt.Errorf("start ALSA backend: %w", err)
+		}
+
+		return sf, nil
+
+	case audioBackendPipeWire:
+		sf, err := newPWSessionFinder(logger, config)
+		if err == nil {
+			return sf, nil
+		}
+
+		logger.Warnw("Failed to start PipeWire backend, falling back to PulseAudio", "error", err)
 	}
 
-	request := proto.SetClientName{
-		Props: proto.PropList{
-			"application.name": proto.PropListString("Reeemiks"),
-		},
+	sf, err := newPulseSessionFinder(logger, config)
+	if err == nil {
+		return sf, nil
 	}
-	reply := proto.SetClientNameReply{}
 
-	if err := client.Request(&request, &reply); err != nil {
+	// without a sound server there may still be a sound card to drive directly
+	alsaSessionFinder, alsaErr := newALSASessionFinder(logger, config)
+	if alsaErr != nil {
+		logger.Debugw("No ALSA mixer to fall back to either", "error", alsaErr)
 		return nil, err
 	}
 
+	logger.Info("No PulseAudio server found, using the ALSA mixer instead")
+
+	return alsaSessionFinder, nil
+}
+
+// newPASessionFinder connects to a single PulseAudio server. the primary one has to be there from the start,
+// any other server that isn't yet is left to the reconnect loop, its sessions showing up once it answers
+func newPASessionFinder(logger *zap.SugaredLogger, config *CanonicalConfig, server pulseServer, primary bool) (*paSessionFinder, error) {
+	if server.Namespace != "" {
+		logger = logger.Named(server.Namespace)
+	}
+
 	sf := &paSessionFinder{
-		logger:        logger.Named("session_finder"),
-		sessionLogger: logger.Named("sessions"),
-		config:        config,
-		client:        client,
-		conn:          conn,
+		logger:               logger.Named("session_finder"),
+		sessionLogger:        logger.Named("sessions"),
+		config:               config,
+		server:               server,
+		primary:              primary,
+		peakMeter:            newPAPeakMeter(logger, server.Address, server.Cookie),
+		serverChanges:        make(chan bool, 1),
+		stopChannel:          make(chan bool),
+		sessionsResetChannel: make(chan bool, 1),
+	}
+
+	if server.Namespace != "" {
+		sf.namespace = server.Namespace + "/"
+	}
+
+	sf.client = newPAConnection(logger, "Reeemiks", server.Address, server.Cookie, sf.handleServerEvent)
+
+	if err := sf.client.connect(); err == nil {
+		sf.setupConnection()
+	} else if primary {
+		logger.Warnw("Failed to establish PulseAudio connection", "error", err)
+		return nil, fmt.Errorf("establish PulseAudio connection: %w", err)
+	} else {
+		logger.Warnw("Failed to connect to PulseAudio server, will keep trying", "server", server.Address, "error", err)
+		sf.client.markDisconnected()
 	}
 
+	go sf.followDefaultDevices()
+	go sf.client.maintain(sf.reconnected)
+
 	sf.logger.Debug("Created PA session finder instance")
 
 	return sf, nil
 }
 
+// setupConnection gets a fresh connection to where the last one left off
+func (sf *paSessionFinder) setupConnection() {
+	if err := sf.client.Request(&proto.Subscribe{Mask: paSubscriptionMaskServer}, nil); err != nil {
+		sf.logger.Warnw("Failed to subscribe to default device changes, master and mic won't follow them", "error", err)
+	}
+
+	if !sf.primary {
+		return
+	}
+
+	if err := sf.syncVirtualDevices(); err != nil {
+		sf.logger.Warnw("Failed to create virtual devices", "error", err)
+	}
+}
+
+// reconnected is called once the server is back. whatever it restarted with, our sessions point at streams and
+// devices that may not exist anymore (and the meter's streams are gone), so the session map has to start over
+func (sf *paSessionFinder) reconnected() {
+	sf.setupConnection()
+	sf.peakMeter.release()
+
+	sf.masterLock.Lock()
+	sf.masterSink, sf.masterSource = nil, nil
+	sf.masterLock.Unlock()
+
+	select {
+	case sf.sessionsResetChannel <- true:
+	default:
+	}
+}
+
+func (sf *paSessionFinder) sessionsReset() <-chan bool {
+	return sf.sessionsResetChannel
+}
+
 func (sf *paSessionFinder) GetAllSessions() ([]Session, error) {
 	sessions := []Session{}
 
@@ -78,7 +192,16 @@ func (sf *paSessionFinder) GetAllSessions() ([]Session, error) {
 }
 
 func (sf *paSessionFinder) Release() error {
-	if err := sf.conn.Close(); err != nil {
+	close(sf.stopChannel)
+	sf.peakMeter.release()
+
+	if sf.primary {
+		if err := sf.unloadVirtualDevices(); err != nil {
+			sf.logger.Warnw("Failed to remove virtual devices", "error", err)
+		}
+	}
+
+	if err := sf.client.close(); err != nil {
 		sf.logger.Warnw("Failed to close PulseAudio connection", "error", err)
 		return fmt.Errorf("close PulseAudio connection: %w", err)
 	}
@@ -100,7 +223,11 @@ func (sf *paSessionFinder) getMasterSinkSession() (Session, error) {
 	}
 
 	// create the master sink session
-	sink := newMasterSession(sf.sessionLogger, sf.client, reply.SinkIndex, reply.Channels, true)
+	sink := newMasterSession(sf.sessionLogger, sf.client, sf.peakMeter, reply.SinkIndex, reply.Channels, true, sf.namespace)
+
+	sf.masterLock.Lock()
+	sf.masterSink = sink
+	sf.masterLock.Unlock()
 
 	return sink, nil
 }
@@ -117,11 +244,207 @@ func (sf *paSessionFinder) getMasterSourceSession() (Session, error) {
 	}
 
 	// create the master source session
-	source := newMasterSession(sf.sessionLogger, sf.client, reply.SourceIndex, reply.Channels, false)
+	source := newMasterSession(sf.sessionLogger, sf.client, sf.peakMeter, reply.SourceIndex, reply.Channels, false, sf.namespace)
+
+	sf.masterLock.Lock()
+	sf.masterSource = source
+	sf.masterLock.Unlock()
 
 	return source, nil
 }
 
+// handleServerEvent is handed the server events we subscribed to, which PulseAudio sends whenever
+// the default sink or source changes. it runs on the client's read loop, so it can't make requests itself:
+// their replies would never be read
+func (sf *paSessionFinder) handleServerEvent(message interface{}) {
+	event, ok := message.(*proto.SubscribeEvent)
+	if !ok || event.Event&paEventFacilityMask != paEventFacilityServer {
+		return
+	}
+
+	select {
+	case sf.serverChanges <- true:
+	default:
+	}
+}
+
+// followDefaultDevices rebinds the master sessions to the new defaults whenever they change
+func (sf *paSessionFinder) followDefaultDevices() {
+	for {
+		select {
+		case <-sf.stopChannel:
+			return
+		case <-sf.serverChanges:
+			sf.rebindMasterSessions()
+		}
+	}
+}
+
+func (sf *paSessionFinder) rebindMasterSessions() {
+	sf.masterLock.Lock()
+	sink, source := sf.masterSink, sf.masterSource
+	sf.masterLock.Unlock()
+
+	if sink != nil {
+		request := proto.GetSinkInfo{
+			SinkIndex: proto.Undefined,
+		}
+		reply := proto.GetSinkInfoReply{}
+
+		if err := sf.client.Request(&request, &reply); err != nil {
+			sf.logger.Warnw("Failed to get master sink info", "error", err)
+		} else if streamIndex, _ := sink.stream(); streamIndex != reply.SinkIndex {
+			sf.logger.Infow("Default sink changed, rebinding master session", "sink", reply.SinkName)
+			sink.rebind(reply.SinkIndex, reply.Channels)
+		}
+	}
+
+	if source != nil {
+		request := proto.GetSourceInfo{
+			SourceIndex: proto.Undefined,
+		}
+		reply := proto.GetSourceInfoReply{}
+
+		if err := sf.client.Request(&request, &reply); err != nil {
+			sf.logger.Warnw("Failed to get master source info", "error", err)
+		} else if streamIndex, _ := source.stream(); streamIndex != reply.SourceIndex {
+			sf.logger.Infow("Default source changed, rebinding mic session", "source", reply.SourceName)
+			source.rebind(reply.SourceIndex, reply.Channels)
+		}
+	}
+}
+
+// resolveSink finds a sink by its name, its description or the key of its device session
+func (sf *paSessionFinder) resolveSink(sink string) (string, error) {
+
+	// device sessions are keyed "reeemiks.device: <description>~<node name>"
+	if strings.HasPrefix(sink, "reeemiks.device: ") {
+		if idx := strings.LastIndex(sink, "~"); idx != -1 {
+			sink = sink[idx+1:]
+		}
+	}
+
+	reply := proto.GetSinkInfoListReply{}
+	if err := sf.client.Request(&proto.GetSinkInfoList{}, &reply); err != nil {
+		return "", fmt.Errorf("get sink list: %w", err)
+	}
+
+	for _, info := range reply {
+		if strings.EqualFold(info.SinkName, sink) {
+			return info.SinkName, nil
+		}
+	}
+
+	for _, info := range reply {
+		if description, ok := info.Properties["device.description"]; ok && strings.EqualFold(description.String(), sink) {
+			return info.SinkName, nil
+		}
+	}
+
+	return "", fmt.Errorf("no such sink: %s", sink)
+}
+
+func (sf *paSessionFinder) sinkNames() ([]string, error) {
+	reply := proto.GetSinkInfoListReply{}
+	if err := sf.client.Request(&proto.GetSinkInfoList{}, &reply); err != nil {
+		return nil, fmt.Errorf("get sink list: %w", err)
+	}
+
+	names := []string{}
+	for _, info := range reply {
+		names = append(names, info.SinkName)
+	}
+
+	return names, nil
+}
+
+func (sf *paSessionFinder) defaultSink() (string, error) {
+	reply := proto.GetServerInfoReply{}
+	if err := sf.client.Request(&proto.GetServerInfo{}, &reply); err != nil {
+		return "", fmt.Errorf("get server info: %w", err)
+	}
+
+	return reply.DefaultSinkName, nil
+}
+
+// setDefaultSink switches the default sink, master follows it once the server tells us it changed
+func (sf *paSessionFinder) setDefaultSink(name string) error {
+	return sf.client.Request(&proto.SetDefaultSink{SinkName: name}, nil)
+}
+
+func (sf *paSessionFinder) sessionSink(session Session) (string, error) {
+	paSession, err := movableSession(session)
+	if err != nil {
+		return "", err
+	}
+
+	inputReply := proto.GetSinkInputInfoReply{}
+	if err := sf.client.Request(&proto.GetSinkInputInfo{SinkInputIndex: paSession.sinkInputIndex}, &inputReply); err != nil {
+		return "", fmt.Errorf("get sink input info: %w", err)
+	}
+
+	sinkReply := proto.GetSinkInfoReply{}
+	if err := sf.client.Request(&proto.GetSinkInfo{SinkIndex: inputReply.SinkIndex}, &sinkReply); err != nil {
+		return "", fmt.Errorf("get sink info: %w", err)
+	}
+
+	return sinkReply.SinkName, nil
+}
+
+func (sf *paSessionFinder) moveSession(session Session, name string) error {
+	paSession, err := movableSession(session)
+	if err != nil {
+		return err
+	}
+
+	request := proto.MoveSinkInput{
+		SinkInputIndex: paSession.sinkInputIndex,
+		DeviceIndex:    proto.Undefined,
+		DeviceName:     name,
+	}
+
+	if err := sf.client.Request(&request, nil); err != nil {
+		return fmt.Errorf("move sink input: %w", err)
+	}
+
+	return nil
+}
+
+// only application streams can move, devices and master sessions are sinks (or sources) themselves
+func movableSession(session Session) (*paSession, error) {
+	paSession, ok := session.(*paSession)
+	if !ok || strings.HasPrefix(paSession.processName, "reeemiks.device: ") {
+		return nil, fmt.Errorf("%s: %w", session.Key(), errSessionNotMovable)
+	}
+
+	return paSession, nil
+}
+
+// localProcessID parses a stream's application.process.id, as long as application.process.host says it's one of ours:
+// a PID from a remote server's machine could be anything here
+func localProcessID(pid string, host string) int {
+	if hostname, err := os.Hostname(); err == nil && host != "" && host != hostname {
+		return 0
+	}
+
+	value, err := strconv.Atoi(pid)
+	if err != nil || value <= 0 {
+		return 0
+	}
+
+	return value
+}
+
+// paProp returns a string property, or nothing if it isn't set
+func paProp(props proto.PropList, key string) string {
+	value, ok := props[key]
+	if !ok {
+		return ""
+	}
+
+	return value.String()
+}
+
 func (sf *paSessionFinder) enumerateAndAddSessions(sessions *[]Session) error {
 	request := proto.GetSinkInputInfoList{}
 	reply := proto.GetSinkInputInfoListReply{}
@@ -131,7 +454,7 @@ func (sf *paSessionFinder) enumerateAndAddSessions(sessions *[]Session) error {
 		return fmt.Errorf("get sink input list: %w", err)
 	}
 
-	if sf.config.ReeemiksMatching == "default" {
+	if sf.config.snapshot().ReeemiksMatching == "default" {
 		for _, info := range reply {
 			name, ok := info.Properties["application.process.binary"]
 
@@ -141,7 +464,8 @@ func (sf *paSessionFinder) enumerateAndAddSessions(sessions *[]Session) error {
 			}
 
 			// create the reeemiks session object
-			newSession := newPASession(sf.sessionLogger, sf.client, info.SinkInputIndex, info.Channels, name.String())
+			newSession := newPASession(sf.sessionLogger, sf.client, sf.peakMeter, info.SinkInputIndex, info.Channels, name.String(), sf.namespace)
+			newSession.process = readProcessInfo(localProcessID(paProp(info.Properties, "application.process.id"), paProp(info.Properties, "application.process.host")))
 
 			// add it to our slice
 			*sessions = append(*sessions, newSession)
@@ -169,7 +493,8 @@ func (sf *paSessionFinder) enumerateAndAddSessions(sessions *[]Session) error {
 			sf.logger.Info("Process: ", name)
 
 			// create the reeemiks session object
-			newSession := newPASession(sf.sessionLogger, sf.client, info.SinkInputIndex, info.Channels, name)
+			newSession := newPASession(sf.sessionLogger, sf.client, sf.peakMeter, info.SinkInputIndex, info.Channels, name, sf.namespace)
+			newSession.process = readProcessInfo(localProcessID(paProp(info.Properties, "application.process.id"), paProp(info.Properties, "application.process.host")))
 
 			// add it to our slice
 			*sessions = append(*sessions, newSession)
@@ -209,7 +534,7 @@ func (sf *paSessionFinder) enumerateAndAddSessions(sessions *[]Session) error {
 				sf.logger.Info("Sink: ", name)
 
 				// create the reeemiks session object
-				newSession := newPASession(sf.sessionLogger, sf.client, info.SinkIndex, info.Channels, name)
+				newSession := newPASession(sf.sessionLogger, sf.client, sf.peakMeter, info.SinkIndex, info.Channels, name, sf.namespace)
 
 				// add it to our slice
 				*sessions = append(*sessions, newSession)
@@ -0,0 +1,715 @@
+package reeemiks
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// pwSessionFinder reaches PipeWire through its command line tools rather than through pipewire-pulse. it keeps
+// a live picture of every node by following `pw-dump --monitor`, which also makes JACK clients and filter-chains
+// visible, and changes node Props through a single long-running `pw-cli`. both are restarted if they exit
+type pwSessionFinder struct {
+	logger        *zap.SugaredLogger
+	sessionLogger *zap.SugaredLogger
+	config        *CanonicalConfig
+
+	monitor *exec.Cmd
+	cli     *pwCLI
+
+	nodes    map[uint32]*pwNodeInfo
+	defaults map[string]string
+	lock     sync.Mutex
+
+	// told whenever pw-dump had to be restarted, since node IDs don't survive a PipeWire restart
+	sessionsResetChannel chan bool
+
+	stopChannel chan bool
+	stopOnce    sync.Once
+}
+
+// pwCLI feeds commands to an interactive pw-cli, so changing a volume doesn't cost a process of its own.
+// every command is followed by one pw-cli doesn't know, whose error tells us it's done with the real one,
+// so whatever went wrong with that makes it back to the caller
+type pwCLI struct {
+	logger *zap.SugaredLogger
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	output chan string // pw-cli's output, one line at a time. closed once it exits
+
+	// numbers the commands marking the end of each real one
+	sequence int
+
+	lock sync.Mutex
+}
+
+// pwObject is a single entry of pw-dump's output. with --monitor, later entries only carry what changed,
+// and a removed object comes with a null info
+type pwObject struct {
+	ID       uint32            `json:"id"`
+	Type     string            `json:"type"`
+	Info     json.RawMessage   `json:"info"`
+	Metadata []pwMetadataEntry `json:"metadata"`
+}
+
+type pwNodeInfo struct {
+	State  string                       `json:"state"`
+	Props  map[string]interface{}       `json:"props"`
+	Params map[string][]json.RawMessage `json:"params"`
+}
+
+// pwProps is the part of a node's Props param we care about. channel volumes are linear, unlike pulse's cubic ones
+type pwProps struct {
+	Volume         *float64  `json:"volume,omitempty"`
+	Mute           *bool     `json:"mute,omitempty"`
+	ChannelVolumes []float64 `json:"channelVolumes,omitempty"`
+}
+
+type pwMetadataEntry struct {
+	Subject uint32          `json:"subject"`
+	Key     string          `json:"key"`
+	Value   json.RawMessage `json:"value"`
+}
+
+const (
+	pwTypeNode = "PipeWire:Interface:Node"
+
+	pwMediaClassStream = "Stream/Output/Audio"
+	pwMediaClassSink   = "Audio/Sink"
+	pwMediaClassDuplex = "Audio/Duplex"
+
+	pwDefaultSinkKey   = "default.audio.sink"
+	pwDefaultSourceKey = "default.audio.source"
+
+	// how long pw-dump gets to deliver its first full dump before we give up on it
+	pwDumpTimeout = 3 * time.Second
+
+	// how long pw-cli gets to answer a command before we give up on it and start another one
+	pwCLITimeout = time.Second
+
+	// pw-cli doesn't know this command, and says so by repeating it back
+	pwCLIMarker = "reeemiks-done-"
+
+	// how many lines of pw-cli's output are kept for the command that's running
+	pwCLIOutputBuffer = 64
+
+	// how long to wait before starting pw-dump again once it exits, doubling up to the max while it keeps failing
+	pwRestartMinDelay = 500 * time.Millisecond
+	pwRestartMaxDelay = 30 * time.Second
+)
+
+// pwCLICommand is what runs pw-cli, a variable so tests can stand in for it
+var pwCLICommand = "pw-cli"
+
+func newPWSessionFinder(logger *zap.SugaredLogger, config *CanonicalConfig) (*pwSessionFinder, error) {
+	sf := &pwSessionFinder{
+		logger:               logger.Named("session_finder"),
+		sessionLogger:        logger.Named("sessions"),
+		config:               config,
+		nodes:                make(map[uint32]*pwNodeInfo),
+		defaults:             make(map[string]string),
+		sessionsResetChannel: make(chan bool, 1),
+		stopChannel:          make(chan bool),
+	}
+
+	sf.cli = &pwCLI{logger: sf.logger.Named("pw-cli")}
+
+	exited, err := sf.startMonitor()
+	if err != nil {
+		return nil, err
+	}
+
+	go sf.maintainMonitor(exited)
+
+	sf.logger.Debug("Created PipeWire session finder instance")
+
+	return sf, nil
+}
+
+// startMonitor runs pw-dump and waits for its first full dump. the returned channel is closed once it stops
+func (sf *pwSessionFinder) startMonitor() (chan bool, error) {
+	monitor := exec.Command("pw-dump", "--monitor")
+
+	stdout, err := monitor.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("get pw-dump output: %w", err)
+	}
+
+	if err := monitor.Start(); err != nil {
+		return nil, fmt.Errorf("start pw-dump: %w", err)
+	}
+
+	ready := make(chan error, 1)
+	exited := make(chan bool)
+
+	go func() {
+		defer close(exited)
+
+		decoder := json.NewDecoder(bufio.NewReader(stdout))
+		first := true
+
+		for {
+			objects := []pwObject{}
+			if err := decoder.Decode(&objects); err != nil {
+				if first {
+					ready <- fmt.Errorf("read pw-dump output: %w", err)
+				} else {
+					sf.logger.Debugw("Stopped reading pw-dump output", "error", err)
+				}
+
+				return
+			}
+
+			sf.apply(objects)
+
+			if first {
+				first = false
+				ready <- nil
+			}
+		}
+	}()
+
+	select {
+	case err = <-ready:
+	case <-time.After(pwDumpTimeout):
+		err = errors.New("timed out waiting for pw-dump")
+	}
+
+	if err != nil {
+		monitor.Process.Kill()
+		monitor.Wait()
+
+		return nil, err
+	}
+
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+
+	// released while we were starting up
+	select {
+	case <-sf.stopChannel:
+		monitor.Process.Kill()
+		monitor.Wait()
+
+		return nil, errors.New("session finder released")
+	default:
+	}
+
+	sf.monitor = monitor
+
+	return exited, nil
+}
+
+// maintainMonitor starts pw-dump over whenever it exits (PipeWire restarting takes it down with it),
+// waiting a little longer after every attempt that fails
+func (sf *pwSessionFinder) maintainMonitor(exited chan bool) {
+	for {
+		select {
+		case <-sf.stopChannel:
+			return
+		case <-exited:
+		}
+
+		// whoever takes the monitor out reaps it, which is Release if it got here first
+		sf.lock.Lock()
+		monitor := sf.monitor
+		sf.monitor = nil
+
+		// the next dump starts from scratch, and what we knew may be long gone
+		sf.nodes = make(map[uint32]*pwNodeInfo)
+		sf.defaults = make(map[string]string)
+		sf.lock.Unlock()
+
+		if monitor == nil {
+			return
+		}
+
+		monitor.Wait()
+
+		sf.logger.Warn("pw-dump exited, restarting it")
+
+		delay := pwRestartMinDelay
+
+		for {
+			select {
+			case <-sf.stopChannel:
+				return
+			case <-time.After(delay):
+			}
+
+			var err error
+			if exited, err = sf.startMonitor(); err == nil {
+				break
+			}
+
+			sf.logger.Debugw("Failed to restart pw-dump", "error", err, "retryIn", delay*2)
+
+			delay *= 2
+			if delay > pwRestartMaxDelay {
+				delay = pwRestartMaxDelay
+			}
+		}
+
+		sf.logger.Info("Restarted pw-dump")
+
+		// whatever took pw-dump down most likely left pw-cli talking to a PipeWire that's gone, so the next write gets a fresh one
+		sf.cli.stop()
+
+		select {
+		case sf.sessionsResetChannel <- true:
+		default:
+		}
+	}
+}
+
+func (sf *pwSessionFinder) sessionsReset() <-chan bool {
+	return sf.sessionsResetChannel
+}
+
+// apply merges a batch of objects from pw-dump into what we know
+func (sf *pwSessionFinder) apply(objects []pwObject) {
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+
+	for _, object := range objects {
+		if len(object.Metadata) > 0 {
+			sf.applyMetadata(object.Metadata)
+		}
+
+		if object.Type != "" && object.Type != pwTypeNode {
+			continue
+		}
+
+		if bytes.Equal(object.Info, []byte("null")) {
+			delete(sf.nodes, object.ID)
+			continue
+		}
+
+		if len(object.Info) == 0 {
+			continue
+		}
+
+		update := &pwNodeInfo{}
+		if err := json.Unmarshal(object.Info, update); err != nil {
+			sf.logger.Debugw("Failed to parse PipeWire node", "id", object.ID, "error", err)
+			continue
+		}
+
+		node, ok := sf.nodes[object.ID]
+		if !ok {
+			sf.nodes[object.ID] = update
+			continue
+		}
+
+		if update.State != "" {
+			node.State = update.State
+		}
+
+		if update.Props != nil {
+			node.Props = update.Props
+		}
+
+		// copies of the node handed out earlier may still be reading the old params, so replace them rather than edit them
+		if len(update.Params) > 0 {
+			node.Params = mergeParams(node.Params, update.Params)
+		}
+	}
+}
+
+func (sf *pwSessionFinder) applyMetadata(entries []pwMetadataEntry) {
+	for _, entry := range entries {
+		if entry.Subject != 0 || (entry.Key != pwDefaultSinkKey && entry.Key != pwDefaultSourceKey) {
+			continue
+		}
+
+		value := struct {
+			Name string `json:"name"`
+		}{}
+
+		if err := json.Unmarshal(entry.Value, &value); err != nil || value.Name == "" {
+			delete(sf.defaults, entry.Key)
+			continue
+		}
+
+		sf.defaults[entry.Key] = value.Name
+	}
+}
+
+func (sf *pwSessionFinder) GetAllSessions() ([]Session, error) {
+	sessions := []Session{
+		newPWSession(sf.sessionLogger, sf, 0, pwDefaultSinkKey, masterSessionName),
+		newPWSession(sf.sessionLogger, sf, 0, pwDefaultSourceKey, inputSessionName),
+	}
+
+	type pwNamedNode struct {
+		id        uint32
+		name      string
+		processID int
+	}
+
+	// sessions read their volume back through the lock when they're logged, so only collect the nodes while holding it
+	sf.lock.Lock()
+	named := []pwNamedNode{}
+	for id, node := range sf.nodes {
+		name, ok := sf.sessionName(node)
+		if !ok {
+			continue
+		}
+
+		named = append(named, pwNamedNode{id, name, localProcessID(pwProp(node.Props, "application.process.id"), pwProp(node.Props, "application.process.host"))})
+	}
+	sf.lock.Unlock()
+
+	for _, node := range named {
+		session := newPWSession(sf.sessionLogger, sf, node.id, "", node.name)
+		session.process = readProcessInfo(node.processID)
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// sessionName names a node the same way the PulseAudio backend names its sink input or sink, so configs carry over
+func (sf *pwSessionFinder) sessionName(node *pwNodeInfo) (string, bool) {
+	mediaClass := pwProp(node.Props, "media.class")
+
+	switch {
+	case mediaClass == pwMediaClassStream || pwProp(node.Props, "client.api") == "jack":
+		if sf.config.snapshot().ReeemiksMatching == "default" {
+			binary := pwProp(node.Props, "application.process.binary")
+			return binary, binary != ""
+		}
+
+		mediaName := pwProp(node.Props, "media.name")
+		applicationName := pwProp(node.Props, "application.name")
+
+		if mediaName == "" || applicationName == "" {
+			return "", false
+		}
+
+		return mediaName + ": " + applicationName, true
+
+	case mediaClass == pwMediaClassSink || mediaClass == pwMediaClassDuplex:
+		description := pwProp(node.Props, "media.name")
+		if description == "" {
+			description = pwProp(node.Props, "node.description")
+		}
+
+		return "reeemiks.device: " + description + "~" + pwProp(node.Props, "node.name"), true
+	}
+
+	return "", false
+}
+
+// node returns a copy of a node's info, looking up the current default device when given a defaults key
+func (sf *pwSessionFinder) node(id uint32, defaultKey string) (uint32, *pwNodeInfo, bool) {
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+
+	if defaultKey != "" {
+		name, ok := sf.defaults[defaultKey]
+		if !ok {
+			return 0, nil, false
+		}
+
+		for nodeID, node := range sf.nodes {
+			if pwProp(node.Props, "node.name") == name {
+				copied := *node
+				return nodeID, &copied, true
+			}
+		}
+
+		return 0, nil, false
+	}
+
+	node, ok := sf.nodes[id]
+	if !ok {
+		return 0, nil, false
+	}
+
+	copied := *node
+	return id, &copied, true
+}
+
+// setProps changes a node's Props, and once pw-cli took the change, remembers it until pw-dump reports it back
+func (sf *pwSessionFinder) setProps(id uint32, props pwProps) error {
+	encoded, err := json.Marshal(props)
+	if err != nil {
+		return fmt.Errorf("encode props: %w", err)
+	}
+
+	if err := sf.cli.run(fmt.Sprintf("set-param %d Props %s", id, encoded)); err != nil {
+		return fmt.Errorf("pw-cli set-param: %w", err)
+	}
+
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+
+	node, ok := sf.nodes[id]
+	if !ok {
+		return nil
+	}
+
+	current := nodeProps(node)
+
+	if props.Mute != nil {
+		current.Mute = props.Mute
+	}
+
+	if props.ChannelVolumes != nil {
+		current.ChannelVolumes = props.ChannelVolumes
+	}
+
+	if encoded, err := json.Marshal(current); err == nil {
+		node.Params = mergeParams(node.Params, map[string][]json.RawMessage{"Props": {encoded}})
+	}
+
+	return nil
+}
+
+func (sf *pwSessionFinder) Release() error {
+	sf.stopOnce.Do(func() {
+		close(sf.stopChannel)
+	})
+
+	sf.cli.stop()
+
+	sf.lock.Lock()
+	monitor := sf.monitor
+	sf.monitor = nil
+	sf.lock.Unlock()
+
+	// a monitor that's being restarted is left to the restart loop, which sees we're stopping
+	if monitor != nil {
+		if err := monitor.Process.Kill(); err != nil {
+			sf.logger.Warnw("Failed to stop pw-dump", "error", err)
+			return fmt.Errorf("stop pw-dump: %w", err)
+		}
+
+		monitor.Wait()
+	}
+
+	sf.logger.Debug("Released PipeWire session finder instance")
+
+	return nil
+}
+
+// run hands pw-cli a command, starting it first if it isn't running, and returns any error pw-cli reports for it.
+// a pw-cli that exited in the meantime (with PipeWire, usually) shows up as a failed write, so that gets one more
+// try on a fresh one
+func (c *pwCLI) run(command string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var err error
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if c.cmd == nil {
+			if err = c.start(); err != nil {
+				return err
+			}
+		}
+
+		if !c.drain() {
+			err = errors.New("pw-cli exited")
+			c.stopLocked()
+			continue
+		}
+
+		c.sequence++
+		marker := fmt.Sprintf("%s%d", pwCLIMarker, c.sequence)
+
+		if _, err = io.WriteString(c.stdin, command+"\n"+marker+"\n"); err == nil {
+			return c.wait(marker)
+		}
+
+		c.logger.Debugw("pw-cli went away, starting it again", "error", err)
+		c.stopLocked()
+	}
+
+	return fmt.Errorf("write to pw-cli: %w", err)
+}
+
+// wait reads pw-cli's output up to its complaint about the marker, returning the first error it reported before that.
+// the lock must be held
+func (c *pwCLI) wait(marker string) error {
+	timeout := time.After(pwCLITimeout)
+	var reported error
+
+	for {
+		select {
+		case line, ok := <-c.output:
+			if !ok {
+				c.stopLocked()
+
+				if reported != nil {
+					return reported
+				}
+
+				return errors.New("pw-cli exited")
+			}
+
+			if strings.Contains(line, marker) {
+				return reported
+			}
+
+			if reported == nil && strings.Contains(strings.ToLower(line), "error") {
+				reported = errors.New(line)
+			}
+
+		case <-timeout:
+
+			// whatever it's stuck on, a fresh pw-cli won't be
+			c.stopLocked()
+			return errors.New("timed out waiting for pw-cli")
+		}
+	}
+}
+
+// drain logs whatever pw-cli said since the last command finished, like errors PipeWire sent back later on,
+// and returns false if pw-cli has exited since. the lock must be held
+func (c *pwCLI) drain() bool {
+	for {
+		select {
+		case line, ok := <-c.output:
+			if !ok {
+				return false
+			}
+
+			if strings.Contains(strings.ToLower(line), "error") {
+				c.logger.Warnw("pw-cli reported an error", "output", line)
+			}
+
+		default:
+			return true
+		}
+	}
+}
+
+// start runs pw-cli in interactive mode, collecting whatever it says back. the lock must be held
+func (c *pwCLI) start() error {
+	cmd := exec.Command(pwCLICommand)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("get pw-cli input: %w", err)
+	}
+
+	output, outputWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("get pw-cli output: %w", err)
+	}
+
+	cmd.Stdout = outputWriter
+	cmd.Stderr = outputWriter
+
+	err = cmd.Start()
+	outputWriter.Close()
+
+	if err != nil {
+		output.Close()
+		return fmt.Errorf("start pw-cli: %w", err)
+	}
+
+	lines := make(chan string, pwCLIOutputBuffer)
+
+	go func() {
+		defer output.Close()
+		defer close(lines)
+
+		scanner := bufio.NewScanner(output)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+
+			// nobody reads while there's no command running, so what doesn't fit by the next one is dropped
+			select {
+			case lines <- line:
+			default:
+				c.logger.Debugw("Dropped pw-cli output", "output", line)
+			}
+		}
+	}()
+
+	c.cmd = cmd
+	c.stdin = stdin
+	c.output = lines
+
+	return nil
+}
+
+func (c *pwCLI) stop() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.stopLocked()
+}
+
+// stopLocked closes pw-cli's input, which makes it exit, and reaps it. the lock must be held
+func (c *pwCLI) stopLocked() {
+	if c.cmd == nil {
+		return
+	}
+
+	c.stdin.Close()
+
+	cmd := c.cmd
+	c.cmd, c.stdin, c.output = nil, nil, nil
+
+	// don't let a pw-cli that's stuck connecting hold us up
+	timer := time.AfterFunc(time.Second, func() {
+		cmd.Process.Kill()
+	})
+
+	cmd.Wait()
+	timer.Stop()
+}
+
+func mergeParams(params map[string][]json.RawMessage, update map[string][]json.RawMessage) map[string][]json.RawMessage {
+	merged := make(map[string][]json.RawMessage, len(params)+len(update))
+
+	for name, values := range params {
+		merged[name] = values
+	}
+
+	for name, values := range update {
+		merged[name] = values
+	}
+
+	return merged
+}
+
+// nodeProps returns the first Props param of a node, which is the one holding its volume and mute state
+func nodeProps(node *pwNodeInfo) pwProps {
+	props := pwProps{}
+
+	if values := node.Params["Props"]; len(values) > 0 {
+		json.Unmarshal(values[0], &props)
+	}
+
+	return props
+}
+
+func pwProp(props map[string]interface{}, key string) string {
+	value, ok := props[key]
+	if !ok {
+		return ""
+	}
+
+	if s, ok := value.(string); ok {
+		return s
+	}
+
+	return fmt.Sprint(value)
+}
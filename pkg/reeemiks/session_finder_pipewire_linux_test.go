@@ -0,0 +1,109 @@
+package reeemiks
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// fakePWCLI answers like pw-cli does: set-param on a node it doesn't know and unknown commands are errors,
+// and quit makes it exit
+const fakePWCLI = `#!/bin/sh
+while read -r command id rest; do
+	case "$command" in
+	set-param)
+		if [ "$id" = 99 ]; then
+			echo "Error: \"set-param: unknown global $id\"" >&2
+		fi
+		;;
+	quit)
+		exit 0
+		;;
+	*)
+		echo "Error: \"Command \"$command\" does not exist. Type 'help' for usage.\"" >&2
+		;;
+	esac
+done
+`
+
+func useFakePWCLI(t *testing.T) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "pw-cli")
+	if err := os.WriteFile(path, []byte(fakePWCLI), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := pwCLICommand
+	pwCLICommand = path
+
+	t.Cleanup(func() {
+		pwCLICommand = previous
+	})
+}
+
+func newTestPWSessionFinder(t *testing.T, ids ...uint32) *pwSessionFinder {
+	t.Helper()
+
+	logger := zap.NewNop().Sugar()
+	sf := &pwSessionFinder{
+		logger: logger,
+		nodes:  make(map[uint32]*pwNodeInfo),
+		cli:    &pwCLI{logger: logger},
+	}
+
+	for _, id := range ids {
+		sf.nodes[id] = &pwNodeInfo{Params: map[string][]json.RawMessage{"Props": {json.RawMessage(`{"mute":false}`)}}}
+	}
+
+	t.Cleanup(sf.cli.stop)
+
+	return sf
+}
+
+func TestPWSetPropsReportsFailure(t *testing.T) {
+	useFakePWCLI(t)
+	sf := newTestPWSessionFinder(t, 5, 99)
+
+	mute := true
+
+	if err := sf.setProps(5, pwProps{Mute: &mute}); err != nil {
+		t.Fatal(err)
+	}
+
+	if props := nodeProps(sf.nodes[5]); props.Mute == nil || !*props.Mute {
+		t.Error("expected the change to be remembered")
+	}
+
+	err := sf.setProps(99, pwProps{Mute: &mute})
+	if err == nil || !strings.Contains(err.Error(), "unknown global 99") {
+		t.Fatalf("expected pw-cli's error, got %v", err)
+	}
+
+	if props := nodeProps(sf.nodes[99]); props.Mute == nil || *props.Mute {
+		t.Error("expected a failed change to be forgotten")
+	}
+
+	// the failure doesn't stick to the next command
+	if err := sf.setProps(5, pwProps{Mute: &mute}); err != nil {
+		t.Errorf("expected the next change to work, got %v", err)
+	}
+}
+
+func TestPWCLIRestartsAfterExit(t *testing.T) {
+	useFakePWCLI(t)
+	cli := &pwCLI{logger: zap.NewNop().Sugar()}
+	t.Cleanup(cli.stop)
+
+	if err := cli.run("quit"); err == nil {
+		t.Error("expected pw-cli exiting to fail the command")
+	}
+
+	if err := cli.run("set-param 5 Props {}"); err != nil {
+		t.Errorf("expected a fresh pw-cli to take the next command, got %v", err)
+	}
+}
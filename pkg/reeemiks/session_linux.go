@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	"go.uber.org/zap"
 
@@ -19,8 +20,10 @@ type paSession struct {
 	baseSession
 
 	processName string
+	process     *processInfo
 
-	client *proto.Client
+	client *paConnection
+	meter  *paPeakMeter
 
 	sinkInputIndex    uint32
 	sinkInputChannels byte
@@ -29,30 +32,37 @@ type paSession struct {
 type masterSession struct {
 	baseSession
 
-	client *proto.Client
+	client *paConnection
+	meter  *paPeakMeter
 
+	// the default device can change underneath us, see rebind
 	streamIndex    uint32
 	streamChannels byte
+	streamLock     sync.Mutex
 	isOutput       bool
 }
 
 func newPASession(
 	logger *zap.SugaredLogger,
-	client *proto.Client,
+	client *paConnection,
+	meter *paPeakMeter,
 	sinkInputIndex uint32,
 	sinkInputChannels byte,
 	processName string,
+	namespace string,
 ) *paSession {
 
 	s := &paSession{
 		client:            client,
+		meter:             meter,
 		sinkInputIndex:    sinkInputIndex,
 		sinkInputChannels: sinkInputChannels,
 	}
 
+	// sessions from other servers than the first are told apart by their namespace, e.g. mediapc/spotify
 	s.processName = processName
-	s.name = processName
-	s.humanReadableDesc = processName
+	s.name = namespace + processName
+	s.humanReadableDesc = s.name
 
 	// use a self-identifying session name e.g. reeemiks.sessions.chrome
 	s.logger = logger.Named(s.Key())
@@ -63,14 +73,17 @@ func newPASession(
 
 func newMasterSession(
 	logger *zap.SugaredLogger,
-	client *proto.Client,
+	client *paConnection,
+	meter *paPeakMeter,
 	streamIndex uint32,
 	streamChannels byte,
 	isOutput bool,
+	namespace string,
 ) *masterSession {
 
 	s := &masterSession{
 		client:         client,
+		meter:          meter,
 		streamIndex:    streamIndex,
 		streamChannels: streamChannels,
 		isOutput:       isOutput,
@@ -86,8 +99,8 @@ func newMasterSession(
 
 	s.logger = logger.Named(key)
 	s.master = true
-	s.name = key
-	s.humanReadableDesc = key
+	s.name = namespace + key
+	s.humanReadableDesc = s.name
 
 	s.logger.Debugw(sessionCreationLogMessage, "session", s)
 
@@ -150,6 +163,99 @@ func (s *paSession) SetVolume(v float32) error {
 	return nil
 }
 
+func (s *paSession) GetMute() bool {
+	if strings.HasPrefix(s.processName, "reeemiks.device: ") {
+		request := &proto.GetSinkInfo{
+			SinkIndex: s.sinkInputIndex,
+		}
+		reply := &proto.GetSinkInfoReply{}
+
+		if err := s.client.Request(request, reply); err != nil {
+			s.logger.Warnw("Failed to get session mute state", "error", err)
+		}
+
+		return reply.Mute
+	} else {
+		request := &proto.GetSinkInputInfo{
+			SinkInputIndex: s.sinkInputIndex,
+		}
+		reply := &proto.GetSinkInputInfoReply{}
+
+		if err := s.client.Request(request, reply); err != nil {
+			s.logger.Warnw("Failed to get session mute state", "error", err)
+		}
+
+		return reply.Muted
+	}
+}
+
+func (s *paSession) SetMute(m bool) error {
+	var request proto.RequestArgs
+
+	if strings.HasPrefix(s.processName, "reeemiks.device: ") {
+		request = &proto.SetSinkMute{
+			SinkIndex: s.sinkInputIndex,
+			Mute:      m,
+		}
+	} else {
+		request = &proto.SetSinkInputMute{
+			SinkInputIndex: s.sinkInputIndex,
+			Mute:           m,
+		}
+	}
+
+	if err := s.client.Request(request, nil); err != nil {
+		s.logger.Warnw("Failed to set session mute state", "error", err)
+		return fmt.Errorf("adjust session mute state: %w", err)
+	}
+
+	s.logger.Debugw("Adjusting session mute state", "to", m)
+
+	return nil
+}
+
+// Active returns true unless the application has paused (corked) its stream. devices are always active
+func (s *paSession) Active() bool {
+	if strings.HasPrefix(s.processName, "reeemiks.device: ") {
+		return true
+	}
+
+	request := &proto.GetSinkInputInfo{
+		SinkInputIndex: s.sinkInputIndex,
+	}
+	reply := &proto.GetSinkInputInfoReply{}
+
+	if err := s.client.Request(request, reply); err != nil {
+		s.logger.Warnw("Failed to get session state", "error", err)
+		return false
+	}
+
+	return !reply.Corked
+}
+
+// Process returns the application playing the stream, if it's running on this machine
+func (s *paSession) Process() *processInfo {
+	return s.process
+}
+
+// Identity tells streams of the same app apart by their sink input, the key already names the server
+func (s *paSession) Identity() string {
+	return fmt.Sprintf("%s#%d", s.Key(), s.sinkInputIndex)
+}
+
+// Peak returns how loud the session's stream (or device) is playing right now
+func (s *paSession) Peak() float32 {
+	if strings.HasPrefix(s.processName, "reeemiks.device: ") {
+		return s.meter.peak(fmt.Sprintf("sink:%d", s.sinkInputIndex), func() (uint32, uint32, error) {
+			return sinkMonitor(s.client, s.sinkInputIndex)
+		})
+	}
+
+	return s.meter.peak(fmt.Sprintf("sink-input:%d", s.sinkInputIndex), func() (uint32, uint32, error) {
+		return sinkInputMonitor(s.client, s.sinkInputIndex)
+	})
+}
+
 func (s *paSession) Release() {
 	s.logger.Debug("Releasing audio session")
 }
@@ -161,9 +267,11 @@ func (s *paSession) String() string {
 func (s *masterSession) GetVolume() float32 {
 	var level float32
 
+	streamIndex, _ := s.stream()
+
 	if s.isOutput {
 		request := proto.GetSinkInfo{
-			SinkIndex: s.streamIndex,
+			SinkIndex: streamIndex,
 		}
 		reply := proto.GetSinkInfoReply{}
 
@@ -175,7 +283,7 @@ func (s *masterSession) GetVolume() float32 {
 		level = parseChannelVolumes(reply.ChannelVolumes)
 	} else {
 		request := proto.GetSourceInfo{
-			SourceIndex: s.streamIndex,
+			SourceIndex: streamIndex,
 		}
 		reply := proto.GetSourceInfoReply{}
 
@@ -193,16 +301,17 @@ func (s *masterSession) GetVolume() float32 {
 func (s *masterSession) SetVolume(v float32) error {
 	var request proto.RequestArgs
 
-	volumes := createChannelVolumes(s.streamChannels, v)
+	streamIndex, streamChannels := s.stream()
+	volumes := createChannelVolumes(streamChannels, v)
 
 	if s.isOutput {
 		request = &proto.SetSinkVolume{
-			SinkIndex:      s.streamIndex,
+			SinkIndex:      streamIndex,
 			ChannelVolumes: volumes,
 		}
 	} else {
 		request = &proto.SetSourceVolume{
-			SourceIndex:    s.streamIndex,
+			SourceIndex:    streamIndex,
 			ChannelVolumes: volumes,
 		}
 	}
@@ -220,6 +329,97 @@ func (s *masterSession) SetVolume(v float32) error {
 	return nil
 }
 
+func (s *masterSession) GetMute() bool {
+	streamIndex, _ := s.stream()
+
+	if s.isOutput {
+		request := proto.GetSinkInfo{
+			SinkIndex: streamIndex,
+		}
+		reply := proto.GetSinkInfoReply{}
+
+		if err := s.client.Request(&request, &reply); err != nil {
+			s.logger.Warnw("Failed to get session mute state", "error", err)
+			return false
+		}
+
+		return reply.Mute
+	}
+
+	request := proto.GetSourceInfo{
+		SourceIndex: streamIndex,
+	}
+	reply := proto.GetSourceInfoReply{}
+
+	if err := s.client.Request(&request, &reply); err != nil {
+		s.logger.Warnw("Failed to get session mute state", "error", err)
+		return false
+	}
+
+	return reply.Mute
+}
+
+func (s *masterSession) SetMute(m bool) error {
+	var request proto.RequestArgs
+
+	streamIndex, _ := s.stream()
+
+	if s.isOutput {
+		request = &proto.SetSinkMute{
+			SinkIndex: streamIndex,
+			Mute:      m,
+		}
+	} else {
+		request = &proto.SetSourceMute{
+			SourceIndex: streamIndex,
+			Mute:        m,
+		}
+	}
+
+	if err := s.client.Request(request, nil); err != nil {
+		s.logger.Warnw("Failed to set session mute state",
+			"error", err,
+			"mute", m)
+
+		return fmt.Errorf("adjust session mute state: %w", err)
+	}
+
+	s.logger.Debugw("Adjusting session mute state", "to", m)
+
+	return nil
+}
+
+// Peak returns how loud the default sink is playing, or the default source is picking up
+func (s *masterSession) Peak() float32 {
+	streamIndex, _ := s.stream()
+
+	if s.isOutput {
+		return s.meter.peak(fmt.Sprintf("sink:%d", streamIndex), func() (uint32, uint32, error) {
+			return sinkMonitor(s.client, streamIndex)
+		})
+	}
+
+	return s.meter.peak(fmt.Sprintf("source:%d", streamIndex), func() (uint32, uint32, error) {
+		return streamIndex, proto.Undefined, nil
+	})
+}
+
+// rebind points the session at another device, for when the server's default sink or source changes
+func (s *masterSession) rebind(streamIndex uint32, streamChannels byte) {
+	s.streamLock.Lock()
+	defer s.streamLock.Unlock()
+
+	s.streamIndex = streamIndex
+	s.streamChannels = streamChannels
+}
+
+func (s *masterSession) stream() (uint32, byte) {
+	s.streamLock.Lock()
+	defer s.streamLock.Unlock()
+
+	return s.streamIndex, s.streamChannels
+}
+
 func (s *masterSession) Release() {
 	s.logger.Debug("Releasing audio session")
 }
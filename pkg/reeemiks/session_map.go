@@ -1,15 +1,16 @@
 package reeemiks
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/Red-M/ReeeMiks/pkg/reeemiks/util"
-	"github.com/micmonay/keybd_event"
 	"github.com/thoas/go-funk"
 	"go.uber.org/zap"
 )
@@ -23,8 +24,43 @@ type sessionMap struct {
 
 	sessionFinder SessionFinder
 
+	// refreshes come from several goroutines, and each one has to stop ramps, clear, re-acquire and resume as a whole
 	lastSessionRefresh time.Time
-	unmappedSessions   []Session
+	refreshLock        sync.Mutex
+
+	// a refresh for the slider event loop to run, asked for by goroutines that shouldn't run one themselves
+	refreshRequests chan bool
+
+	// guarded by lock
+	unmappedSessions []Session
+
+	// set while learn mode is waiting for a slider to move
+	learnChannel chan int
+	learnLock    sync.Mutex
+
+	// the engaged slider layer, and soft takeover state for every slider:
+	// where it physically is, the level it last set its targets to and whether it's waiting to pick them up
+	activeLayer     string
+	sliderPositions map[int]float32
+	sliderLevels    map[int]float32
+	pickupArmed     map[int]bool
+	layerLock       sync.Mutex
+
+	// when each slider last moved relatively, to tell fast turns apart,
+	// and the mix inside each relative group by session identity. only touched from the slider event loop
+	lastRelativeMove map[int]time.Time
+	groupMixes       map[int]map[string]float32
+
+	// moves volumes gradually for sliders that ramp, and for fades
+	ramper *volumeRamper
+
+	// how far ducking has brought each slider's targets down, and changes to that for the slider event loop to apply
+	duckFactors   map[int]float32
+	duckLock      sync.Mutex
+	duckingEvents chan duckingChange
+
+	// presses the keys buttons are mapped to, created on the first press. only touched from the button event loop
+	keyboard *virtualKeyboard
 }
 
 const (
@@ -36,7 +72,7 @@ const (
 	// this prefix identifies those targets to ensure they don't contradict with another similarly-named process
 	specialTargetTransformPrefix = "deej."
 
-	// targets the currently active window (Windows-only, experimental)
+	// targets the currently active window and its child processes (experimental)
 	specialTargetCurrentWindow = "current"
 
 	// targets all currently unmapped sessions (experimental)
@@ -63,11 +99,20 @@ func newSessionMap(reeemiks *Reeemiks, logger *zap.SugaredLogger, sessionFinder
 	logger = logger.Named("sessions")
 
 	m := &sessionMap{
-		reeemiks:      reeemiks,
-		logger:        logger,
-		m:             make(map[string][]Session),
-		lock:          &sync.Mutex{},
-		sessionFinder: sessionFinder,
+		reeemiks:         reeemiks,
+		logger:           logger,
+		m:                make(map[string][]Session),
+		lock:             &sync.Mutex{},
+		sessionFinder:    sessionFinder,
+		sliderPositions:  make(map[int]float32),
+		sliderLevels:     make(map[int]float32),
+		pickupArmed:      make(map[int]bool),
+		lastRelativeMove: make(map[int]time.Time),
+		groupMixes:       make(map[int]map[string]float32),
+		ramper:           newVolumeRamper(logger),
+		duckFactors:      make(map[int]float32),
+		duckingEvents:    make(chan duckingChange),
+		refreshRequests:  make(chan bool, 1),
 	}
 
 	logger.Debug("Created session map instance")
@@ -76,19 +121,32 @@ func newSessionMap(reeemiks *Reeemiks, logger *zap.SugaredLogger, sessionFinder
 }
 
 func (m *sessionMap) initialize() error {
-	if err := m.getAndAddSessions(); err != nil {
+	m.refreshLock.Lock()
+	err := m.getAndAddSessions()
+	m.refreshLock.Unlock()
+
+	if err != nil {
 		m.logger.Warnw("Failed to get all sessions during session map initialization", "error", err)
 		return fmt.Errorf("get all sessions during init: %w", err)
 	}
 
+	m.warnUnsupportedSettings()
+
 	m.setupOnConfigReload()
 	m.setupOnSliderMove()
 	m.setupOnButtonEvent()
+	m.setupOnSessionsReset()
 
 	return nil
 }
 
 func (m *sessionMap) release() error {
+	if m.keyboard != nil {
+		if err := m.keyboard.close(); err != nil {
+			m.logger.Warnw("Failed to remove virtual keyboard", "error", err)
+		}
+	}
+
 	if err := m.sessionFinder.Release(); err != nil {
 		m.logger.Warnw("Failed to release session finder during session map release", "error", err)
 		return fmt.Errorf("release session finder during release: %w", err)
@@ -97,13 +155,58 @@ func (m *sessionMap) release() error {
 	return nil
 }
 
+// warnUnsupportedSettings logs every setting the audio backend in use can't act on, which would otherwise do nothing at all
+func (m *sessionMap) warnUnsupportedSettings() {
+	config := m.reeemiks.config.snapshot()
+
+	for _, setting := range m.unsupportedSettings(config) {
+		m.logger.Warnw("Setting not supported by the audio backend, ignoring it", "setting", setting, "backend", config.AudioBackend)
+	}
+}
+
+func (m *sessionMap) unsupportedSettings(config *configSnapshot) []string {
+	unsupported := []string{}
+
+	if _, ok := m.sessionFinder.(virtualDeviceManager); !ok && len(config.VirtualDevices) > 0 {
+		unsupported = append(unsupported, configKeyVirtualDevices)
+	}
+
+	if _, ok := m.sessionFinder.(sinkRouter); ok {
+		return unsupported
+	}
+
+	if len(config.DefaultSinks) > 0 {
+		unsupported = append(unsupported, configKeyDefaultSinks)
+	}
+
+	buttons := []string{}
+	for buttonID := range config.ButtonMapping {
+		buttons = append(buttons, buttonID)
+	}
+
+	sort.Strings(buttons)
+
+	for _, buttonID := range buttons {
+		mapping := config.ButtonMapping[buttonID]
+		if len(mapping) == 0 {
+			continue
+		}
+
+		if action := strings.ToLower(mapping[0]); action == buttonActionMove || action == buttonActionDefaultSink {
+			unsupported = append(unsupported, fmt.Sprintf("%s.%s (%s)", configKeyButtonMapping, buttonID, action))
+		}
+	}
+
+	return unsupported
+}
+
 // assumes the session map is clean!
-// only call on a new session map or as part of refreshSessions which calls reset
+// only call on a new session map or as part of refreshSessions which calls reset, with refreshLock held
 func (m *sessionMap) getAndAddSessions() error {
 
 	// mark that we're refreshing before anything else
 	m.lastSessionRefresh = time.Now()
-	m.unmappedSessions = nil
+	m.setUnmappedSessions(nil)
 
 	sessions, err := m.sessionFinder.GetAllSessions()
 	if err != nil {
@@ -111,16 +214,24 @@ func (m *sessionMap) getAndAddSessions() error {
 		return fmt.Errorf("get sessions from SessionFinder: %w", err)
 	}
 
+	unmappedSessions := []Session{}
+
 	for _, session := range sessions {
 		m.add(session)
 
 		if !m.sessionMapped(session) {
 			m.logger.Debugw("Tracking unmapped session", "session", session)
-			m.unmappedSessions = append(m.unmappedSessions, session)
+			unmappedSessions = append(unmappedSessions, session)
 		}
 	}
 
+	m.setUnmappedSessions(unmappedSessions)
+
 	m.logger.Infow("Got all audio sessions successfully", "sessionMap", m)
+	m.reeemiks.control.publish(controlEventSessionsRefresh, m.count())
+
+	// a fresh look at what's running is all automatic profile switching needs
+	m.reeemiks.autoProfiles.evaluate()
 
 	return nil
 }
@@ -133,6 +244,15 @@ func (m *sessionMap) setupOnConfigReload() {
 			select {
 			case <-configReloadedChannel:
 				m.logger.Info("Detected config reload, attempting to re-acquire all audio sessions")
+				m.forgetRemovedLayer()
+				m.warnUnsupportedSettings()
+
+				if manager, ok := m.sessionFinder.(virtualDeviceManager); ok {
+					if err := manager.syncVirtualDevices(); err != nil {
+						m.logger.Warnw("Failed to update virtual devices", "error", err)
+					}
+				}
+
 				m.refreshSessions(false)
 			}
 		}
@@ -147,6 +267,10 @@ func (m *sessionMap) setupOnSliderMove() {
 			select {
 			case event := <-sliderEventsChannel:
 				m.handleSliderMoveEvent(event)
+			case change := <-m.duckingEvents:
+				m.applyDucking(change)
+			case <-m.refreshRequests:
+				m.refreshSessions(false)
 			}
 		}
 	}()
@@ -165,14 +289,36 @@ func (m *sessionMap) setupOnButtonEvent() {
 	}()
 }
 
+func (m *sessionMap) setupOnSessionsReset() {
+	notifier, ok := m.sessionFinder.(sessionResetNotifier)
+	if !ok {
+		return
+	}
+
+	go func() {
+		for range notifier.sessionsReset() {
+			m.logger.Info("Audio sessions were reset, re-acquiring all of them")
+
+			// every session we hold is stale, so this can't wait out the usual refresh interval
+			m.refreshSessions(true)
+		}
+	}()
+}
+
 // performance: explain why force == true at every such use to avoid unintended forced refresh spams
 func (m *sessionMap) refreshSessions(force bool) {
+	m.refreshLock.Lock()
+	defer m.refreshLock.Unlock()
 
-	// make sure enough time passed since the last refresh, unless force is true in which case always clear
+	// make sure enough time passed since the last refresh, unless force is true in which case always clear.
+	// a caller that waited on another refresh usually finds it's no longer needed
 	if !force && m.lastSessionRefresh.Add(minTimeBetweenSessionRefreshes).After(time.Now()) {
 		return
 	}
 
+	// ramps move the sessions about to be released, so they stop first and carry on with the new ones
+	ramps := m.ramper.stop()
+
 	// clear and release sessions first
 	m.clear()
 
@@ -181,6 +327,32 @@ func (m *sessionMap) refreshSessions(force bool) {
 	} else {
 		m.logger.Debug("Re-acquired sessions successfully")
 	}
+
+	m.ramper.resume(ramps, m.get)
+}
+
+// requestRefresh has the slider event loop refresh sessions, with the usual cooldown. it never blocks,
+// a request that's already waiting covers this one too
+func (m *sessionMap) requestRefresh() {
+	select {
+	case m.refreshRequests <- true:
+	default:
+	}
+}
+
+// stale returns true if the sessions were last acquired longer ago than maxAge
+func (m *sessionMap) stale(maxAge time.Duration) bool {
+	m.refreshLock.Lock()
+	defer m.refreshLock.Unlock()
+
+	return m.lastSessionRefresh.Add(maxAge).Before(time.Now())
+}
+
+func (m *sessionMap) setUnmappedSessions(sessions []Session) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.unmappedSessions = sessions
 }
 
 // returns true if a session is not currently mapped to any slider, false otherwise
@@ -188,9 +360,11 @@ func (m *sessionMap) refreshSessions(force bool) {
 // even when absent from the config. this makes sense for every current feature that uses "unmapped sessions"
 func (m *sessionMap) sessionMapped(session Session) bool {
 
-	// count master/system/mic as mapped
-	if funk.ContainsString([]string{masterSessionName, systemSessionName, inputSessionName}, session.Key()) {
-		return true
+	// count master/system/mic as mapped, including those of other PulseAudio servers (mediapc/master)
+	for _, name := range []string{masterSessionName, systemSessionName, inputSessionName} {
+		if session.Key() == name || strings.HasSuffix(session.Key(), "/"+name) {
+			return true
+		}
 	}
 
 	// count device sessions as mapped
@@ -200,10 +374,19 @@ func (m *sessionMap) sessionMapped(session Session) bool {
 
 	matchFound := false
 
-	// look through the actual mappings
-	m.reeemiks.config.SliderMapping.iterate(func(sliderIdx int, targets []string) {
+	checkMapping := func(sliderIdx int, targets []string) {
 		for _, target := range targets {
 
+			// process targets pick sessions by where they come from, not by key
+			if prefix, pattern, ok := parseProcessTarget(strings.ToLower(target)); ok {
+				if sessionProcess(session).matches(prefix, pattern) {
+					matchFound = true
+					return
+				}
+
+				continue
+			}
+
 			// ignore special transforms
 			if m.targetHasSpecialTransform(target) {
 				continue
@@ -217,86 +400,156 @@ func (m *sessionMap) sessionMapped(session Session) bool {
 				return
 			}
 		}
-	})
+	}
+
+	// look through the actual mappings, including layers - they're only a button press away
+	config := m.reeemiks.config.snapshot()
+
+	config.SliderMapping.iterate(checkMapping)
+	for _, layerMapping := range config.Layers {
+		layerMapping.iterate(checkMapping)
+	}
 
 	return matchFound
 }
 
 func (m *sessionMap) getSliderVolume(slider int, targets []string) float32 {
-	var average float32
-	var count int
+	return m.sliderLevel(slider, m.reeemiks.config.snapshot().sliderSettings(slider), m.resolveSessions(targets))
+}
 
-	for _, target := range targets {
-		resolvedTargets := m.resolveTarget(target)
+// captureNextSliderMove hands the next slider move to the caller instead of applying it
+func (m *sessionMap) captureNextSliderMove(timeout time.Duration) (int, error) {
+	m.learnLock.Lock()
+	if m.learnChannel != nil {
+		m.learnLock.Unlock()
+		return 0, errors.New("already waiting for a slider to move")
+	}
 
-		for _, resolvedTarget := range resolvedTargets {
-			sessions, ok := m.get(resolvedTarget)
+	ch := make(chan int, 1)
+	m.learnChannel = ch
+	m.learnLock.Unlock()
 
-			if !ok {
-				continue
-			}
+	defer func() {
+		m.learnLock.Lock()
+		m.learnChannel = nil
+		m.learnLock.Unlock()
+	}()
 
-			for _, session := range sessions {
-				count++
-				average += session.GetVolume()
-			}
-		}
+	select {
+	case sliderIdx := <-ch:
+		return sliderIdx, nil
+	case <-time.After(timeout):
+		return 0, errLearnTimeout
 	}
+}
 
-	if count > 0 {
-		average /= float32(count)
-		return average
-	} else {
-		return 0
+// delivers the event to learn mode if it's waiting, returning true if it did
+func (m *sessionMap) deliverToLearnMode(event SliderMoveEvent) bool {
+	m.learnLock.Lock()
+	defer m.learnLock.Unlock()
+
+	if m.learnChannel == nil {
+		return false
+	}
+
+	select {
+	case m.learnChannel <- event.SliderID:
+	default:
 	}
+
+	return true
 }
 
 func (m *sessionMap) handleSliderMoveEvent(event SliderMoveEvent) {
 
+	// learn mode gets first dibs, and a slider that's being learned shouldn't move anything yet
+	if m.deliverToLearnMode(event) {
+		return
+	}
+
 	// first of all, ensure our session map isn't moldy
-	if m.lastSessionRefresh.Add(maxTimeBetweenSessionRefreshes).Before(time.Now()) {
+	if m.stale(maxTimeBetweenSessionRefreshes) {
 		m.logger.Debug("Stale session map detected on slider move, refreshing")
 		m.refreshSessions(true)
 	}
 
-	// get the targets mapped to this slider from the config
-	targets, ok := m.reeemiks.config.SliderMapping.get(event.SliderID)
+	// the whole move is handled with the same config, even if it gets reloaded halfway through
+	config := m.reeemiks.config.snapshot()
+	settings := config.sliderSettings(event.SliderID)
+
+	// get the targets mapped to this slider from the config, taking the engaged layer into account
+	targets, ok := m.sliderMappingOf(config).get(event.SliderID)
 
 	// if slider not found in config, silently ignore
 	if !ok {
 		return
 	}
 
+	// every session usually goes to wherever the slider is
+	volumeFor := func(session Session) float32 {
+		return event.PercentValue
+	}
+
+	// relative moves only say which way they turned, work out where that takes the targets
+	if event.Relative {
+		step := m.relativeStep(event, settings)
+		event.PercentValue = clampVolume(m.sliderLevel(event.SliderID, settings, m.resolveSessions(targets)) + step)
+
+		// rather than bringing them all to their average, sessions can each be stepped from their own volume
+		if settings.StepEachSession {
+			volumeFor = func(session Session) float32 {
+				return clampVolume(m.sessionLevel(event.SliderID, session) + step)
+			}
+		}
+	}
+
+	// after a layer switch or in pickup mode, wait for the slider to catch up with its targets
+	if !m.pickedUp(event, targets, settings) {
+		return
+	}
+
+	m.applySliderVolume(event, targets, settings, volumeFor, settings.Ramp)
+}
+
+// applySliderVolume sets every session of a slider's targets to where the slider puts it, ramping there over the
+// given duration. volumeFor gives the volume for each session, before ducking has brought it down
+func (m *sessionMap) applySliderVolume(
+	event SliderMoveEvent,
+	targets []string,
+	settings sliderSettings,
+	volumeFor func(Session) float32,
+	ramp time.Duration,
+) {
+	duckFactor := m.duckFactor(event.SliderID)
+
+	// relative groups keep their mix, whichever way the slider moved
+	if settings.GroupMode == groupModeRelative {
+		volumeFor = m.groupVolumeFor(event.SliderID, m.resolveSessions(targets), event.PercentValue)
+	}
+
 	targetFound := false
 	adjustmentFailed := false
 
 	// for each possible target for this slider...
 	for _, target := range targets {
 
-		// resolve the target name by cleaning it up and applying any special transformations.
-		// depending on the transformation applied, this can result in more than one target name
-		resolvedTargets := m.resolveTarget(target)
+		// resolve the target by cleaning it up and applying any special transformations.
+		// depending on the transformation applied, this can match sessions under more than one key
+		sessions := m.resolveTargetSessions(target)
 
-		// for each resolved target...
-		for _, resolvedTarget := range resolvedTargets {
-
-			// check the map for matching sessions
-			sessions, ok := m.get(resolvedTarget)
-
-			// no sessions matching this target - move on
-			if !ok {
-				continue
-			}
+		// no sessions matching this target - move on
+		if len(sessions) == 0 {
+			continue
+		}
 
-			targetFound = true
+		targetFound = true
 
-			// iterate all matching sessions and adjust the volume of each one
-			for _, session := range sessions {
-				if session.GetVolume() != event.PercentValue {
-					if err := session.SetVolume(event.PercentValue); err != nil {
-						m.logger.Warnw("Failed to set target session volume", "error", err)
-						adjustmentFailed = true
-					}
+		// iterate all matching sessions and adjust the volume of each one
+		for _, session := range sessions {
+			if volume := volumeFor(session) * duckFactor; m.ramper.targetVolume(session) != volume {
+				if err := m.ramper.setVolume(session, volume, ramp); err != nil {
+					m.logger.Warnw("Failed to set target session volume", "error", err)
+					adjustmentFailed = true
 				}
 			}
 		}
@@ -317,18 +570,40 @@ func (m *sessionMap) handleSliderMoveEvent(event SliderMoveEvent) {
 }
 
 func (m *sessionMap) handleButtonEvent(event ButtonEvent) {
-	if event.Value == 0 {
-		kb, err := keybd_event.NewKeyBonding()
-
-		i, err := strconv.Atoi(m.reeemiks.config.ButtonMapping[strconv.Itoa(event.ButtonID)][0])
-		kb.SetKeys(i)
-		m.logger.Debugw("Triggering button", "keycodeint", i)
-		// m.logger.Debug(0xAD + 0xFFF)
-		err = kb.Launching()
-		if err != nil {
-			panic(err)
+
+	// buttons pull their pin low while pressed
+	pressed := event.Value == 0
+
+	mapping, ok := m.reeemiks.config.snapshot().ButtonMapping[strconv.Itoa(event.ButtonID)]
+	if !ok || len(mapping) == 0 {
+		m.logger.Debugw("Ignoring unmapped button", "button", event.ButtonID)
+		return
+	}
+
+	// anything that isn't a key code or key names names an action instead
+	chord, err := parseKeyChord(mapping[0])
+	if _, isAction := buttonActionArgs[strings.ToLower(mapping[0])]; isAction || err != nil {
+		m.runButtonAction(event.ButtonID, mapping[0], mapping[1:], pressed)
+		return
+	}
+
+	// keys are pressed and released in one go, as soon as the button goes down
+	if !pressed {
+		return
+	}
+
+	if m.keyboard == nil {
+		if m.keyboard, err = newVirtualKeyboard(m.logger); err != nil {
+			m.logger.Warnw("Failed to create virtual keyboard", "button", event.ButtonID, "error", err)
+			return
 		}
 	}
+
+	m.logger.Debugw("Triggering button", "keys", mapping[0], "keycodes", chord.codes)
+
+	if err := m.keyboard.tap(chord); err != nil {
+		m.logger.Warnw("Failed to trigger button key press", "button", event.ButtonID, "keys", mapping[0], "error", err)
+	}
 }
 
 func (m *sessionMap) targetHasSpecialTransform(target string) bool {
@@ -372,6 +647,9 @@ func (m *sessionMap) applyTargetTransform(specialTargetName string) []string {
 
 	// get currently unmapped sessions
 	case specialTargetAllUnmapped:
+		m.lock.Lock()
+		defer m.lock.Unlock()
+
 		targetKeys := make([]string, len(m.unmappedSessions))
 		for sessionIdx, session := range m.unmappedSessions {
 			targetKeys[sessionIdx] = session.Key()
@@ -383,6 +661,52 @@ func (m *sessionMap) applyTargetTransform(specialTargetName string) []string {
 	return nil
 }
 
+// resolveSessions returns every session currently matched by the given slider targets
+func (m *sessionMap) resolveSessions(targets []string) []Session {
+	result := []Session{}
+
+	for _, target := range targets {
+		result = append(result, m.resolveTargetSessions(target)...)
+	}
+
+	return result
+}
+
+// resolveTargetSessions returns the sessions a single target matches: by key, or by their process for process targets
+func (m *sessionMap) resolveTargetSessions(target string) []Session {
+	result := []Session{}
+
+	if prefix, pattern, ok := parseProcessTarget(strings.ToLower(target)); ok {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+
+		for _, sessions := range m.m {
+			for _, session := range sessions {
+				if sessionProcess(session).matches(prefix, pattern) {
+					result = append(result, session)
+				}
+			}
+		}
+
+		return result
+	}
+
+	if strings.ToLower(target) == specialTargetTransformPrefix+specialTargetCurrentWindow {
+		return m.currentWindowSessions()
+	}
+
+	for _, resolvedTarget := range m.resolveTarget(target) {
+		sessions, ok := m.get(resolvedTarget)
+		if !ok {
+			continue
+		}
+
+		result = append(result, sessions...)
+	}
+
+	return result
+}
+
 func (m *sessionMap) add(value Session) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -405,6 +729,101 @@ func (m *sessionMap) get(key string) ([]Session, bool) {
 	return value, ok
 }
 
+// currentWindowSessions returns the sessions of the focused window's process and the processes it started.
+// sessions that know their process are picked by PID, so only the instance that has focus follows the slider,
+// and streams named after something other than their binary are caught too. the rest go by name
+func (m *sessionMap) currentWindowSessions() []Session {
+	names := m.applyTargetTransform(specialTargetCurrentWindow)
+
+	pids, err := util.GetCurrentWindowProcessIDs()
+	if err != nil {
+		result := []Session{}
+
+		for _, name := range names {
+			if sessions, ok := m.get(name); ok {
+				result = append(result, sessions...)
+			}
+		}
+
+		return result
+	}
+
+	wantedNames := map[string]bool{}
+	for _, name := range names {
+		wantedNames[name] = true
+	}
+
+	result := m.sessionsByProcessID(pids)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for key, sessions := range m.m {
+		if !wantedNames[key] {
+			continue
+		}
+
+		for _, session := range sessions {
+			if sessionProcess(session) == nil {
+				result = append(result, session)
+			}
+		}
+	}
+
+	return result
+}
+
+// sessionsByProcessID returns every session played by one of the given processes
+func (m *sessionMap) sessionsByProcessID(pids []int) []Session {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	wanted := map[int]bool{}
+	for _, pid := range pids {
+		wanted[pid] = true
+	}
+
+	result := []Session{}
+
+	for _, sessions := range m.m {
+		for _, session := range sessions {
+			if process := sessionProcess(session); process != nil && wanted[process.pid] {
+				result = append(result, session)
+			}
+		}
+	}
+
+	return result
+}
+
+// keys returns all session keys currently in the map, sorted
+func (m *sessionMap) keys() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	keys := make([]string, 0, len(m.m))
+	for key := range m.m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (m *sessionMap) count() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	sessionCount := 0
+
+	for _, value := range m.m {
+		sessionCount += len(value)
+	}
+
+	return sessionCount
+}
+
 func (m *sessionMap) clear() {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -0,0 +1,149 @@
+package reeemiks
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeProcessSession is a fakeSession that knows the process playing it
+type fakeProcessSession struct {
+	fakeSession
+	process *processInfo
+}
+
+func (s *fakeProcessSession) Process() *processInfo {
+	return s.process
+}
+
+func TestSessionsByProcessID(t *testing.T) {
+	m, _ := newSessionMap(nil, zap.NewNop().Sugar(), nil)
+
+	focused := &fakeProcessSession{fakeSession{key: "firefox", identity: "firefox#1"}, &processInfo{pid: 100}}
+	other := &fakeProcessSession{fakeSession{key: "firefox", identity: "firefox#2"}, &processInfo{pid: 200}}
+	helper := &fakeProcessSession{fakeSession{key: "youtube", identity: "youtube#3"}, &processInfo{pid: 101}}
+	unknown := &fakeSession{key: "firefox", identity: "firefox#4"}
+
+	for _, session := range []Session{focused, other, helper, unknown} {
+		m.add(session)
+	}
+
+	identities := []string{}
+	for _, session := range m.sessionsByProcessID([]int{100, 101}) {
+		identities = append(identities, sessionIdentity(session))
+	}
+
+	sort.Strings(identities)
+
+	if len(identities) != 2 || identities[0] != "firefox#1" || identities[1] != "youtube#3" {
+		t.Errorf("expected only the focused instance and its helper, got %v", identities)
+	}
+
+	pidTarget := m.resolveTargetSessions("pid:200")
+	if len(pidTarget) != 1 || pidTarget[0] != other {
+		t.Errorf("expected pid:200 to pick the other instance only, got %v", pidTarget)
+	}
+}
+
+type nopNotifier struct{}
+
+func (nopNotifier) Notify(title string, message string) {}
+
+// slowSessionFinder hands out the same two sessions every time, taking its time about it
+type slowSessionFinder struct{}
+
+func (slowSessionFinder) GetAllSessions() ([]Session, error) {
+	time.Sleep(10 * time.Millisecond)
+
+	return []Session{
+		&fakeSession{key: "spotify", identity: "spotify#1"},
+		&fakeSession{key: "discord", identity: "discord#2"},
+	}, nil
+}
+
+func (slowSessionFinder) Release() error { return nil }
+
+func newTestReeemiks(t *testing.T, sessionFinder SessionFinder) *Reeemiks {
+	t.Helper()
+
+	logger := zap.NewNop().Sugar()
+
+	config, err := NewConfig(logger, nopNotifier{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Reeemiks{logger: logger, notifier: nopNotifier{}, config: config}
+	d.control = newControlServer(d, logger)
+	d.autoProfiles = newAutoProfiler(d, logger)
+
+	if d.sessions, err = newSessionMap(d, logger, sessionFinder); err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func TestRefreshSessionsConcurrently(t *testing.T) {
+	m := newTestReeemiks(t, slowSessionFinder{}).sessions
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.refreshSessions(true)
+		}()
+	}
+
+	wg.Wait()
+
+	if count := m.count(); count != 2 {
+		t.Errorf("expected overlapping refreshes to leave 2 sessions, got %d", count)
+	}
+
+	if m.stale(time.Second) {
+		t.Error("expected the sessions to be fresh after refreshing")
+	}
+}
+
+func TestRequestRefresh(t *testing.T) {
+	m := newTestReeemiks(t, slowSessionFinder{}).sessions
+
+	// asking twice before anyone gets to it is one refresh, and never blocks
+	m.requestRefresh()
+	m.requestRefresh()
+
+	if len(m.refreshRequests) != 1 {
+		t.Errorf("expected a single waiting refresh request, got %d", len(m.refreshRequests))
+	}
+}
+
+func TestUnsupportedSettings(t *testing.T) {
+	m := newTestReeemiks(t, slowSessionFinder{}).sessions
+
+	if unsupported := m.unsupportedSettings(&configSnapshot{}); len(unsupported) != 0 {
+		t.Errorf("expected nothing unsupported without any settings, got %v", unsupported)
+	}
+
+	config := &configSnapshot{
+		VirtualDevices: []virtualDevice{{}},
+		DefaultSinks:   []string{"speakers"},
+		ButtonMapping: map[string][]string{
+			"0": {"KEY_1"},
+			"1": {"move", "spotify", "next"},
+			"2": {"Default_Sink", "next"},
+			"3": {"profile", "next"},
+		},
+	}
+
+	expected := []string{"virtual_devices", "default_sinks", "button_mapping.1 (move)", "button_mapping.2 (default_sink)"}
+
+	if unsupported := m.unsupportedSettings(config); strings.Join(unsupported, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, unsupported)
+	}
+}
@@ -0,0 +1,164 @@
+package reeemiks
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"go.uber.org/zap"
+)
+
+// pwSession is a PipeWire node. master and mic don't stick to a node, they follow the default sink and source
+type pwSession struct {
+	baseSession
+
+	finder *pwSessionFinder
+
+	nodeID     uint32
+	defaultKey string
+	process    *processInfo
+}
+
+// PipeWire doesn't know the channel count of a node that never reported its volumes, stereo is the safe bet
+const pwDefaultChannels = 2
+
+var errNoSuchNode = errors.New("no such PipeWire node")
+
+func newPWSession(
+	logger *zap.SugaredLogger,
+	finder *pwSessionFinder,
+	nodeID uint32,
+	defaultKey string,
+	name string,
+) *pwSession {
+
+	s := &pwSession{
+		finder:     finder,
+		nodeID:     nodeID,
+		defaultKey: defaultKey,
+	}
+
+	s.master = defaultKey != ""
+	s.name = name
+	s.humanReadableDesc = name
+
+	s.logger = logger.Named(s.Key())
+	s.logger.Debugw(sessionCreationLogMessage, "session", s)
+
+	return s
+}
+
+// GetVolume returns the node's volume on the same cubic scale pulse and wpctl use, rather than PipeWire's linear one
+func (s *pwSession) GetVolume() float32 {
+	_, node, ok := s.finder.node(s.nodeID, s.defaultKey)
+	if !ok {
+		s.logger.Warnw("Failed to get session volume", "error", errNoSuchNode)
+		return 0
+	}
+
+	props := nodeProps(node)
+
+	if len(props.ChannelVolumes) == 0 {
+		if props.Volume == nil {
+			return 0
+		}
+
+		return float32(math.Cbrt(*props.Volume))
+	}
+
+	var level float64
+	for _, volume := range props.ChannelVolumes {
+		level += volume
+	}
+
+	return float32(math.Cbrt(level / float64(len(props.ChannelVolumes))))
+}
+
+func (s *pwSession) SetVolume(v float32) error {
+	id, node, ok := s.finder.node(s.nodeID, s.defaultKey)
+	if !ok {
+		return fmt.Errorf("adjust session volume: %w", errNoSuchNode)
+	}
+
+	channels := len(nodeProps(node).ChannelVolumes)
+	if channels == 0 {
+		channels = pwDefaultChannels
+	}
+
+	linear := math.Pow(float64(v), 3)
+	volumes := make([]float64, channels)
+
+	for i := range volumes {
+		volumes[i] = linear
+	}
+
+	if err := s.finder.setProps(id, pwProps{ChannelVolumes: volumes}); err != nil {
+		s.logger.Warnw("Failed to set session volume", "error", err, "volume", v)
+		return fmt.Errorf("adjust session volume: %w", err)
+	}
+
+	s.logger.Debugw("Adjusting session volume", "to", fmt.Sprintf("%.2f", v))
+
+	return nil
+}
+
+func (s *pwSession) GetMute() bool {
+	_, node, ok := s.finder.node(s.nodeID, s.defaultKey)
+	if !ok {
+		s.logger.Warnw("Failed to get session mute state", "error", errNoSuchNode)
+		return false
+	}
+
+	props := nodeProps(node)
+
+	return props.Mute != nil && *props.Mute
+}
+
+func (s *pwSession) SetMute(m bool) error {
+	id, _, ok := s.finder.node(s.nodeID, s.defaultKey)
+	if !ok {
+		return fmt.Errorf("adjust session mute state: %w", errNoSuchNode)
+	}
+
+	if err := s.finder.setProps(id, pwProps{Mute: &m}); err != nil {
+		s.logger.Warnw("Failed to set session mute state", "error", err, "mute", m)
+		return fmt.Errorf("adjust session mute state: %w", err)
+	}
+
+	s.logger.Debugw("Adjusting session mute state", "to", m)
+
+	return nil
+}
+
+// Process returns the application behind the node, if it's running on this machine
+func (s *pwSession) Process() *processInfo {
+	return s.process
+}
+
+// Identity tells nodes sharing a key apart by their ID
+func (s *pwSession) Identity() string {
+	return fmt.Sprintf("%s#%d", s.Key(), s.nodeID)
+}
+
+// Active returns true while the node is running. devices (and master and mic) are always active
+func (s *pwSession) Active() bool {
+	_, node, ok := s.finder.node(s.nodeID, s.defaultKey)
+	if !ok {
+		return false
+	}
+
+	mediaClass := pwProp(node.Props, "media.class")
+	if s.master || mediaClass == pwMediaClassSink || mediaClass == pwMediaClassDuplex {
+		return true
+	}
+
+	return node.State == "running"
+}
+
+func (s *pwSession) Release() {
+	s.logger.Debug("Releasing audio session")
+}
+
+func (s *pwSession) String() string {
+	return fmt.Sprintf(sessionStringFormat, s.humanReadableDesc, s.GetVolume())
+}
@@ -3,6 +3,7 @@ package reeemiks
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/thoas/go-funk"
@@ -20,7 +21,11 @@ func newSliderMap() *sliderMap {
 	}
 }
 
-func sliderMapFromConfigs(userMapping map[string][]string, internalMapping map[string][]string) *sliderMap {
+func sliderMapFromConfigs(
+	userMapping map[string][]string,
+	internalMapping map[string][]string,
+	removedTargets map[string][]string,
+) *sliderMap {
 	resultMap := newSliderMap()
 
 	// copy targets from user config, ignoring empty values
@@ -49,9 +54,46 @@ func sliderMapFromConfigs(userMapping map[string][]string, internalMapping map[s
 		resultMap.set(sliderIdx, existingTargets)
 	}
 
+	// drop targets that were removed at runtime, even if the user config still has them
+	for sliderIdxString, targets := range removedTargets {
+		sliderIdx, _ := strconv.Atoi(sliderIdxString)
+
+		existingTargets, ok := resultMap.get(sliderIdx)
+		if !ok {
+			continue
+		}
+
+		existingTargets = funk.FilterString(existingTargets, func(s string) bool {
+			return !containsTarget(targets, s)
+		})
+
+		if len(existingTargets) == 0 {
+			resultMap.remove(sliderIdx)
+		} else {
+			resultMap.set(sliderIdx, existingTargets)
+		}
+	}
+
 	return resultMap
 }
 
+// targets are matched case-insensitively everywhere else, so do the same when comparing them
+func containsTarget(targets []string, target string) bool {
+	for _, existing := range targets {
+		if strings.EqualFold(existing, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func removeTarget(targets []string, target string) []string {
+	return funk.FilterString(targets, func(s string) bool {
+		return !strings.EqualFold(s, target)
+	})
+}
+
 func (m *sliderMap) iterate(f func(int, []string)) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -61,6 +103,19 @@ func (m *sliderMap) iterate(f func(int, []string)) {
 	}
 }
 
+// entries returns a copy of every slider's targets, for callers that do slow work with them and shouldn't hold the lock
+func (m *sliderMap) entries() map[int][]string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	result := make(map[int][]string, len(m.m))
+	for key, value := range m.m {
+		result[key] = value
+	}
+
+	return result
+}
+
 func (m *sliderMap) get(key int) ([]string, bool) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -76,6 +131,28 @@ func (m *sliderMap) set(key int, value []string) {
 	m.m[key] = value
 }
 
+func (m *sliderMap) remove(key int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.m, key)
+}
+
+// overlay returns a new map with every slider that top maps taken from top, and the rest from m
+func (m *sliderMap) overlay(top *sliderMap) *sliderMap {
+	resultMap := newSliderMap()
+
+	m.iterate(func(sliderIdx int, targets []string) {
+		resultMap.set(sliderIdx, targets)
+	})
+
+	top.iterate(func(sliderIdx int, targets []string) {
+		resultMap.set(sliderIdx, targets)
+	})
+
+	return resultMap
+}
+
 func (m *sliderMap) String() string {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -0,0 +1,105 @@
+package reeemiks
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// sliderSettings changes how a single slider behaves, on top of what it's mapped to
+type sliderSettings struct {
+
+	// ignore the slider until it reaches its targets' level, whenever it doesn't match it
+	Pickup bool
+
+	// how close counts as reaching it, from 0 to 1
+	PickupTolerance float64
+
+	// how far a single encoder or HID step moves the slider's targets, from 0 to 1
+	Step float64
+
+	// the most a fast turn can multiply the step by, 1 turns acceleration off
+	Acceleration float64
+
+	// step each session from its own volume, instead of moving them all from their average
+	StepEachSession bool
+
+	// how the slider moves several sessions at once, absolute or relative
+	GroupMode string
+
+	// how long its sessions take to reach a new volume, zero sets them right away
+	Ramp time.Duration
+}
+
+// how slider_settings entries look in the user config, tolerances are given in percent
+type sliderSettingsEntry struct {
+	Pickup          bool     `mapstructure:"pickup"`
+	PickupTolerance *float64 `mapstructure:"pickup_tolerance"`
+	Step            *float64 `mapstructure:"step"`
+	Acceleration    *float64 `mapstructure:"acceleration"`
+	StepEachSession bool     `mapstructure:"step_each_session"`
+	GroupMode       string   `mapstructure:"group_mode"`
+	Ramp            float64  `mapstructure:"ramp"` // in milliseconds
+}
+
+const (
+	configKeySliderSettings = "slider_settings"
+
+	// how far encoders and HID up/down move per step, unless a slider says otherwise
+	defaultStep = 0.05
+)
+
+var defaultSliderSettings = sliderSettings{
+	PickupTolerance: defaultPickupTolerance,
+	Step:            defaultStep,
+	Acceleration:    1,
+	GroupMode:       groupModeAbsolute,
+}
+
+// parseSliderSettings turns slider_settings entries into settings by slider index, filling in defaults for anything left out
+func parseSliderSettings(entries map[string]sliderSettingsEntry) (map[int]sliderSettings, error) {
+	result := make(map[int]sliderSettings, len(entries))
+
+	for sliderIdxString, entry := range entries {
+		sliderIdx, err := strconv.Atoi(sliderIdxString)
+		if err != nil {
+			return nil, fmt.Errorf("parse slider index %q: %w", sliderIdxString, err)
+		}
+
+		settings := defaultSliderSettings
+		settings.Pickup = entry.Pickup
+
+		if entry.PickupTolerance != nil {
+			settings.PickupTolerance = *entry.PickupTolerance / 100
+		}
+
+		if entry.Step != nil {
+			settings.Step = *entry.Step / 100
+		}
+
+		if entry.Acceleration != nil {
+			settings.Acceleration = *entry.Acceleration
+		}
+
+		settings.StepEachSession = entry.StepEachSession
+
+		if entry.GroupMode != "" {
+			settings.GroupMode = entry.GroupMode
+		}
+
+		settings.Ramp = time.Duration(entry.Ramp * float64(time.Millisecond))
+
+		result[sliderIdx] = settings
+	}
+
+	return result, nil
+}
+
+// sliderSettings returns the settings for a slider, or the defaults if it has none
+func (s *configSnapshot) sliderSettings(sliderIdx int) sliderSettings {
+	if settings, ok := s.SliderSettings[sliderIdx]; ok {
+		return settings
+	}
+
+	return defaultSliderSettings
+}
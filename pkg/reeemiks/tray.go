@@ -1,12 +1,29 @@
 package reeemiks
 
 import (
+	"errors"
+	"fmt"
+
 	"fyne.io/systray"
 
 	"github.com/Red-M/ReeeMiks/pkg/reeemiks/icon"
 	"github.com/Red-M/ReeeMiks/pkg/reeemiks/util"
 )
 
+const (
+
+	// the most audio sessions learn mode can offer from the tray
+	trayBindMenuSize = 40
+
+	// the most profiles the tray can list
+	trayProfileMenuSize = 20
+)
+
+type trayLearnResult struct {
+	slider     int
+	candidates []string
+}
+
 func (d *Reeemiks) initializeTray(onDone func()) {
 	logger := d.logger.Named("tray")
 
@@ -23,6 +40,25 @@ func (d *Reeemiks) initializeTray(onDone func()) {
 		refreshSessions := systray.AddMenuItem("Re-scan audio sessions", "Manually refresh audio sessions if something's stuck")
 		refreshSessions.SetIcon(icon.RefreshSessions)
 
+		learnMapping := systray.AddMenuItem("Learn slider mapping", "Move a slider, then pick what it should control")
+
+		// learn mode fills this in once it knows which slider was moved.
+		// the tray can't remove items, so keep a fixed pool around and only show what's needed
+		bindMenu := systray.AddMenuItem("Bind slider to...", "Pick what the learned slider should control")
+		bindMenu.Hide()
+		bindItems, bindClicks := addTrayItemPool(bindMenu, trayBindMenuSize, false)
+
+		learnResults := make(chan trayLearnResult)
+		var learned trayLearnResult
+
+		profilesMenu := systray.AddMenuItem("Profile", "Switch between mapping profiles")
+		autoProfile := profilesMenu.AddSubMenuItemCheckbox("Automatic", "Follow the automatic profile rules", false)
+		profileItems, profileClicks := addTrayItemPool(profilesMenu, trayProfileMenuSize, true)
+		updateTrayProfiles(d, profilesMenu, autoProfile, profileItems)
+
+		// profiles can come and go with config reloads, and get switched from elsewhere
+		configReloadedChannel := d.config.SubscribeToChanges()
+
 		if d.version != "" {
 			systray.AddSeparator()
 			versionInfo := systray.AddMenuItem(d.version, "")
@@ -63,6 +99,91 @@ func (d *Reeemiks) initializeTray(onDone func()) {
 					// performance: the reason that forcing a refresh here is okay is that users can't spam the
 					// right-click -> select-this-option sequence at a rate that's meaningful to performance
 					d.sessions.refreshSessions(true)
+
+				// learn mapping
+				case <-learnMapping.ClickedCh:
+					logger.Info("Learn mapping menu item clicked, waiting for a slider to move")
+					d.notifier.Notify("Learning slider mapping", "Move the slider you want to map.")
+
+					go func() {
+						sliderIdx, candidates, err := d.learnSlider()
+						if errors.Is(err, errLearnTimeout) {
+							d.notifier.Notify("Learn mode stopped", "No slider was moved, nothing has changed.")
+							return
+						} else if err != nil {
+							logger.Warnw("Failed to learn slider", "error", err)
+							return
+						}
+
+						learnResults <- trayLearnResult{slider: sliderIdx, candidates: candidates}
+					}()
+
+				case learned = <-learnResults:
+					if len(learned.candidates) > len(bindItems) {
+						logger.Warnw("Too many audio sessions to list in the tray, some were left out",
+							"sessions", len(learned.candidates),
+							"shown", len(bindItems))
+					}
+
+					for idx, item := range bindItems {
+						if idx < len(learned.candidates) {
+							item.SetTitle(learned.candidates[idx])
+							item.Show()
+						} else {
+							item.Hide()
+						}
+					}
+
+					bindMenu.SetTitle(fmt.Sprintf("Bind slider %d to...", learned.slider))
+					bindMenu.Show()
+
+					d.notifier.Notify(fmt.Sprintf("Got slider %d!", learned.slider),
+						"Pick what it should control under \"Bind slider to...\" in the tray menu.")
+
+				// bind the learned slider
+				case idx := <-bindClicks:
+					bindMenu.Hide()
+
+					if idx >= len(learned.candidates) {
+						continue
+					}
+
+					logger.Infow("Bind menu item clicked", "slider", learned.slider, "target", learned.candidates[idx])
+
+					// binding reloads the config, which this loop has to be free to hear about
+					go func(slider int, target string) {
+						if err := d.bindLearnedTarget(slider, target); err != nil {
+							d.notifier.Notify("Failed to map slider!", "Please check reeemiks's logs for more details.")
+						}
+					}(learned.slider, learned.candidates[idx])
+
+				// switch profiles
+				case idx := <-profileClicks:
+					profiles := d.config.snapshot().Profiles
+					if idx >= len(profiles) {
+						continue
+					}
+
+					logger.Infow("Profile menu item clicked", "profile", profiles[idx])
+
+					// same as binding, switching reloads the config
+					go d.switchProfile(profiles[idx])
+
+				// toggle automatic profile switching
+				case <-autoProfile.ClickedCh:
+					logger.Info("Automatic profile menu item clicked")
+
+					// resuming doesn't necessarily switch profiles, so there might not be a reload to update the menu
+					if d.autoProfiles.active() {
+						autoProfile.Uncheck()
+						go d.switchProfile(d.config.snapshot().ActiveProfile)
+					} else {
+						autoProfile.Check()
+						go d.switchProfile(profileAuto)
+					}
+
+				case <-configReloadedChannel:
+					updateTrayProfiles(d, profilesMenu, autoProfile, profileItems)
 				}
 			}
 		}()
@@ -80,6 +201,75 @@ func (d *Reeemiks) initializeTray(onDone func()) {
 	systray.Run(onReady, onExit)
 }
 
+// addTrayItemPool adds hidden sub-items under parent and funnels their clicks into one channel, by index
+func addTrayItemPool(parent *systray.MenuItem, size int, checkable bool) ([]*systray.MenuItem, chan int) {
+	items := make([]*systray.MenuItem, size)
+	clicks := make(chan int)
+
+	for idx := range items {
+		if checkable {
+			items[idx] = parent.AddSubMenuItemCheckbox("", "", false)
+		} else {
+			items[idx] = parent.AddSubMenuItem("", "")
+		}
+
+		items[idx].Hide()
+
+		go func(idx int, item *systray.MenuItem) {
+			for range item.ClickedCh {
+				clicks <- idx
+			}
+		}(idx, items[idx])
+	}
+
+	return items, clicks
+}
+
+// updateTrayProfiles lists every profile under the profile menu, with the active one checked
+func updateTrayProfiles(d *Reeemiks, menu *systray.MenuItem, autoItem *systray.MenuItem, items []*systray.MenuItem) {
+	config := d.config.snapshot()
+	profiles := config.Profiles
+	active := config.ActiveProfile
+
+	if len(config.AutoProfileRules) > 0 {
+		if d.autoProfiles.active() {
+			autoItem.Check()
+		} else {
+			autoItem.Uncheck()
+		}
+
+		autoItem.Show()
+	} else {
+		autoItem.Hide()
+	}
+
+	for idx, item := range items {
+		if idx >= len(profiles) {
+			item.Hide()
+			continue
+		}
+
+		item.SetTitle(profiles[idx])
+
+		if profiles[idx] == active {
+			item.Check()
+		} else {
+			item.Uncheck()
+		}
+
+		item.Show()
+	}
+
+	menu.SetTitle(fmt.Sprintf("Profile: %s", active))
+
+	// with nothing but the default profile, there's nothing to switch to
+	if len(profiles) > 1 {
+		menu.Show()
+	} else {
+		menu.Hide()
+	}
+}
+
 func (d *Reeemiks) stopTray() {
 	d.logger.Debug("Quitting tray")
 	systray.Quit()
@@ -7,9 +7,13 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"syscall"
 
+	"github.com/mitchellh/go-ps"
 	"go.uber.org/zap"
 )
 
@@ -72,6 +76,42 @@ func MoveFile(src, dst string) error {
     return nil
 }
 
+// WriteFileAtomic writes data to a temporary file next to path and then renames it into place,
+// so anyone reading path sees either the old contents or the new ones, never half of each
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+
+	// if anything below fails, don't leave the temporary file lying around
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write temporary file: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync temporary file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temporary file: %w", err)
+	}
+
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return fmt.Errorf("chmod temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename temporary file into place: %w", err)
+	}
+
+	return nil
+}
+
 // Linux returns true if we're running on Linux
 func Linux() bool {
 	return runtime.GOOS == "linux"
@@ -88,11 +128,32 @@ func SetupCloseHandler() chan os.Signal {
 
 // GetCurrentWindowProcessNames returns the process names (including extension, if applicable)
 // of the current foreground window. This includes child processes belonging to the window.
-// This is currently only implemented for Windows
+// On Linux this works with X11, sway, i3, Hyprland and KDE
 func GetCurrentWindowProcessNames() ([]string, error) {
 	return getCurrentWindowProcessNames()
 }
 
+// GetCurrentWindowProcessIDs returns the PIDs of the current foreground window's process and its children.
+// This is currently only implemented for Linux
+func GetCurrentWindowProcessIDs() ([]int, error) {
+	return getCurrentWindowProcessIDs()
+}
+
+// ProcessName returns the name of a process's binary, which is what PulseAudio calls application.process.binary.
+// on Linux the executable's name is used, /proc/<pid>/comm is cut short at 15 characters
+func ProcessName(pid int) (string, bool) {
+	if executable, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(pid), "exe")); err == nil {
+		return filepath.Base(strings.TrimSuffix(executable, " (deleted)")), true
+	}
+
+	process, err := ps.FindProcess(pid)
+	if err != nil || process == nil {
+		return "", false
+	}
+
+	return process.Executable(), true
+}
+
 // OpenExternal spawns a detached window with the provided command and argument
 func OpenExternal(logger *zap.SugaredLogger, cmd string, arg string) error {
 
@@ -2,8 +2,133 @@ package util
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/go-ps"
+)
+
+const (
+	getCurrentWindowInternalCooldown = time.Millisecond * 350
+)
+
+var (
+	lastGetCurrentWindowResult []int
+	lastGetCurrentWindowErr    error
+	lastGetCurrentWindowCall   time.Time
+	getCurrentWindowLock       sync.Mutex
 )
 
 func getCurrentWindowProcessNames() ([]string, error) {
-	return nil, errors.New("Not implemented")
+	pids, err := getCurrentWindowProcessIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+
+	for _, pid := range pids {
+		if name, ok := ProcessName(pid); ok {
+			result = append(result, name)
+		}
+	}
+
+	return result, nil
+}
+
+// getCurrentWindowProcessIDs returns the PID of the focused window's process followed by all of its descendants,
+// since plenty of apps (browsers, game launchers) play their audio from a child process
+func getCurrentWindowProcessIDs() ([]int, error) {
+	getCurrentWindowLock.Lock()
+	defer getCurrentWindowLock.Unlock()
+
+	// same cooldown as on windows, asking the compositor every time a slider moves would be a waste
+	now := time.Now()
+	if lastGetCurrentWindowCall.Add(getCurrentWindowInternalCooldown).After(now) {
+		return lastGetCurrentWindowResult, lastGetCurrentWindowErr
+	}
+
+	lastGetCurrentWindowCall = now
+
+	pid, err := activeWindowPID()
+	if err != nil {
+		lastGetCurrentWindowResult, lastGetCurrentWindowErr = nil, err
+		return nil, err
+	}
+
+	lastGetCurrentWindowResult, lastGetCurrentWindowErr = processTree(pid), nil
+	return lastGetCurrentWindowResult, nil
+}
+
+// activeWindowPID asks whoever knows about the focused window on this session: the Wayland compositor if we
+// know how to talk to it, the X server otherwise (which includes i3, and X11 apps under Xwayland as a last resort)
+func activeWindowPID() (int, error) {
+	errs := []error{}
+
+	if signature := os.Getenv("HYPRLAND_INSTANCE_SIGNATURE"); signature != "" {
+		pid, err := hyprlandActiveWindowPID(signature)
+		if err == nil {
+			return pid, nil
+		}
+
+		errs = append(errs, err)
+	}
+
+	for _, env := range []string{"SWAYSOCK", "I3SOCK"} {
+		if socketPath := os.Getenv(env); socketPath != "" {
+			pid, err := i3ActiveWindowPID(socketPath)
+			if err == nil {
+				return pid, nil
+			}
+
+			errs = append(errs, err)
+		}
+	}
+
+	if os.Getenv("WAYLAND_DISPLAY") != "" && kdeSession() {
+		pid, err := kwinActiveWindowPID()
+		if err == nil {
+			return pid, nil
+		}
+
+		errs = append(errs, err)
+	}
+
+	if os.Getenv("DISPLAY") != "" {
+		pid, err := x11ActiveWindowPID()
+		if err == nil {
+			return pid, nil
+		}
+
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 0 {
+		return 0, errors.New("no supported window manager or compositor found")
+	}
+
+	return 0, fmt.Errorf("get active window: %w", errors.Join(errs...))
+}
+
+// processTree returns pid and the PIDs of all its descendants, parents first
+func processTree(pid int) []int {
+	processes, err := ps.Processes()
+	if err != nil {
+		return []int{pid}
+	}
+
+	children := map[int][]int{}
+	for _, process := range processes {
+		children[process.PPid()] = append(children[process.PPid()], process.Pid())
+	}
+
+	result := []int{pid}
+
+	for idx := 0; idx < len(result); idx++ {
+		result = append(result, children[result[idx]]...)
+	}
+
+	return result
 }
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"errors"
 	"fmt"
 	"syscall"
 	"time"
@@ -94,3 +95,7 @@ func getCurrentWindowProcessNames() ([]string, error) {
 	lastGetCurrentWindowResult = result
 	return result, nil
 }
+
+func getCurrentWindowProcessIDs() ([]int, error) {
+	return nil, errors.New("Not implemented")
+}
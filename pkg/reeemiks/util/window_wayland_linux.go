@@ -0,0 +1,312 @@
+package util
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	compositorIPCTimeout = time.Second
+
+	// i3's IPC header is this magic string followed by the payload's length and the message type
+	i3IPCMagic   = "i3-ipc"
+	i3IPCGetTree = 4
+
+	kwinScriptingService   = "org.kde.KWin"
+	kwinScriptingPath      = "/Scripting"
+	kwinScriptingInterface = "org.kde.kwin.Scripting"
+
+	// the object the KWin script calls back on with the active window's PID, whenever it changes
+	kwinCallbackPath      = "/ActiveWindow"
+	kwinCallbackInterface = "io.github.redm.reeemiks.ActiveWindow"
+	kwinScriptName        = "reeemiks-active-window"
+)
+
+// i3Node is the part of a sway/i3 tree node we need. sway has the PID of every view, i3 only their X11 window
+type i3Node struct {
+	Focused       bool     `json:"focused"`
+	PID           int      `json:"pid"`
+	Window        uint32   `json:"window"`
+	Nodes         []i3Node `json:"nodes"`
+	FloatingNodes []i3Node `json:"floating_nodes"`
+}
+
+// i3ActiveWindowPID finds the focused view in the layout tree sway (or i3) hands out over its IPC socket
+func i3ActiveWindowPID(socketPath string) (int, error) {
+	conn, err := net.DialTimeout("unix", socketPath, compositorIPCTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("connect to sway/i3: %w", err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(compositorIPCTimeout))
+
+	// the header is in the host's byte order, which is little-endian everywhere reeemiks runs
+	request := []byte(i3IPCMagic)
+	request = binary.LittleEndian.AppendUint32(request, 0)
+	request = binary.LittleEndian.AppendUint32(request, i3IPCGetTree)
+
+	if _, err := conn.Write(request); err != nil {
+		return 0, fmt.Errorf("request sway/i3 tree: %w", err)
+	}
+
+	header := make([]byte, len(i3IPCMagic)+8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return 0, fmt.Errorf("read sway/i3 tree: %w", err)
+	}
+
+	payload := make([]byte, binary.LittleEndian.Uint32(header[len(i3IPCMagic):]))
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return 0, fmt.Errorf("read sway/i3 tree: %w", err)
+	}
+
+	tree := i3Node{}
+	if err := json.Unmarshal(payload, &tree); err != nil {
+		return 0, fmt.Errorf("parse sway/i3 tree: %w", err)
+	}
+
+	focused, ok := tree.focused()
+	if !ok {
+		return 0, errNoActiveWindow
+	}
+
+	if focused.PID != 0 {
+		return focused.PID, nil
+	}
+
+	if focused.Window != 0 {
+		return x11WindowPID(focused.Window)
+	}
+
+	return 0, errNoActiveWindow
+}
+
+func (n i3Node) focused() (i3Node, bool) {
+	if n.Focused {
+		return n, true
+	}
+
+	for _, children := range [][]i3Node{n.Nodes, n.FloatingNodes} {
+		for _, child := range children {
+			if focused, ok := child.focused(); ok {
+				return focused, true
+			}
+		}
+	}
+
+	return i3Node{}, false
+}
+
+// hyprlandActiveWindowPID asks Hyprland's request socket for the active window
+func hyprlandActiveWindowPID(signature string) (int, error) {
+
+	// Hyprland moved its sockets from /tmp/hypr to the runtime directory at some point
+	candidates := []string{
+		filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), "hypr", signature, ".socket.sock"),
+		filepath.Join("/tmp", "hypr", signature, ".socket.sock"),
+	}
+
+	var conn net.Conn
+	var err error
+
+	for _, candidate := range candidates {
+		if conn, err = net.DialTimeout("unix", candidate, compositorIPCTimeout); err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("connect to Hyprland: %w", err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(compositorIPCTimeout))
+
+	if _, err := conn.Write([]byte("j/activewindow")); err != nil {
+		return 0, fmt.Errorf("request Hyprland active window: %w", err)
+	}
+
+	// Hyprland closes the connection once it has replied
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return 0, fmt.Errorf("read Hyprland active window: %w", err)
+	}
+
+	window := struct {
+		PID int `json:"pid"`
+	}{}
+
+	if err := json.Unmarshal(reply, &window); err != nil {
+		return 0, fmt.Errorf("parse Hyprland active window: %w", err)
+	}
+
+	if window.PID <= 0 {
+		return 0, errNoActiveWindow
+	}
+
+	return window.PID, nil
+}
+
+// kwinWatcher keeps a small script loaded in KWin that calls us back over D-Bus with the active window's PID
+// every time it changes, since KWin doesn't answer that question over D-Bus by itself. the script and the
+// connection it calls back on are set up once, and only set up again if either KWin or the bus goes away
+type kwinWatcher struct {
+	conn     *dbus.Conn
+	callback *kwinCallback
+	lock     sync.Mutex
+}
+
+// kwinCallback is the object our KWin script calls. it only ever holds the latest PID it was told
+type kwinCallback struct {
+	pid       atomic.Int64
+	ready     chan bool // closed once the script reported for the first time
+	readyOnce sync.Once
+}
+
+// activeWindow is Plasma 6, activeClient Plasma 5, and the same goes for the signals
+const kwinScript = `function report(window) {
+	callDBus(%q, %q, %q, "Report", String(window ? window.pid : 0));
+}
+
+(workspace.windowActivated || workspace.clientActivated).connect(report);
+report(workspace.activeWindow || workspace.activeClient);
+`
+
+var kwin = &kwinWatcher{}
+
+func kwinActiveWindowPID() (int, error) {
+	kwin.lock.Lock()
+	defer kwin.lock.Unlock()
+
+	if kwin.conn == nil {
+		if err := kwin.start(); err != nil {
+			return 0, err
+		}
+	}
+
+	pid := kwin.callback.pid.Load()
+	if pid <= 0 {
+		return 0, errNoActiveWindow
+	}
+
+	return int(pid), nil
+}
+
+// start connects to the session bus and loads our script, waiting for its first report. the lock must be held
+func (w *kwinWatcher) start() error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("connect to session bus: %w", err)
+	}
+
+	callback := &kwinCallback{ready: make(chan bool)}
+	if err := conn.Export(callback, kwinCallbackPath, kwinCallbackInterface); err != nil {
+		conn.Close()
+		return fmt.Errorf("export KWin callback: %w", err)
+	}
+
+	// KWin restarting takes our script with it
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, kwinScriptingService),
+	); err != nil {
+		conn.Close()
+		return fmt.Errorf("watch KWin: %w", err)
+	}
+
+	signals := make(chan *dbus.Signal, 1)
+	conn.Signal(signals)
+
+	if err := loadKWinScript(conn, callback); err != nil {
+		conn.Close()
+		return err
+	}
+
+	w.conn = conn
+	w.callback = callback
+
+	go func() {
+
+		// the channel is closed along with the connection
+		for signal := range signals {
+			if signal.Name == "org.freedesktop.DBus.NameOwnerChanged" {
+				break
+			}
+		}
+
+		w.lock.Lock()
+		if w.conn == conn {
+			w.conn, w.callback = nil, nil
+		}
+		w.lock.Unlock()
+
+		conn.Close()
+	}()
+
+	return nil
+}
+
+func loadKWinScript(conn *dbus.Conn, callback *kwinCallback) error {
+	script, err := os.CreateTemp("", kwinScriptName+"-*.js")
+	if err != nil {
+		return fmt.Errorf("create KWin script: %w", err)
+	}
+
+	// KWin reads the file in the background once started, it's done with it by the time the script reports
+	defer os.Remove(script.Name())
+
+	fmt.Fprintf(script, kwinScript, conn.Names()[0], kwinCallbackPath, kwinCallbackInterface)
+	script.Close()
+
+	scripting := conn.Object(kwinScriptingService, kwinScriptingPath)
+
+	// a script left behind by an earlier run would keep the name taken, and call back on a connection that's gone
+	scripting.Call(kwinScriptingInterface+".unloadScript", 0, kwinScriptName)
+
+	if err := scripting.Call(kwinScriptingInterface+".loadScript", 0, script.Name(), kwinScriptName).Err; err != nil {
+		return fmt.Errorf("load KWin script: %w", err)
+	}
+
+	if err := scripting.Call(kwinScriptingInterface+".start", 0).Err; err != nil {
+		scripting.Call(kwinScriptingInterface+".unloadScript", 0, kwinScriptName)
+		return fmt.Errorf("start KWin script: %w", err)
+	}
+
+	select {
+	case <-callback.ready:
+		return nil
+	case <-time.After(compositorIPCTimeout):
+		scripting.Call(kwinScriptingInterface+".unloadScript", 0, kwinScriptName)
+		return errors.New("timed out waiting for KWin")
+	}
+}
+
+// Report is called by our KWin script. the PID comes as a string, JavaScript numbers don't map onto one D-Bus type
+func (c *kwinCallback) Report(pid string) *dbus.Error {
+	value, _ := strconv.Atoi(pid)
+	c.pid.Store(int64(value))
+
+	c.readyOnce.Do(func() {
+		close(c.ready)
+	})
+
+	return nil
+}
+
+func kdeSession() bool {
+	return strings.Contains(strings.ToUpper(os.Getenv("XDG_CURRENT_DESKTOP")), "KDE") || os.Getenv("KDE_FULL_SESSION") != ""
+}
@@ -0,0 +1,108 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// fakeI3 listens like sway's IPC socket and answers a single GET_TREE with tree
+func fakeI3(t *testing.T, tree string) string {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "ipc.sock")
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		header := make([]byte, len(i3IPCMagic)+8)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			t.Errorf("read request: %v", err)
+			return
+		}
+
+		if !bytes.HasPrefix(header, []byte(i3IPCMagic)) || binary.LittleEndian.Uint32(header[len(i3IPCMagic)+4:]) != i3IPCGetTree {
+			t.Errorf("unexpected request %q", header)
+		}
+
+		reply := []byte(i3IPCMagic)
+		reply = binary.LittleEndian.AppendUint32(reply, uint32(len(tree)))
+		reply = binary.LittleEndian.AppendUint32(reply, i3IPCGetTree)
+		conn.Write(append(reply, tree...))
+	}()
+
+	return socketPath
+}
+
+const swayTree = `{
+	"id": 1, "type": "root", "focused": false,
+	"nodes": [
+		{"id": 2, "type": "output", "name": "__i3", "focused": false, "nodes": []},
+		{"id": 3, "type": "output", "name": "DP-1", "focused": false, "nodes": [
+			{"id": 4, "type": "workspace", "name": "1", "focused": false,
+				"nodes": [
+					{"id": 5, "type": "con", "app_id": "foot", "pid": 1200, "focused": false, "nodes": []}
+				],
+				"floating_nodes": [
+					{"id": 6, "type": "floating_con", "app_id": "pavucontrol", "pid": 1300, "focused": true, "nodes": []}
+				]
+			}
+		]}
+	]
+}`
+
+func TestI3NodeFocused(t *testing.T) {
+	tree := i3Node{}
+	if err := json.Unmarshal([]byte(swayTree), &tree); err != nil {
+		t.Fatal(err)
+	}
+
+	focused, ok := tree.focused()
+	if !ok || focused.PID != 1300 {
+		t.Errorf("expected the focused floating window, got %+v", focused)
+	}
+
+	tree.Nodes[1].Nodes[0].FloatingNodes[0].Focused = false
+
+	if focused, ok := tree.focused(); ok {
+		t.Errorf("expected nothing focused, got %+v", focused)
+	}
+}
+
+func TestI3ActiveWindowPID(t *testing.T) {
+	socketPath := fakeI3(t, swayTree)
+
+	pid, err := i3ActiveWindowPID(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pid != 1300 {
+		t.Errorf("expected PID 1300, got %d", pid)
+	}
+}
+
+func TestI3ActiveWindowPIDNoFocus(t *testing.T) {
+	socketPath := fakeI3(t, `{"focused": false, "nodes": [{"focused": false, "pid": 10}]}`)
+
+	if _, err := i3ActiveWindowPID(socketPath); !errors.Is(err, errNoActiveWindow) {
+		t.Errorf("expected no active window, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// x11Watcher holds on to a single connection to the X server, along with the atoms we look up on it.
+// the connection is made on first use, and only made again once the X server has gone away
+type x11Watcher struct {
+	conn         *xgb.Conn
+	root         xproto.Window
+	activeWindow xproto.Atom
+	windowPID    xproto.Atom
+	lock         sync.Mutex
+}
+
+var errNoActiveWindow = errors.New("no active window")
+
+var x11 = &x11Watcher{}
+
+func init() {
+
+	// xgb logs to stderr by default, failing to connect is something we report ourselves
+	xgb.Logger = log.New(io.Discard, "", 0)
+}
+
+// x11ActiveWindowPID asks the window manager for the active window, and that window for the process it belongs to
+func x11ActiveWindowPID() (int, error) {
+	x11.lock.Lock()
+	defer x11.lock.Unlock()
+
+	if err := x11.ensureConnected(); err != nil {
+		return 0, err
+	}
+
+	window, err := x11.property(x11.root, x11.activeWindow)
+	if err != nil {
+		return 0, fmt.Errorf("get _NET_ACTIVE_WINDOW: %w", err)
+	}
+
+	if window == 0 {
+		return 0, errNoActiveWindow
+	}
+
+	return x11.pidOf(xproto.Window(window))
+}
+
+// x11WindowPID returns the process a window belongs to, for window managers that hand out window ids
+func x11WindowPID(window uint32) (int, error) {
+	x11.lock.Lock()
+	defer x11.lock.Unlock()
+
+	if err := x11.ensureConnected(); err != nil {
+		return 0, err
+	}
+
+	return x11.pidOf(xproto.Window(window))
+}
+
+// ensureConnected connects to the X server named by DISPLAY unless we already are, and interns the atoms we need.
+// xgb takes care of finding the display's cookie in .Xauthority. the lock must be held
+func (w *x11Watcher) ensureConnected() error {
+	if w.conn != nil {
+		return nil
+	}
+
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return fmt.Errorf("connect to X server: %w", err)
+	}
+
+	activeWindow, err := internAtom(conn, "_NET_ACTIVE_WINDOW")
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	windowPID, err := internAtom(conn, "_NET_WM_PID")
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	w.conn = conn
+	w.root = xproto.Setup(conn).DefaultScreen(conn).Root
+	w.activeWindow = activeWindow
+	w.windowPID = windowPID
+
+	go func() {
+
+		// we never select any events, but the server may send some anyway. once there's nothing left to wait for,
+		// the connection is gone
+		for {
+			event, xErr := conn.WaitForEvent()
+			if event == nil && xErr == nil {
+				break
+			}
+		}
+
+		w.lock.Lock()
+		if w.conn == conn {
+			w.conn = nil
+		}
+		w.lock.Unlock()
+	}()
+
+	return nil
+}
+
+func (w *x11Watcher) pidOf(window xproto.Window) (int, error) {
+	pid, err := w.property(window, w.windowPID)
+	if err != nil {
+		return 0, fmt.Errorf("get _NET_WM_PID: %w", err)
+	}
+
+	if pid == 0 {
+		return 0, fmt.Errorf("window 0x%x doesn't say which process it belongs to", window)
+	}
+
+	return int(pid), nil
+}
+
+// property reads the first 32-bit value of a window's property, 0 if it isn't set. the lock must be held
+func (w *x11Watcher) property(window xproto.Window, atom xproto.Atom) (uint32, error) {
+	reply, err := xproto.GetProperty(w.conn, false, window, atom, xproto.GetPropertyTypeAny, 0, 1).Reply()
+	if err != nil {
+
+		// an X11 error is about this window, anything else means the connection broke
+		if _, ok := err.(xgb.Error); !ok {
+			w.conn.Close()
+			w.conn = nil
+		}
+
+		return 0, err
+	}
+
+	if reply.Format != 32 || reply.ValueLen == 0 {
+		return 0, nil
+	}
+
+	return xgb.Get32(reply.Value), nil
+}
+
+func internAtom(conn *xgb.Conn, name string) (xproto.Atom, error) {
+	reply, err := xproto.InternAtom(conn, true, uint16(len(name)), name).Reply()
+	if err != nil {
+		return 0, fmt.Errorf("intern %s: %w", name, err)
+	}
+
+	if reply.Atom == 0 {
+		return 0, fmt.Errorf("the window manager doesn't support %s", name)
+	}
+
+	return reply.Atom, nil
+}
@@ -0,0 +1,369 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	fakeX11Root         = 0x2a7
+	fakeX11BadWindow    = 0xbad
+	fakeX11ActiveWindow = 301
+	fakeX11WindowPID    = 302
+)
+
+// fakeX11Server is just enough of an X server to answer the requests we make: InternAtom and GetProperty.
+// it listens where DISPLAY points to, and counts the connections it gets
+type fakeX11Server struct {
+	t        *testing.T
+	listener net.Listener
+
+	// window -> atom -> value
+	properties map[uint32]map[uint32]uint32
+
+	connections []net.Conn
+	cookies     [][]byte
+	interned    int
+	lock        sync.Mutex
+}
+
+func newFakeX11(t *testing.T) *fakeX11Server {
+	t.Helper()
+
+	// a DISPLAY starting with a path is dialed as that path, followed by the display number
+	socket := filepath.Join(t.TempDir(), "X")
+
+	listener, err := net.Listen("unix", socket+":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("DISPLAY", socket+":0")
+	t.Setenv("XAUTHORITY", filepath.Join(t.TempDir(), "missing"))
+
+	server := &fakeX11Server{
+		t:          t,
+		listener:   listener,
+		properties: map[uint32]map[uint32]uint32{fakeX11Root: {}},
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			server.lock.Lock()
+			server.connections = append(server.connections, conn)
+			server.lock.Unlock()
+
+			go server.serve(conn)
+		}
+	}()
+
+	t.Cleanup(func() {
+		listener.Close()
+		server.disconnect()
+
+		x11.lock.Lock()
+		if x11.conn != nil {
+			x11.conn.Close()
+			x11.conn = nil
+		}
+		x11.lock.Unlock()
+	})
+
+	return server
+}
+
+func (s *fakeX11Server) setProperty(window uint32, atom uint32, value uint32) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.properties[window] == nil {
+		s.properties[window] = map[uint32]uint32{}
+	}
+
+	s.properties[window][atom] = value
+}
+
+// disconnect drops every client, like an X server going away
+func (s *fakeX11Server) disconnect() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, conn := range s.connections {
+		conn.Close()
+	}
+}
+
+func (s *fakeX11Server) stats() (connections int, interned int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return len(s.connections), s.interned
+}
+
+func (s *fakeX11Server) serve(conn net.Conn) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+
+	nameLength := int(binary.LittleEndian.Uint16(header[6:]))
+	cookieLength := int(binary.LittleEndian.Uint16(header[8:]))
+
+	auth := make([]byte, (nameLength+3)/4*4+(cookieLength+3)/4*4)
+	if _, err := io.ReadFull(conn, auth); err != nil {
+		return
+	}
+
+	s.lock.Lock()
+	s.cookies = append(s.cookies, auth[len(auth)-(cookieLength+3)/4*4:][:cookieLength])
+	s.lock.Unlock()
+
+	conn.Write(setupReply(fakeX11Root))
+
+	var sequence uint16
+
+	for {
+		request := make([]byte, 4)
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return
+		}
+
+		body := make([]byte, int(binary.LittleEndian.Uint16(request[2:]))*4-4)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+
+		request = append(request, body...)
+		sequence++
+
+		switch request[0] {
+		case 16: // InternAtom
+			name := string(request[8 : 8+binary.LittleEndian.Uint16(request[4:])])
+
+			atoms := map[string]uint32{"_NET_ACTIVE_WINDOW": fakeX11ActiveWindow, "_NET_WM_PID": fakeX11WindowPID}
+
+			s.lock.Lock()
+			s.interned++
+			s.lock.Unlock()
+
+			conn.Write(x11Reply(sequence, 0, binary.LittleEndian.AppendUint32(nil, atoms[name])))
+
+		case 20: // GetProperty
+			window := binary.LittleEndian.Uint32(request[4:])
+			atom := binary.LittleEndian.Uint32(request[8:])
+
+			if window == fakeX11BadWindow {
+				reply := make([]byte, 32)
+				reply[1] = 3 // BadWindow
+				binary.LittleEndian.PutUint16(reply[2:], sequence)
+				conn.Write(reply)
+				continue
+			}
+
+			s.lock.Lock()
+			value, ok := s.properties[window][atom]
+			s.lock.Unlock()
+
+			if !ok {
+				conn.Write(x11Reply(sequence, 0, nil))
+				continue
+			}
+
+			// type, bytes after and the value's length, 12 bytes of padding and the value itself
+			data := binary.LittleEndian.AppendUint32(nil, 6)
+			data = binary.LittleEndian.AppendUint32(data, 0)
+			data = binary.LittleEndian.AppendUint32(data, 1)
+			data = append(data, make([]byte, 12)...)
+			data = binary.LittleEndian.AppendUint32(data, value)
+
+			conn.Write(x11Reply(sequence, 32, data))
+
+		default:
+			s.t.Errorf("unexpected request %v", request)
+		}
+	}
+}
+
+// x11Reply builds a reply: 32 bytes at least, with whatever doesn't fit announced in its length
+func x11Reply(sequence uint16, detail byte, data []byte) []byte {
+	reply := []byte{1, detail}
+	reply = binary.LittleEndian.AppendUint16(reply, sequence)
+
+	extra := 0
+	if len(data) > 24 {
+		extra = len(data) - 24
+	}
+
+	reply = binary.LittleEndian.AppendUint32(reply, uint32((extra+3)/4))
+	reply = append(reply, data...)
+
+	return append(reply, make([]byte, 32+(extra+3)/4*4-len(reply))...)
+}
+
+// setupReply builds a successful setup reply with a single screen, whose root window comes after
+// the vendor string and the pixmap formats
+func setupReply(root uint32) []byte {
+	vendor := []byte("Fake")
+	formats := 2
+
+	data := make([]byte, 32)
+	binary.LittleEndian.PutUint32(data[4:], 0x200000) // resource id base
+	binary.LittleEndian.PutUint32(data[8:], 0x1fffff) // resource id mask
+	binary.LittleEndian.PutUint16(data[16:], uint16(len(vendor)))
+	data[20] = 1 // screens
+	data[21] = byte(formats)
+	data = append(data, vendor...)
+	data = append(data, make([]byte, formats*8)...)
+	data = binary.LittleEndian.AppendUint32(data, root)
+	data = append(data, make([]byte, 36)...)
+
+	header := []byte{1, 0}
+	header = binary.LittleEndian.AppendUint16(header, 11)
+	header = binary.LittleEndian.AppendUint16(header, 0)
+	header = binary.LittleEndian.AppendUint16(header, uint16(len(data)/4))
+
+	return append(header, data...)
+}
+
+func TestX11ActiveWindowPID(t *testing.T) {
+	server := newFakeX11(t)
+	server.setProperty(fakeX11Root, fakeX11ActiveWindow, 0x400007)
+	server.setProperty(0x400007, fakeX11WindowPID, 4242)
+
+	for i := 0; i < 3; i++ {
+		pid, err := x11ActiveWindowPID()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if pid != 4242 {
+			t.Errorf("expected PID 4242, got %d", pid)
+		}
+	}
+
+	if connections, interned := server.stats(); connections != 1 || interned != 2 {
+		t.Errorf("expected one connection with both atoms interned once, got %d connections and %d atoms",
+			connections, interned)
+	}
+}
+
+func TestX11NoActiveWindow(t *testing.T) {
+	newFakeX11(t)
+
+	if _, err := x11ActiveWindowPID(); !errors.Is(err, errNoActiveWindow) {
+		t.Errorf("expected errNoActiveWindow, got %v", err)
+	}
+}
+
+func TestX11WindowPID(t *testing.T) {
+	server := newFakeX11(t)
+	server.setProperty(0x400007, fakeX11WindowPID, 4242)
+
+	if pid, err := x11WindowPID(0x400007); err != nil || pid != 4242 {
+		t.Errorf("expected PID 4242, got %d (%v)", pid, err)
+	}
+
+	if _, err := x11WindowPID(0x400008); err == nil {
+		t.Error("expected a window without _NET_WM_PID to fail")
+	}
+
+	// an X11 error is about the window, not the connection
+	if _, err := x11WindowPID(fakeX11BadWindow); err == nil {
+		t.Error("expected a bad window to fail")
+	}
+
+	if pid, err := x11WindowPID(0x400007); err != nil || pid != 4242 {
+		t.Errorf("expected PID 4242, got %d (%v)", pid, err)
+	}
+
+	if connections, _ := server.stats(); connections != 1 {
+		t.Errorf("expected a single connection, got %d", connections)
+	}
+}
+
+func TestX11Reconnects(t *testing.T) {
+	server := newFakeX11(t)
+	server.setProperty(0x400007, fakeX11WindowPID, 4242)
+
+	if _, err := x11WindowPID(0x400007); err != nil {
+		t.Fatal(err)
+	}
+
+	server.disconnect()
+
+	// the call that notices the connection is gone may fail, the next one connects again
+	deadline := time.Now().Add(time.Second)
+	for {
+		pid, err := x11WindowPID(0x400007)
+		if err == nil && pid == 4242 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected to reconnect, last got %d (%v)", pid, err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if connections, _ := server.stats(); connections != 2 {
+		t.Errorf("expected to connect twice, got %d connections", connections)
+	}
+}
+
+func xauthEntry(family uint16, fields ...string) []byte {
+	entry := binary.BigEndian.AppendUint16(nil, family)
+	for _, field := range fields {
+		entry = binary.BigEndian.AppendUint16(entry, uint16(len(field)))
+		entry = append(entry, field...)
+	}
+
+	return entry
+}
+
+func TestX11Authenticates(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skip("no hostname")
+	}
+
+	server := newFakeX11(t)
+
+	cookie := "0123456789abcdef"
+	contents := bytes.Join([][]byte{
+		xauthEntry(256, "elsewhere", "0", "MIT-MAGIC-COOKIE-1", "fedcba9876543210"),
+		xauthEntry(256, hostname, "1", "MIT-MAGIC-COOKIE-1", "fedcba9876543210"),
+		xauthEntry(256, hostname, "0", "MIT-MAGIC-COOKIE-1", cookie),
+	}, nil)
+
+	path := filepath.Join(t.TempDir(), "Xauthority")
+	if err := os.WriteFile(path, contents, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("XAUTHORITY", path)
+
+	if _, err := x11ActiveWindowPID(); !errors.Is(err, errNoActiveWindow) {
+		t.Fatalf("expected errNoActiveWindow, got %v", err)
+	}
+
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	if len(server.cookies) != 1 || string(server.cookies[0]) != cookie {
+		t.Errorf("expected the display's cookie to be sent, got %q", server.cookies)
+	}
+}
@@ -0,0 +1,72 @@
+package reeemiks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// virtualDevice is a sink reeemiks creates while it runs, for routing apps into a group a slider controls.
+// unless output is "none", whatever plays into it is looped back out to the output sink (or the default one)
+type virtualDevice struct {
+	Name        string `mapstructure:"name"`
+	Description string `mapstructure:"description"`
+	Output      string `mapstructure:"output"`
+}
+
+const (
+	virtualDeviceOutputNone = "none"
+
+	// every module we load carries this property, so we can find them again (even after a crash) without keeping track
+	virtualDeviceProperty = "reeemiks.virtual_device"
+
+	virtualDeviceNullSinkModule = "module-null-sink"
+	virtualDeviceLoopbackModule = "module-loopback"
+)
+
+// sink names end up unquoted in module arguments, so keep them simple
+var virtualDeviceNamePattern = regexp.MustCompile(`^[\w.-]+$`)
+
+// virtualDeviceModule is a single module to load, identified by its name and arguments
+type virtualDeviceModule struct {
+	name string
+	args string
+}
+
+// modules returns the modules that make up the device, the null sink always coming first
+func (d virtualDevice) modules() []virtualDeviceModule {
+	description := d.Description
+	if description == "" {
+		description = d.Name
+	}
+
+	modules := []virtualDeviceModule{{
+		name: virtualDeviceNullSinkModule,
+		args: fmt.Sprintf(`sink_name=%s sink_properties='device.description="%s" %s="%s"'`,
+			d.Name, quoteModuleProperty(description), virtualDeviceProperty, d.Name),
+	}}
+
+	if strings.EqualFold(d.Output, virtualDeviceOutputNone) {
+		return modules
+	}
+
+	// without a sink the loopback plays to the default sink, and follows it around
+	args := fmt.Sprintf(`source=%s.monitor source_dont_move=true sink_input_properties='media.name="%s" %s="%s"'`,
+		d.Name, quoteModuleProperty(description+" loopback"), virtualDeviceProperty, d.Name)
+
+	if d.Output != "" {
+		args = fmt.Sprintf("sink=%s %s", d.Output, args)
+	}
+
+	return append(modules, virtualDeviceModule{name: virtualDeviceLoopbackModule, args: args})
+}
+
+// module arguments are already single-quoted and property values double-quoted, so neither can appear inside them
+func quoteModuleProperty(value string) string {
+	return strings.NewReplacer(`"`, "", "'", "").Replace(value)
+}
+
+// isVirtualDeviceModule tells our modules apart from everything else loaded on the server
+func isVirtualDeviceModule(moduleArgs string) bool {
+	return strings.Contains(moduleArgs, virtualDeviceProperty+"=")
+}
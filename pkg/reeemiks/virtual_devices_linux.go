@@ -0,0 +1,103 @@
+package reeemiks
+
+import (
+	"fmt"
+
+	"github.com/jfreymuth/pulse/proto"
+)
+
+// syncVirtualDevices loads the modules for every configured virtual device that isn't there yet,
+// and unloads our modules that the config no longer asks for (or asks for differently)
+func (sf *paSessionFinder) syncVirtualDevices() error {
+	wanted := map[string]bool{}
+	toLoad := []virtualDeviceModule{}
+
+	for _, device := range sf.config.snapshot().VirtualDevices {
+		for _, module := range device.modules() {
+			wanted[module.name+" "+module.args] = true
+			toLoad = append(toLoad, module)
+		}
+	}
+
+	loaded, err := sf.virtualDeviceModules()
+	if err != nil {
+		return err
+	}
+
+	stale := []*proto.GetModuleInfoReply{}
+	present := map[string]bool{}
+
+	for _, info := range loaded {
+		key := info.ModuleName + " " + info.ModuleArgs
+
+		if wanted[key] && !present[key] {
+			present[key] = true
+		} else {
+			stale = append(stale, info)
+		}
+	}
+
+	sf.unloadModules(stale)
+
+	for _, module := range toLoad {
+		if present[module.name+" "+module.args] {
+			continue
+		}
+
+		reply := proto.LoadModuleReply{}
+		if err := sf.client.Request(&proto.LoadModule{Name: module.name, Args: module.args}, &reply); err != nil {
+			return fmt.Errorf("load %s: %w", module.name, err)
+		}
+
+		sf.logger.Infow("Loaded virtual device module", "module", module.name, "args", module.args, "index", reply.ModuleIndex)
+	}
+
+	return nil
+}
+
+// unloadVirtualDevices unloads every module we loaded, so nothing outlives reeemiks
+func (sf *paSessionFinder) unloadVirtualDevices() error {
+	loaded, err := sf.virtualDeviceModules()
+	if err != nil {
+		return err
+	}
+
+	sf.unloadModules(loaded)
+
+	return nil
+}
+
+func (sf *paSessionFinder) virtualDeviceModules() ([]*proto.GetModuleInfoReply, error) {
+	reply := proto.GetModuleInfoListReply{}
+	if err := sf.client.Request(&proto.GetModuleInfoList{}, &reply); err != nil {
+		return nil, fmt.Errorf("get module list: %w", err)
+	}
+
+	modules := []*proto.GetModuleInfoReply{}
+	for _, info := range reply {
+		if isVirtualDeviceModule(info.ModuleArgs) {
+			modules = append(modules, info)
+		}
+	}
+
+	return modules, nil
+}
+
+func (sf *paSessionFinder) unloadModules(modules []*proto.GetModuleInfoReply) {
+
+	// loopbacks go first: unloading a null sink takes its loopback down with it, and then we'd fail to unload that
+	for _, name := range []string{virtualDeviceLoopbackModule, virtualDeviceNullSinkModule} {
+		for _, info := range modules {
+			if info.ModuleName != name {
+				continue
+			}
+
+			if err := sf.client.Request(&proto.UnloadModule{ModuleIndex: info.ModuleIndex}, nil); err != nil {
+				sf.logger.Warnw("Failed to unload virtual device module", "module", info.ModuleName, "index", info.ModuleIndex, "error", err)
+				continue
+			}
+
+			sf.logger.Infow("Unloaded virtual device module", "module", info.ModuleName, "args", info.ModuleArgs)
+		}
+	}
+}